@@ -0,0 +1,270 @@
+package bounce
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// Authenticator checks that a webhook request comes from the provider, the webhooks refuse all the requests
+	// without one.
+	Authenticator interface {
+		Authenticate(r *http.Request, payload []byte) error
+	}
+
+	// AuthenticatorFunc is a function used as an Authenticator (ie: to check a shared secret).
+	AuthenticatorFunc func(r *http.Request, payload []byte) error
+
+	SNSParams struct {
+		// TopicARNs are the topics accepted, required.
+		TopicARNs []string
+		// CertHost matches the host of the signing certificates URL, DefaultSNSCertHost by default.
+		CertHost *regexp.Regexp
+		// Client downloads the signing certificates, http.DefaultClient by default.
+		Client *http.Client
+	}
+
+	// SNSVerifier checks the signatures of the SNS messages, and that they come from the expected topics.
+	SNSVerifier struct {
+		topics   map[string]bool
+		certHost *regexp.Regexp
+		client   *http.Client
+		mutex    sync.Mutex
+		certs    map[string]*x509.Certificate
+	}
+
+	SendGridParams struct {
+		// PublicKey is the verification key of the signed event webhook, as given by SendGrid (base64 DER).
+		PublicKey string
+		// MaxAge refuses the events signed before, not checked when 0.
+		MaxAge time.Duration
+	}
+
+	// SendGridVerifier checks the signatures of the SendGrid signed event webhook.
+	SendGridVerifier struct {
+		key    *ecdsa.PublicKey
+		maxAge time.Duration
+	}
+
+	snsMessage struct {
+		Type             string
+		MessageID        string `json:"MessageId"`
+		Token            string
+		TopicArn         string
+		Subject          string
+		Message          string
+		Timestamp        string
+		SubscribeURL     string
+		SignatureVersion string
+		Signature        string
+		SigningCertURL   string
+	}
+)
+
+const (
+	SendGridSignatureHeader = "X-Twilio-Email-Event-Webhook-Signature"
+	SendGridTimestampHeader = "X-Twilio-Email-Event-Webhook-Timestamp"
+
+	maxCertSize = 64 << 10
+)
+
+var (
+	// DefaultSNSCertHost matches the hosts of the SNS signing certificates, ie: sns.us-east-1.amazonaws.com.
+	DefaultSNSCertHost = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+	ErrUnauthenticated = errors.New("webhook request is not authenticated")
+	ErrNoTopic         = errors.New("at least one topic is required")
+	ErrInvalidKey      = errors.New("invalid public key")
+)
+
+func (f AuthenticatorFunc) Authenticate(r *http.Request, payload []byte) error {
+	return f(r, payload)
+}
+
+func NewSNSVerifier(params SNSParams) (*SNSVerifier, error) {
+	if len(params.TopicARNs) == 0 {
+		return nil, ErrNoTopic
+	}
+	res := &SNSVerifier{
+		topics:   map[string]bool{},
+		certHost: params.CertHost,
+		client:   params.Client,
+		certs:    map[string]*x509.Certificate{},
+	}
+	for _, topic := range params.TopicARNs {
+		res.topics[topic] = true
+	}
+	if res.certHost == nil {
+		res.certHost = DefaultSNSCertHost
+	}
+	if res.client == nil {
+		res.client = http.DefaultClient
+	}
+	return res, nil
+}
+
+// Authenticate checks the topic, the signing certificate URL and the signature of the SNS message.
+func (v *SNSVerifier) Authenticate(r *http.Request, payload []byte) error {
+	msg := snsMessage{}
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return ErrUnauthenticated
+	}
+	if !v.topics[msg.TopicArn] {
+		return ErrUnauthenticated
+	}
+
+	var hash crypto.Hash
+	switch msg.SignatureVersion {
+	case "1":
+		hash = crypto.SHA1
+	case "2":
+		hash = crypto.SHA256
+	default:
+		return ErrUnauthenticated
+	}
+	signature, err := base64.StdEncoding.DecodeString(msg.Signature)
+	if err != nil {
+		return ErrUnauthenticated
+	}
+
+	cert, err := v.certificate(msg.SigningCertURL)
+	if err != nil {
+		return ErrUnauthenticated
+	}
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return ErrUnauthenticated
+	}
+
+	var digest []byte
+	if hash == crypto.SHA1 {
+		sum := sha1.Sum([]byte(msg.stringToSign()))
+		digest = sum[:]
+	} else {
+		sum := sha256.Sum256([]byte(msg.stringToSign()))
+		digest = sum[:]
+	}
+	if err := rsa.VerifyPKCS1v15(key, hash, digest, signature); err != nil {
+		return ErrUnauthenticated
+	}
+	return nil
+}
+
+// certificate downloads the signing certificate, the certificates are cached by URL.
+func (v *SNSVerifier) certificate(certURL string) (*x509.Certificate, error) {
+	u, err := url.Parse(certURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" || !v.certHost.MatchString(u.Host) || !strings.HasSuffix(u.Path, ".pem") {
+		return nil, ErrUnauthenticated
+	}
+
+	v.mutex.Lock()
+	cert, ok := v.certs[certURL]
+	v.mutex.Unlock()
+	if !ok {
+		if cert, err = v.download(certURL); err != nil {
+			return nil, err
+		}
+		v.mutex.Lock()
+		v.certs[certURL] = cert
+		v.mutex.Unlock()
+	}
+
+	if now := time.Now(); now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return nil, ErrUnauthenticated
+	}
+	return cert, nil
+}
+
+func (v *SNSVerifier) download(certURL string) (*x509.Certificate, error) {
+	resp, err := v.client.Get(certURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrUnauthenticated
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxCertSize))
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrUnauthenticated
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// stringToSign returns the fields signed by SNS, in the order of the documentation.
+func (m snsMessage) stringToSign() string {
+	fields := []string{"Message", m.Message, "MessageId", m.MessageID}
+	if m.Type == "Notification" {
+		if m.Subject != "" {
+			fields = append(fields, "Subject", m.Subject)
+		}
+		fields = append(fields, "Timestamp", m.Timestamp)
+	} else {
+		fields = append(fields, "SubscribeURL", m.SubscribeURL, "Timestamp", m.Timestamp, "Token", m.Token)
+	}
+	fields = append(fields, "TopicArn", m.TopicArn, "Type", m.Type)
+	return strings.Join(fields, "\n") + "\n"
+}
+
+func NewSendGridVerifier(params SendGridParams) (*SendGridVerifier, error) {
+	der, err := base64.StdEncoding.DecodeString(params.PublicKey)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	return &SendGridVerifier{key: ecKey, maxAge: params.MaxAge}, nil
+}
+
+// Authenticate checks the ECDSA signature of the timestamp and the payload.
+func (v *SendGridVerifier) Authenticate(r *http.Request, payload []byte) error {
+	timestamp := r.Header.Get(SendGridTimestampHeader)
+	signature, err := base64.StdEncoding.DecodeString(r.Header.Get(SendGridSignatureHeader))
+	if err != nil || timestamp == "" || len(signature) == 0 {
+		return ErrUnauthenticated
+	}
+
+	if v.maxAge != 0 {
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil || time.Since(time.Unix(seconds, 0)) > v.maxAge {
+			return ErrUnauthenticated
+		}
+	}
+
+	digest := sha256.Sum256(append([]byte(timestamp), payload...))
+	if !ecdsa.VerifyASN1(v.key, digest[:], signature) {
+		return ErrUnauthenticated
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+// Package bounce processes the bounces coming back from the recipients mail servers: delivery status notifications
+// (RFC 3464) received by the envelope sender, and the bounce webhooks of the providers (Amazon SES through SNS, and
+// SendGrid). The failures are classified as hard or soft and emitted as events, SuppressionHandler adds the hard
+// bounces and complaints to a suppression list.
+package bounce
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/fdelbos/mauth/suppression"
+)
+
+type (
+	Processor struct {
+		handlers []Handler
+	}
+)
+
+const (
+	maxPayloadSize = 10 << 20
+)
+
+func NewProcessor(handlers ...Handler) *Processor {
+	return &Processor{handlers: handlers}
+}
+
+func (p Processor) emit(ctx context.Context, events []Event) error {
+	for _, event := range events {
+		for _, handler := range p.handlers {
+			if err := handler(ctx, event); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ProcessDSN parses a raw delivery status notification and emits its events.
+func (p Processor) ProcessDSN(ctx context.Context, r io.Reader) error {
+	events, err := ParseDSN(r)
+	if err != nil {
+		return err
+	}
+	return p.emit(ctx, events)
+}
+
+// ProcessSES parses an Amazon SES notification and emits its events.
+func (p Processor) ProcessSES(ctx context.Context, payload []byte) error {
+	events, err := ParseSES(payload)
+	if err != nil {
+		return err
+	}
+	return p.emit(ctx, events)
+}
+
+// ProcessSendGrid parses a batch of SendGrid events and emits the bounces.
+func (p Processor) ProcessSendGrid(ctx context.Context, payload []byte) error {
+	events, err := ParseSendGrid(payload)
+	if err != nil {
+		return err
+	}
+	return p.emit(ctx, events)
+}
+
+// SESWebhook returns an http handler for the SNS subscription of the SES notifications, the requests are checked by
+// auth (ie: SNSVerifier) and all of them are refused when it is nil. The subscription itself must be confirmed
+// manually.
+func (p Processor) SESWebhook(auth Authenticator) http.Handler {
+	return p.webhook(auth, p.ProcessSES)
+}
+
+// SendGridWebhook returns an http handler for the SendGrid event webhook, the requests are checked by auth (ie:
+// SendGridVerifier) and all of them are refused when it is nil.
+func (p Processor) SendGridWebhook(auth Authenticator) http.Handler {
+	return p.webhook(auth, p.ProcessSendGrid)
+}
+
+func (p Processor) webhook(auth Authenticator, process func(context.Context, []byte) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		payload, err := ioutil.ReadAll(io.LimitReader(r.Body, maxPayloadSize))
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		if auth == nil || auth.Authenticate(r, payload) != nil {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		switch err := process(r.Context(), payload); err {
+		case nil, ErrUnsupportedType:
+			w.WriteHeader(http.StatusOK)
+		case ErrInvalidPayload:
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		default:
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	})
+}
+
+// SuppressionHandler adds the recipients of the hard bounces and complaints to the suppression list.
+func SuppressionHandler(store suppression.Store) Handler {
+	return func(ctx context.Context, event Event) error {
+		var reason string
+		switch event.Type {
+		case TypeHard:
+			reason = suppression.ReasonBounce
+		case TypeComplaint:
+			reason = suppression.ReasonComplaint
+		default:
+			return nil
+		}
+
+		return store.Add(ctx, suppression.Entry{
+			Address:   event.Recipient,
+			Reason:    reason,
+			Details:   strings.TrimSpace(event.Status + " " + event.Diagnostic),
+			CreatedAt: event.Time,
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package bounce
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fdelbos/mauth/suppression"
+	"github.com/stretchr/testify/require"
+)
+
+func readFile(t *testing.T, name string) []byte {
+	content, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	require.Nil(t, err)
+	return content
+}
+
+func TestParseDSN(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "hard.eml"))
+	require.Nil(t, err)
+	defer f.Close()
+
+	events, err := ParseDSN(f)
+	require.Nil(t, err)
+	require.Len(t, events, 2)
+
+	when := time.Date(2020, 12, 1, 9, 15, 2, 0, time.UTC)
+	require.Equal(t, "unknown@example.com", events[0].Recipient)
+	require.Equal(t, TypeHard, events[0].Type)
+	require.Equal(t, "5.1.1", events[0].Status)
+	require.Equal(t, "550 5.1.1 <unknown@example.com>: Recipient address rejected: User unknown", events[0].Diagnostic)
+	require.Equal(t, SourceDSN, events[0].Source)
+	require.True(t, when.Equal(events[0].Time))
+
+	require.Equal(t, "full@example.com", events[1].Recipient)
+	require.Equal(t, TypeSoft, events[1].Type)
+}
+
+func TestParseDSNDelayed(t *testing.T) {
+	events, err := ParseDSN(bytes.NewReader(readFile(t, "delayed.eml")))
+	require.Nil(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "slow@example.net", events[0].Recipient)
+	require.Equal(t, TypeSoft, events[0].Type)
+	require.Equal(t, "4.4.1", events[0].Status)
+}
+
+func TestParseDSNRegular(t *testing.T) {
+	_, err := ParseDSN(bytes.NewReader(readFile(t, "regular.eml")))
+	require.Equal(t, ErrNotDSN, err)
+}
+
+func TestParseSES(t *testing.T) {
+	events, err := ParseSES(readFile(t, "ses_bounce.json"))
+	require.Nil(t, err)
+	require.Equal(t, []Event{{
+		Recipient:  "unknown@example.com",
+		Type:       TypeHard,
+		Status:     "5.1.1",
+		Diagnostic: "550 5.1.1 user unknown",
+		Source:     SourceSES,
+		Time:       time.Date(2020, 12, 1, 10, 15, 2, 0, time.UTC),
+	}}, events)
+
+	events, err = ParseSES(readFile(t, "ses_complaint.json"))
+	require.Nil(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "angry@example.com", events[0].Recipient)
+	require.Equal(t, TypeComplaint, events[0].Type)
+
+	_, err = ParseSES([]byte(`{"Type":"SubscriptionConfirmation","SubscribeURL":"https://sns.example.com"}`))
+	require.Equal(t, ErrUnsupportedType, err)
+
+	_, err = ParseSES([]byte(`not json`))
+	require.Equal(t, ErrInvalidPayload, err)
+}
+
+func TestParseSendGrid(t *testing.T) {
+	events, err := ParseSendGrid(readFile(t, "sendgrid.json"))
+	require.Nil(t, err)
+	require.Len(t, events, 4)
+
+	expected := []struct {
+		recipient string
+		kind      Type
+	}{
+		{"unknown@example.com", TypeHard},
+		{"listed@example.com", TypeSoft},
+		{"slow@example.com", TypeSoft},
+		{"angry@example.com", TypeComplaint},
+	}
+	for i, e := range expected {
+		require.Equal(t, e.recipient, events[i].Recipient)
+		require.Equal(t, e.kind, events[i].Type, e.recipient)
+	}
+}
+
+func TestSuppressionHandler(t *testing.T) {
+	ctx := context.Background()
+	store := suppression.NewMemory()
+	p := NewProcessor(SuppressionHandler(store))
+
+	require.Nil(t, p.ProcessDSN(ctx, bytes.NewReader(readFile(t, "hard.eml"))))
+
+	entry, err := store.Get(ctx, "unknown@example.com")
+	require.Nil(t, err)
+	require.Equal(t, suppression.ReasonBounce, entry.Reason)
+	require.Contains(t, entry.Details, "5.1.1")
+
+	// soft bounces are not suppressed
+	_, err = store.Get(ctx, "full@example.com")
+	require.Equal(t, suppression.ErrNotFound, err)
+}
+
+func TestWebhook(t *testing.T) {
+	allow := AuthenticatorFunc(func(*http.Request, []byte) error { return nil })
+	deny := AuthenticatorFunc(func(*http.Request, []byte) error { return ErrUnauthenticated })
+	events := []Event{}
+	p := NewProcessor(func(ctx context.Context, event Event) error {
+		events = append(events, event)
+		return nil
+	})
+
+	for _, c := range []struct {
+		handler http.Handler
+		payload []byte
+		status  int
+		events  int
+	}{
+		{p.SendGridWebhook(allow), readFile(t, "sendgrid.json"), http.StatusOK, 4},
+		{p.SESWebhook(allow), readFile(t, "ses_bounce.json"), http.StatusOK, 1},
+		{p.SESWebhook(allow), []byte(`{"Type":"SubscriptionConfirmation"}`), http.StatusOK, 0},
+		{p.SESWebhook(allow), []byte(`[]`), http.StatusBadRequest, 0},
+		// the webhooks fail closed
+		{p.SendGridWebhook(nil), readFile(t, "sendgrid.json"), http.StatusUnauthorized, 0},
+		{p.SESWebhook(nil), readFile(t, "ses_bounce.json"), http.StatusUnauthorized, 0},
+		{p.SESWebhook(deny), readFile(t, "ses_bounce.json"), http.StatusUnauthorized, 0},
+	} {
+		events = []Event{}
+		w := httptest.NewRecorder()
+		c.handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(c.payload)))
+		require.Equal(t, c.status, w.Code)
+		require.Len(t, events, c.events)
+	}
+}
+
+func TestSNSVerifier(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(t, err)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	}))
+	defer server.Close()
+
+	topic := "arn:aws:sns:us-east-1:123456789012:mauth-bounces"
+	verifier, err := NewSNSVerifier(SNSParams{
+		TopicARNs: []string{topic},
+		CertHost:  regexp.MustCompile(`^127\.0\.0\.1:\d+$`),
+		Client:    server.Client(),
+	})
+	require.Nil(t, err)
+
+	sign := func(msg snsMessage) []byte {
+		digest := sha256.Sum256([]byte(msg.stringToSign()))
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		require.Nil(t, err)
+		msg.Signature = base64.StdEncoding.EncodeToString(signature)
+		payload, err := json.Marshal(msg)
+		require.Nil(t, err)
+		return payload
+	}
+	msg := snsMessage{}
+	require.Nil(t, json.Unmarshal(readFile(t, "ses_bounce.json"), &msg))
+	msg.SignatureVersion = "2"
+	msg.SigningCertURL = server.URL + "/SimpleNotificationService.pem"
+
+	events := []Event{}
+	p := NewProcessor(func(ctx context.Context, event Event) error {
+		events = append(events, event)
+		return nil
+	})
+	post := func(payload []byte) int {
+		w := httptest.NewRecorder()
+		p.SESWebhook(verifier).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload)))
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusOK, post(sign(msg)))
+	require.Len(t, events, 1)
+
+	// tampered message
+	tampered := snsMessage{}
+	require.Nil(t, json.Unmarshal(sign(msg), &tampered))
+	tampered.Message = strings.Replace(tampered.Message, "unknown@example.com", "victim@example.com", 1)
+	payload, err := json.Marshal(tampered)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusUnauthorized, post(payload))
+
+	// unexpected topic
+	other := msg
+	other.TopicArn = "arn:aws:sns:us-east-1:999999999999:attacker"
+	require.Equal(t, http.StatusUnauthorized, post(sign(other)))
+
+	// certificate outside of the expected hosts
+	other = msg
+	other.SigningCertURL = "https://attacker.example.com/cert.pem"
+	require.Equal(t, http.StatusUnauthorized, post(sign(other)))
+
+	// unsigned raw SES notification
+	require.Equal(t, http.StatusUnauthorized, post([]byte(msg.Message)))
+	require.Len(t, events, 1)
+
+	_, err = NewSNSVerifier(SNSParams{})
+	require.Equal(t, ErrNoTopic, err)
+	require.True(t, DefaultSNSCertHost.MatchString("sns.eu-west-1.amazonaws.com"))
+	require.False(t, DefaultSNSCertHost.MatchString("sns.eu-west-1.amazonaws.com.attacker.com"))
+}
+
+func TestSendGridVerifier(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.Nil(t, err)
+	verifier, err := NewSendGridVerifier(SendGridParams{
+		PublicKey: base64.StdEncoding.EncodeToString(der),
+		MaxAge:    10 * time.Minute,
+	})
+	require.Nil(t, err)
+
+	payload := readFile(t, "sendgrid.json")
+	request := func(timestamp time.Time, body []byte) *http.Request {
+		ts := strconv.FormatInt(timestamp.Unix(), 10)
+		digest := sha256.Sum256(append([]byte(ts), payload...))
+		signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		require.Nil(t, err)
+
+		r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		r.Header.Set(SendGridTimestampHeader, ts)
+		r.Header.Set(SendGridSignatureHeader, base64.StdEncoding.EncodeToString(signature))
+		return r
+	}
+
+	handler := NewProcessor().SendGridWebhook(verifier)
+	for _, c := range []struct {
+		request *http.Request
+		status  int
+	}{
+		{request(time.Now(), payload), http.StatusOK},
+		{request(time.Now(), []byte(`[{"email":"victim@example.com","event":"bounce"}]`)), http.StatusUnauthorized},
+		{request(time.Now().Add(-time.Hour), payload), http.StatusUnauthorized},
+		{httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload)), http.StatusUnauthorized},
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, c.request)
+		require.Equal(t, c.status, w.Code)
+	}
+
+	_, err = NewSendGridVerifier(SendGridParams{PublicKey: "not a key"})
+	require.Equal(t, ErrInvalidKey, err)
+}
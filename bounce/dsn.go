@@ -0,0 +1,137 @@
+package bounce
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// ParseDSN parses a delivery status notification (RFC 3464) and returns an event for each failed or delayed
+// recipient. The successful recipients are ignored.
+func ParseDSN(r io.Reader) ([]Event, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	when, err := msg.Header.Date()
+	if err != nil {
+		when = time.Now()
+	}
+
+	status, err := findDeliveryStatus(msg.Header.Get("Content-Type"), msg.Body)
+	if err != nil {
+		return nil, err
+	}
+	return parseDeliveryStatus(status, when)
+}
+
+// findDeliveryStatus walks the MIME tree and returns the content of the delivery-status part.
+func findDeliveryStatus(contentType string, body io.Reader) ([]byte, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, ErrNotDSN
+	}
+
+	switch {
+	case mediaType == "message/delivery-status" || mediaType == "message/global-delivery-status":
+		return ioutil.ReadAll(body)
+
+	case strings.HasPrefix(mediaType, "multipart/"):
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return nil, ErrNotDSN
+			} else if err != nil {
+				return nil, err
+			}
+			partType := part.Header.Get("Content-Type")
+			if partType == "" {
+				continue
+			}
+			if res, err := findDeliveryStatus(partType, part); err == nil {
+				return res, nil
+			} else if err != ErrNotDSN {
+				return nil, err
+			}
+		}
+	}
+	return nil, ErrNotDSN
+}
+
+// parseDeliveryStatus parses the per-message fields, followed by a group of fields per recipient.
+func parseDeliveryStatus(status []byte, when time.Time) ([]Event, error) {
+	reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(bytes.TrimLeft(status, "\r\n"))))
+	if _, err := reader.ReadMIMEHeader(); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	res := []Event{}
+	for {
+		fields, err := reader.ReadMIMEHeader()
+		if len(fields) > 0 {
+			if event, ok := recipientEvent(fields, when); ok {
+				res = append(res, event)
+			}
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func recipientEvent(fields textproto.MIMEHeader, when time.Time) (Event, bool) {
+	recipient := addressField(fields.Get("Final-Recipient"))
+	if recipient == "" {
+		recipient = addressField(fields.Get("Original-Recipient"))
+	}
+	if recipient == "" {
+		return Event{}, false
+	}
+
+	event := Event{
+		Recipient:  recipient,
+		Status:     strings.TrimSpace(strings.SplitN(fields.Get("Status"), " ", 2)[0]),
+		Diagnostic: typedField(fields.Get("Diagnostic-Code")),
+		Source:     SourceDSN,
+		Time:       when,
+	}
+
+	switch strings.ToLower(strings.TrimSpace(fields.Get("Action"))) {
+	case "failed":
+		event.Type = TypeHard
+		if strings.HasPrefix(event.Status, "4.") {
+			event.Type = TypeSoft
+		}
+	case "delayed":
+		event.Type = TypeSoft
+	default:
+		// delivered, relayed or expanded
+		return Event{}, false
+	}
+	return event, true
+}
+
+// typedField removes the type of a field value, ie: "smtp; 550 user unknown" gives "550 user unknown".
+func typedField(value string) string {
+	parts := strings.SplitN(value, ";", 2)
+	if len(parts) != 2 {
+		return strings.TrimSpace(value)
+	}
+	return strings.TrimSpace(parts[1])
+}
+
+func addressField(value string) string {
+	return strings.Trim(typedField(value), "<>")
+}
@@ -0,0 +1,45 @@
+package bounce
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type (
+	Type string
+
+	// Event is a delivery failure, or a complaint, for a recipient.
+	Event struct {
+		Recipient string
+		Type      Type
+		// Status is the enhanced status code (RFC 3463), ie: "5.1.1".
+		Status     string
+		Diagnostic string
+		// Source is the format the event was parsed from: SourceDSN, SourceSES or SourceSendGrid.
+		Source string
+		Time   time.Time
+	}
+
+	// Handler is called with each parsed event.
+	Handler func(ctx context.Context, event Event) error
+)
+
+const (
+	// TypeHard is a permanent failure, the address should not be used anymore.
+	TypeHard Type = "hard"
+	// TypeSoft is a temporary failure, like a full mailbox.
+	TypeSoft Type = "soft"
+	// TypeComplaint is a recipient marking the message as spam.
+	TypeComplaint Type = "complaint"
+
+	SourceDSN      = "dsn"
+	SourceSES      = "ses"
+	SourceSendGrid = "sendgrid"
+)
+
+var (
+	ErrNotDSN          = errors.New("message is not a delivery status notification")
+	ErrInvalidPayload  = errors.New("invalid bounce payload")
+	ErrUnsupportedType = errors.New("unsupported notification type")
+)
@@ -0,0 +1,141 @@
+package bounce
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+type (
+	snsEnvelope struct {
+		Type    string
+		Message string
+	}
+
+	sesRecipient struct {
+		EmailAddress   string `json:"emailAddress"`
+		Status         string `json:"status"`
+		DiagnosticCode string `json:"diagnosticCode"`
+	}
+
+	sesNotification struct {
+		NotificationType string `json:"notificationType"`
+		EventType        string `json:"eventType"`
+		Bounce           *struct {
+			BounceType        string         `json:"bounceType"`
+			BouncedRecipients []sesRecipient `json:"bouncedRecipients"`
+			Timestamp         time.Time      `json:"timestamp"`
+		} `json:"bounce"`
+		Complaint *struct {
+			ComplainedRecipients []sesRecipient `json:"complainedRecipients"`
+			Timestamp            time.Time      `json:"timestamp"`
+		} `json:"complaint"`
+	}
+
+	sendGridEvent struct {
+		Email     string `json:"email"`
+		Event     string `json:"event"`
+		Type      string `json:"type"`
+		Status    string `json:"status"`
+		Reason    string `json:"reason"`
+		Response  string `json:"response"`
+		Timestamp int64  `json:"timestamp"`
+	}
+)
+
+// ParseSES parses an Amazon SES bounce or complaint notification, either raw or wrapped in an SNS message. Delivery
+// notifications give no event.
+func ParseSES(payload []byte) ([]Event, error) {
+	envelope := snsEnvelope{}
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return nil, ErrInvalidPayload
+	}
+	if envelope.Type != "" {
+		if envelope.Type != "Notification" {
+			// ie: SubscriptionConfirmation, must be confirmed by visiting its SubscribeURL
+			return nil, ErrUnsupportedType
+		}
+		payload = []byte(envelope.Message)
+	}
+
+	notification := sesNotification{}
+	if err := json.Unmarshal(payload, &notification); err != nil {
+		return nil, ErrInvalidPayload
+	}
+
+	kind := notification.NotificationType
+	if kind == "" {
+		kind = notification.EventType
+	}
+
+	res := []Event{}
+	switch {
+	case kind == "Bounce" && notification.Bounce != nil:
+		eventType := TypeSoft
+		if notification.Bounce.BounceType == "Permanent" {
+			eventType = TypeHard
+		}
+		for _, r := range notification.Bounce.BouncedRecipients {
+			res = append(res, Event{
+				Recipient:  r.EmailAddress,
+				Type:       eventType,
+				Status:     r.Status,
+				Diagnostic: typedField(r.DiagnosticCode),
+				Source:     SourceSES,
+				Time:       notification.Bounce.Timestamp,
+			})
+		}
+
+	case kind == "Complaint" && notification.Complaint != nil:
+		for _, r := range notification.Complaint.ComplainedRecipients {
+			res = append(res, Event{
+				Recipient: r.EmailAddress,
+				Type:      TypeComplaint,
+				Source:    SourceSES,
+				Time:      notification.Complaint.Timestamp,
+			})
+		}
+
+	case kind == "":
+		return nil, ErrInvalidPayload
+	}
+	return res, nil
+}
+
+// ParseSendGrid parses a batch of SendGrid event webhook events. Only the bounce, deferred and spam report events are
+// returned.
+func ParseSendGrid(payload []byte) ([]Event, error) {
+	events := []sendGridEvent{}
+	if err := json.Unmarshal(payload, &events); err != nil {
+		return nil, ErrInvalidPayload
+	}
+
+	res := []Event{}
+	for _, e := range events {
+		event := Event{
+			Recipient: e.Email,
+			Status:    e.Status,
+			Source:    SourceSendGrid,
+			Time:      time.Unix(e.Timestamp, 0),
+		}
+
+		switch e.Event {
+		case "bounce":
+			event.Diagnostic = e.Reason
+			event.Type = TypeHard
+			// "blocked" bounces are temporary rejections, ie: the ip is listed
+			if e.Type == "blocked" || strings.HasPrefix(e.Status, "4.") {
+				event.Type = TypeSoft
+			}
+		case "deferred":
+			event.Diagnostic = e.Response
+			event.Type = TypeSoft
+		case "spamreport":
+			event.Type = TypeComplaint
+		default:
+			continue
+		}
+		res = append(res, event)
+	}
+	return res, nil
+}
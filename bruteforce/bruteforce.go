@@ -0,0 +1,208 @@
+// Package bruteforce protects the token validation against guessing. Failed validations are counted per client IP
+// and per token prefix: after each failure the key must wait for an exponentially growing delay before the next
+// attempt, and after too many failures it is locked out for a while. The validations without a client IP are
+// refused, since they can't be counted.
+package bruteforce
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/fdelbos/mauth/ratelimit"
+)
+
+type (
+	Params struct {
+		// Store defaults to an in memory store.
+		Store Store
+		// MaxFailures is the number of failures locking out a key, defaults to 10.
+		MaxFailures int
+		// LockoutDuration defaults to 15 minutes.
+		LockoutDuration time.Duration
+		// BaseDelay is the delay required after the first failure, it doubles with each failure. Defaults to 1
+		// second.
+		BaseDelay time.Duration
+		// MaxDelay caps the delay between two attempts, defaults to 1 minute.
+		MaxDelay time.Duration
+		// Window is the time after which the failures of a key are forgotten, defaults to 1 hour.
+		Window time.Duration
+		// PrefixLength is the number of characters of the token used to count the failures per token, so that the
+		// guessers using many IPs are limited too. 0 disables it. Only enable it when the beginning of the tokens is
+		// random (ie: encrypted HMAC tokens), otherwise all the tokens share the same prefix.
+		PrefixLength int
+		// TrustedProxies is the number of proxies in front of the service setting the X-Forwarded-For header, see
+		// ratelimit.ClientIP.
+		TrustedProxies int
+		// OnLockout is called when a key gets locked out, for alerting.
+		OnLockout func(Event)
+	}
+
+	Guard struct {
+		store           Store
+		maxFailures     int
+		lockoutDuration time.Duration
+		baseDelay       time.Duration
+		maxDelay        time.Duration
+		window          time.Duration
+		prefixLength    int
+		trustedProxies  int
+		onLockout       func(Event)
+		now             func() time.Time
+	}
+)
+
+const (
+	DefaultMaxFailures     = 10
+	DefaultLockoutDuration = 15 * time.Minute
+	DefaultBaseDelay       = time.Second
+	DefaultMaxDelay        = time.Minute
+	DefaultWindow          = time.Hour
+)
+
+func NewGuard(params Params) *Guard {
+	g := &Guard{
+		store:           params.Store,
+		maxFailures:     params.MaxFailures,
+		lockoutDuration: params.LockoutDuration,
+		baseDelay:       params.BaseDelay,
+		maxDelay:        params.MaxDelay,
+		window:          params.Window,
+		prefixLength:    params.PrefixLength,
+		trustedProxies:  params.TrustedProxies,
+		onLockout:       params.OnLockout,
+		now:             time.Now,
+	}
+	if g.store == nil {
+		g.store = NewMemory()
+	}
+	if g.maxFailures <= 0 {
+		g.maxFailures = DefaultMaxFailures
+	}
+	if g.lockoutDuration <= 0 {
+		g.lockoutDuration = DefaultLockoutDuration
+	}
+	if g.baseDelay <= 0 {
+		g.baseDelay = DefaultBaseDelay
+	}
+	if g.maxDelay <= 0 {
+		g.maxDelay = DefaultMaxDelay
+	}
+	if g.window <= 0 {
+		g.window = DefaultWindow
+	}
+	return g
+}
+
+// ClientIP returns the IP address of the client that made the request.
+func (g *Guard) ClientIP(r *http.Request) string {
+	return ratelimit.ClientIP(r, g.trustedProxies)
+}
+
+type key struct {
+	scope string
+	value string
+}
+
+func (g *Guard) keys(ip, token string) []key {
+	res := []key{{ScopeIP, ip}}
+	if g.prefixLength > 0 && len(token) >= g.prefixLength {
+		res = append(res, key{ScopeToken, token[:g.prefixLength]})
+	}
+	return res
+}
+
+// Attempt reserves a validation for the ip and the token prefix: it is counted as a failure right away, so that the
+// concurrent attempts wait for the delays, and Success must be called when the token is valid. It returns an
+// *ErrTooManyAttempts error if a key must wait before the next validation, and ErrNoClientIP without ip.
+func (g *Guard) Attempt(ctx context.Context, ip, token string) error {
+	if ip == "" {
+		return ErrNoClientIP
+	}
+
+	keys := g.keys(ip, token)
+	for i, k := range keys {
+		if err := g.reserve(ctx, k); err != nil {
+			// the attempt is not made, give back the keys already reserved
+			for _, reserved := range keys[:i] {
+				g.release(ctx, reserved)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Success gives back the attempt reserved for the ip and the token prefix, the token was valid.
+func (g *Guard) Success(ctx context.Context, ip, token string) error {
+	if ip == "" {
+		return ErrNoClientIP
+	}
+	for _, k := range g.keys(ip, token) {
+		if err := g.release(ctx, k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *Guard) reserve(ctx context.Context, k key) error {
+	now := g.now()
+	var refused *ErrTooManyAttempts
+	locked := false
+	state, err := g.store.Update(ctx, k.scope+":"+k.value, g.window+g.lockoutDuration, func(s *State) {
+		if now.Before(s.BlockedUntil) {
+			refused = &ErrTooManyAttempts{Scope: k.scope, RetryAfter: s.BlockedUntil.Sub(now)}
+			return
+		}
+		s.Failures++
+		if s.Failures >= g.maxFailures {
+			locked = true
+			s.BlockedUntil = now.Add(g.lockoutDuration)
+		} else {
+			s.BlockedUntil = now.Add(g.delay(s.Failures))
+		}
+	})
+	if err != nil {
+		return err
+	} else if refused != nil {
+		return refused
+	}
+
+	if locked && g.onLockout != nil {
+		g.onLockout(Event{
+			Scope:    k.scope,
+			Key:      k.value,
+			Failures: state.Failures,
+			Until:    state.BlockedUntil,
+		})
+	}
+	return nil
+}
+
+func (g *Guard) release(ctx context.Context, k key) error {
+	now := g.now()
+	_, err := g.store.Update(ctx, k.scope+":"+k.value, g.window+g.lockoutDuration, func(s *State) {
+		if s.Failures > 0 {
+			s.Failures--
+		}
+		switch {
+		case s.Failures == 0:
+			s.BlockedUntil = time.Time{}
+		case s.Failures < g.maxFailures:
+			s.BlockedUntil = now.Add(g.delay(s.Failures))
+		}
+	})
+	return err
+}
+
+func (g *Guard) delay(failures int) time.Duration {
+	res := g.baseDelay
+	for i := 1; i < failures && res < g.maxDelay; i++ {
+		res *= 2
+	}
+	if res > g.maxDelay {
+		res = g.maxDelay
+	}
+	return res
+}
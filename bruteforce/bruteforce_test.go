@@ -0,0 +1,135 @@
+package bruteforce
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestGuard(params Params) (*Guard, *time.Time) {
+	now := time.Now()
+	store := NewMemory()
+	store.now = func() time.Time { return now }
+	params.Store = store
+
+	g := NewGuard(params)
+	g.now = func() time.Time { return now }
+	return g, &now
+}
+
+func TestExponentialDelay(t *testing.T) {
+	g, now := newTestGuard(Params{BaseDelay: time.Second, MaxDelay: 4 * time.Second})
+	ctx := context.Background()
+
+	for _, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		require.Nil(t, g.Attempt(ctx, "10.0.0.1", ""))
+
+		err := g.Attempt(ctx, "10.0.0.1", "")
+		tooMany := &ErrTooManyAttempts{}
+		require.True(t, errors.As(err, &tooMany))
+		require.Equal(t, ScopeIP, tooMany.Scope)
+		require.Equal(t, expected, tooMany.RetryAfter)
+
+		// another ip is not affected
+		require.Nil(t, g.Attempt(ctx, "10.0.0.2", ""))
+		require.Nil(t, g.Success(ctx, "10.0.0.2", ""))
+
+		*now = now.Add(expected)
+	}
+}
+
+func TestLockout(t *testing.T) {
+	events := []Event{}
+	g, now := newTestGuard(Params{
+		MaxFailures:     3,
+		LockoutDuration: time.Hour,
+		BaseDelay:       time.Millisecond,
+		OnLockout:       func(e Event) { events = append(events, e) },
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		*now = now.Add(time.Second)
+		require.Nil(t, g.Attempt(ctx, "10.0.0.1", ""))
+	}
+
+	err := g.Attempt(ctx, "10.0.0.1", "")
+	tooMany := &ErrTooManyAttempts{}
+	require.True(t, errors.As(err, &tooMany))
+	require.Equal(t, ScopeIP, tooMany.Scope)
+	require.Equal(t, time.Hour, tooMany.RetryAfter)
+
+	require.Len(t, events, 1)
+	require.Equal(t, Event{Scope: ScopeIP, Key: "10.0.0.1", Failures: 3, Until: now.Add(time.Hour)}, events[0])
+
+	// the lockout ends
+	*now = now.Add(time.Hour)
+	require.Nil(t, g.Attempt(ctx, "10.0.0.1", ""))
+}
+
+func TestSuccess(t *testing.T) {
+	g, _ := newTestGuard(Params{BaseDelay: time.Second})
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		require.Nil(t, g.Attempt(ctx, "10.0.0.1", ""))
+		require.Nil(t, g.Success(ctx, "10.0.0.1", ""))
+	}
+
+	// the failures before a success still count
+	require.Nil(t, g.Attempt(ctx, "10.0.0.1", ""))
+	require.Nil(t, g.Attempt(ctx, "10.0.0.2", ""))
+	require.Nil(t, g.Success(ctx, "10.0.0.2", ""))
+	err := g.Attempt(ctx, "10.0.0.1", "")
+	tooMany := &ErrTooManyAttempts{}
+	require.True(t, errors.As(err, &tooMany))
+	require.Equal(t, time.Second, tooMany.RetryAfter)
+}
+
+func TestConcurrentAttempts(t *testing.T) {
+	g, _ := newTestGuard(Params{BaseDelay: time.Second})
+	ctx := context.Background()
+
+	// the attempts are reserved, only one of the parallel guesses is made
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		go func() { errs <- g.Attempt(ctx, "10.0.0.1", "") }()
+	}
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if err := <-errs; err == nil {
+			allowed++
+		}
+	}
+	require.Equal(t, 1, allowed)
+}
+
+func TestTokenPrefix(t *testing.T) {
+	g, _ := newTestGuard(Params{BaseDelay: time.Second, PrefixLength: 4})
+	ctx := context.Background()
+
+	require.Nil(t, g.Attempt(ctx, "10.0.0.1", "abcd1234"))
+
+	// the same prefix from another ip must wait
+	err := g.Attempt(ctx, "10.0.0.2", "abcd5678")
+	tooMany := &ErrTooManyAttempts{}
+	require.True(t, errors.As(err, &tooMany))
+	require.Equal(t, ScopeToken, tooMany.Scope)
+
+	// the refused attempt is not counted for its ip
+	require.Nil(t, g.Attempt(ctx, "10.0.0.2", "efgh5678"))
+
+	// the tokens shorter than the prefix are only counted per ip
+	require.Nil(t, g.Attempt(ctx, "10.0.0.3", "ab"))
+}
+
+func TestNoClientIP(t *testing.T) {
+	g, _ := newTestGuard(Params{})
+	ctx := context.Background()
+
+	require.Equal(t, ErrNoClientIP, g.Attempt(ctx, "", "token"))
+	require.Equal(t, ErrNoClientIP, g.Success(ctx, "", "token"))
+}
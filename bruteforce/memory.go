@@ -0,0 +1,73 @@
+package bruteforce
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type (
+	entry struct {
+		state   State
+		expires time.Time
+	}
+
+	// Memory is an in memory Store, the failures are local to the process.
+	Memory struct {
+		mu        sync.Mutex
+		entries   map[string]*entry
+		lastSweep time.Time
+		now       func() time.Time
+	}
+)
+
+const (
+	sweepInterval = time.Minute
+)
+
+func NewMemory() *Memory {
+	return &Memory{
+		entries: map[string]*entry{},
+		now:     time.Now,
+	}
+}
+
+func (m *Memory) Get(ctx context.Context, key string) (State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok || m.now().After(e.expires) {
+		return State{}, nil
+	}
+	return e.state, nil
+}
+
+func (m *Memory) Update(ctx context.Context, key string, ttl time.Duration, fn func(*State)) (State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	e, ok := m.entries[key]
+	if !ok || now.After(e.expires) {
+		e = &entry{}
+		m.entries[key] = e
+	}
+	fn(&e.state)
+	e.expires = now.Add(ttl)
+	return e.state, nil
+}
+
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+	for key, e := range m.entries {
+		if now.After(e.expires) {
+			delete(m.entries, key)
+		}
+	}
+}
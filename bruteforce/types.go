@@ -0,0 +1,53 @@
+package bruteforce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type (
+	// State is the record of the failed validations for a key.
+	State struct {
+		Failures     int
+		BlockedUntil time.Time
+	}
+
+	// Store keeps the states of the keys.
+	Store interface {
+		Get(ctx context.Context, key string) (State, error)
+		// Update applies fn to the state of the key atomically, the state is forgotten after ttl without update.
+		Update(ctx context.Context, key string, ttl time.Duration, fn func(*State)) (State, error)
+	}
+
+	// Event describes a key being locked out.
+	Event struct {
+		// Scope is ScopeIP or ScopeToken, Key is the IP or the token prefix.
+		Scope    string
+		Key      string
+		Failures int
+		Until    time.Time
+	}
+
+	// ErrTooManyAttempts is returned when validations are refused after too many failures, RetryAfter is the time
+	// to wait before trying again.
+	ErrTooManyAttempts struct {
+		Scope      string
+		RetryAfter time.Duration
+	}
+)
+
+const (
+	ScopeIP    = "ip"
+	ScopeToken = "token"
+)
+
+var (
+	// ErrNoClientIP is returned for the validations without a client IP, they can't be counted.
+	ErrNoClientIP = errors.New("the client IP is required to validate a token")
+)
+
+func (e *ErrTooManyAttempts) Error() string {
+	return fmt.Sprintf("too many failed validations for this %s, retry after %s", e.Scope, e.RetryAfter)
+}
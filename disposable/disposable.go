@@ -0,0 +1,193 @@
+// Package disposable detects the addresses of disposable (throwaway) email domains.
+package disposable
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/idna"
+)
+
+type (
+	Params struct {
+		// SkipBundled doesn't load the bundled list, only the files and readers are used.
+		SkipBundled bool
+		// Files are lists with one domain per line, the empty lines and the lines starting with '#' are ignored.
+		Files []string
+	}
+
+	// Checker matches the domains of the lists and their subdomains. It is safe for concurrent use, and the
+	// lists can be reloaded while it is used.
+	Checker struct {
+		// loading serializes the loads, mu protects the fields
+		loading     sync.Mutex
+		mu          sync.RWMutex
+		skipBundled bool
+		files       []string
+		// loaded holds the domains of the readers, they are kept by Reload
+		loaded  map[string]struct{}
+		domains map[string]struct{}
+	}
+)
+
+var (
+	ErrDisposableAddress = errors.New("disposable email addresses are not allowed")
+)
+
+func NewChecker(params Params) (*Checker, error) {
+	res := &Checker{
+		skipBundled: params.SkipBundled,
+		files:       params.Files,
+		loaded:      map[string]struct{}{},
+	}
+	if err := res.Reload(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Reload rebuilds the list from the bundled list, the files and the domains previously loaded from readers. The
+// current list is kept if a file can't be read.
+func (c *Checker) Reload() error {
+	c.loading.Lock()
+	defer c.loading.Unlock()
+
+	c.mu.RLock()
+	files := c.files
+	domains := make(map[string]struct{}, len(bundled)+len(c.loaded))
+	for domain := range c.loaded {
+		domains[domain] = struct{}{}
+	}
+	c.mu.RUnlock()
+
+	if !c.skipBundled {
+		for _, domain := range bundled {
+			domains[domain] = struct{}{}
+		}
+	}
+	for _, path := range files {
+		if err := readFile(path, domains); err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	c.domains = domains
+	c.mu.Unlock()
+	return nil
+}
+
+// LoadFile adds the domains of the file, the file is read again by Reload.
+func (c *Checker) LoadFile(path string) error {
+	c.loading.Lock()
+	defer c.loading.Unlock()
+
+	domains := map[string]struct{}{}
+	if err := readFile(path, domains); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files = append(c.files, path)
+	c.add(domains)
+	return nil
+}
+
+// LoadReader adds the domains read from r, they are kept by Reload.
+func (c *Checker) LoadReader(r io.Reader) error {
+	c.loading.Lock()
+	defer c.loading.Unlock()
+
+	domains := map[string]struct{}{}
+	if err := read(r, domains); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for domain := range domains {
+		c.loaded[domain] = struct{}{}
+	}
+	c.add(domains)
+	return nil
+}
+
+// add copies the current domains so that the map is never modified after being published.
+func (c *Checker) add(domains map[string]struct{}) {
+	res := make(map[string]struct{}, len(c.domains)+len(domains))
+	for domain := range c.domains {
+		res[domain] = struct{}{}
+	}
+	for domain := range domains {
+		res[domain] = struct{}{}
+	}
+	c.domains = res
+}
+
+// IsDisposable returns true if the domain, or one of its parents, is in the list.
+func (c *Checker) IsDisposable(domain string) bool {
+	domain = normalize(domain)
+
+	c.mu.RLock()
+	domains := c.domains
+	c.mu.RUnlock()
+
+	for domain != "" {
+		if _, ok := domains[domain]; ok {
+			return true
+		}
+		i := strings.IndexByte(domain, '.')
+		if i < 0 {
+			break
+		}
+		domain = domain[i+1:]
+	}
+	return false
+}
+
+// Check returns ErrDisposableAddress if the domain of the address is disposable, it can be used as a
+// mauth.AddressPolicy.
+func (c *Checker) Check(ctx context.Context, address string) error {
+	at := strings.LastIndexByte(address, '@')
+	if at >= 0 && c.IsDisposable(address[at+1:]) {
+		return ErrDisposableAddress
+	}
+	return nil
+}
+
+func readFile(path string, domains map[string]struct{}) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return read(file, domains)
+}
+
+func read(r io.Reader, domains map[string]struct{}) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if domain := normalize(line); domain != "" {
+			domains[domain] = struct{}{}
+		}
+	}
+	return scanner.Err()
+}
+
+func normalize(domain string) string {
+	domain = strings.TrimSuffix(strings.TrimSpace(domain), ".")
+	if ascii, err := idna.Lookup.ToASCII(domain); err == nil {
+		return ascii
+	}
+	return strings.ToLower(domain)
+}
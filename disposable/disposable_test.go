@@ -0,0 +1,63 @@
+package disposable
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundled(t *testing.T) {
+	c, err := NewChecker(Params{})
+	require.NoError(t, err)
+
+	require.True(t, c.IsDisposable("mailinator.com"))
+	require.True(t, c.IsDisposable("MAILINATOR.com."))
+	require.True(t, c.IsDisposable("eu.mailinator.com"))
+	require.False(t, c.IsDisposable("notmailinator.com"))
+	require.False(t, c.IsDisposable("example.com"))
+
+	require.True(t, errors.Is(c.Check(nil, "john@yopmail.com"), ErrDisposableAddress))
+	require.NoError(t, c.Check(nil, "john@example.com"))
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "disposable")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "domains.txt")
+	require.NoError(t, ioutil.WriteFile(path, []byte("# our list\nthrowaway.test\n\n"), 0600))
+
+	c, err := NewChecker(Params{SkipBundled: true, Files: []string{path}})
+	require.NoError(t, err)
+	require.False(t, c.IsDisposable("mailinator.com"))
+	require.True(t, c.IsDisposable("throwaway.test"))
+
+	require.NoError(t, c.LoadReader(strings.NewReader("bücher.test\n")))
+	require.True(t, c.IsDisposable("xn--bcher-kva.test"))
+	require.True(t, c.IsDisposable("mail.bücher.test"))
+
+	// the file changes are applied by Reload, the reader domains are kept
+	require.NoError(t, ioutil.WriteFile(path, []byte("other.test\n"), 0600))
+	require.NoError(t, c.Reload())
+	require.False(t, c.IsDisposable("throwaway.test"))
+	require.True(t, c.IsDisposable("other.test"))
+	require.True(t, c.IsDisposable("bücher.test"))
+
+	path2 := filepath.Join(dir, "more.txt")
+	require.NoError(t, ioutil.WriteFile(path2, []byte("more.test\n"), 0600))
+	require.NoError(t, c.LoadFile(path2))
+	require.True(t, c.IsDisposable("more.test"))
+
+	// a missing file keeps the current list
+	require.NoError(t, os.Remove(path2))
+	require.Error(t, c.Reload())
+	require.True(t, c.IsDisposable("more.test"))
+
+	_, err = NewChecker(Params{Files: []string{path2}})
+	require.Error(t, err)
+}
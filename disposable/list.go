@@ -0,0 +1,111 @@
+package disposable
+
+// bundled is a list of well known disposable email domains, the subdomains are matched as well.
+var bundled = []string{
+	"0-mail.com",
+	"10minutemail.com",
+	"10minutemail.net",
+	"20minutemail.com",
+	"33mail.com",
+	"anonbox.net",
+	"binkmail.com",
+	"bobmail.info",
+	"burnermail.io",
+	"byom.de",
+	"chammy.info",
+	"deadaddress.com",
+	"discard.email",
+	"discardmail.com",
+	"discardmail.de",
+	"dispostable.com",
+	"dodgit.com",
+	"dropmail.me",
+	"e4ward.com",
+	"emailondeck.com",
+	"emailsensei.com",
+	"emailtemporanea.com",
+	"emailtemporario.com.br",
+	"fakeinbox.com",
+	"fakemail.net",
+	"fakemailgenerator.com",
+	"getairmail.com",
+	"getnada.com",
+	"guerrillamail.biz",
+	"guerrillamail.com",
+	"guerrillamail.de",
+	"guerrillamail.info",
+	"guerrillamail.net",
+	"guerrillamail.org",
+	"guerrillamailblock.com",
+	"harakirimail.com",
+	"incognitomail.org",
+	"inboxbear.com",
+	"jetable.org",
+	"kasmail.com",
+	"mailcatch.com",
+	"maildrop.cc",
+	"mailexpire.com",
+	"mailinator.com",
+	"mailinator.net",
+	"mailinator2.com",
+	"mailnesia.com",
+	"mailnull.com",
+	"mailpoof.com",
+	"mailsac.com",
+	"mailtemp.info",
+	"meltmail.com",
+	"mintemail.com",
+	"moakt.com",
+	"mohmal.com",
+	"mt2015.com",
+	"mytemp.email",
+	"mytrashmail.com",
+	"nada.email",
+	"no-spam.ws",
+	"nowmymail.com",
+	"nwytg.net",
+	"one-time.email",
+	"owlymail.com",
+	"pokemail.net",
+	"sharklasers.com",
+	"shieldemail.com",
+	"sogetthis.com",
+	"spam4.me",
+	"spambog.com",
+	"spambox.us",
+	"spamfree24.org",
+	"spamgourmet.com",
+	"spamex.com",
+	"spamherelots.com",
+	"spamhole.com",
+	"spaml.com",
+	"spammotel.com",
+	"tempail.com",
+	"tempemail.net",
+	"tempinbox.com",
+	"tempmail.dev",
+	"tempmail.net",
+	"tempmailo.com",
+	"tempmail.plus",
+	"temp-mail.io",
+	"temp-mail.org",
+	"tempomail.fr",
+	"temporaryemail.net",
+	"temporaryinbox.com",
+	"throwawaymail.com",
+	"tmail.ws",
+	"tmpmail.net",
+	"tmpmail.org",
+	"trash-mail.com",
+	"trashmail.com",
+	"trashmail.de",
+	"trashmail.me",
+	"trashmail.net",
+	"trbvm.com",
+	"wegwerfmail.de",
+	"wegwerfmail.net",
+	"yopmail.com",
+	"yopmail.fr",
+	"yopmail.net",
+	"zetmail.com",
+}
@@ -1,14 +1,23 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"time"
 
 	"github.com/fdelbos/mauth/generator"
 
+	"github.com/fdelbos/mauth/sender"
+	"github.com/fdelbos/mauth/sender/file"
+	"github.com/fdelbos/mauth/sender/queue"
 	"github.com/fdelbos/mauth/sender/smtp"
 	"github.com/fdelbos/mauth/templates/gotemplates"
+	"github.com/fdelbos/mauth/validator"
 
 	"github.com/fdelbos/mauth"
 	"github.com/fdelbos/mauth/generator/hmac"
@@ -32,10 +41,14 @@ const (
 )
 
 var (
-	auth *mauth.MAuth
+	auth   *mauth.MAuth
+	outbox *queue.Queue
+
+	// run with -dir ./mails to write the messages to a directory instead of using MailHog
+	outputDir = flag.String("dir", "", "write the emails to this directory instead of sending them")
 )
 
-func init() {
+func setup() {
 	// A generator creates the token, here using HMAC
 	generator, err := hmac.NewHMACB64("YRIXcOJ0wvjE14iJFz3eBCHj5qPzpq952LUcWlfPRDc=")
 	if err != nil {
@@ -43,11 +56,21 @@ func init() {
 	}
 
 	// the sender sends the message
-	sender, err := smtp.NewSMTP(smtp.Params{
-		Host: "localhost",
-		Port: 1025,
-		From: "test@example.com",
-	})
+	var sender sender.Sender
+	if *outputDir != "" {
+		// no mail server needed, the messages are written as .eml files and the links printed on stdout
+		sender, err = file.NewFile(file.Params{
+			Dir:   *outputDir,
+			From:  "test@example.com",
+			Links: os.Stdout,
+		})
+	} else {
+		sender, err = smtp.NewSMTP(smtp.Params{
+			Host: "localhost",
+			Port: 1025,
+			From: "test@example.com",
+		})
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -63,7 +86,13 @@ func init() {
 		`Suivez le lien pour vous connecter: {{ .URL }}`,
 		`Cliquez sur le lien pour vous connecter: <a href="{{ .URL }}">{{ .URL }}</a>`)
 
-	auth, err = mauth.NewMAuth(generator, sender, templates, baseURL)
+	// the queue sends the emails in the background, so that the login requests return right away
+	outbox, err = queue.NewQueue(sender, queue.Params{})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	auth, err = mauth.NewMAuth(generator, outbox, templates, baseURL)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -109,10 +138,15 @@ func sendEmail(w http.ResponseWriter, r *http.Request) {
 	}
 	email := r.PostFormValue("email")
 
-	// if you want to send the email async, use the background context instead and a go routine
+	// the email is only queued here, the request context only limits the time spent waiting for room in the queue
 	err = auth.Send(r.Context(), email)
 	if err != nil {
-		replyError(w, http.StatusInternalServerError)
+		if sender.IsPermanent(err) || errors.Is(err, validator.ErrInvalidAddress) {
+			// the address was rejected, asking again won't help
+			replyError(w, http.StatusBadRequest)
+		} else {
+			replyError(w, http.StatusInternalServerError)
+		}
 		return
 	}
 	w.Write([]byte("email sent to " + email))
@@ -136,6 +170,25 @@ func createCookieAndRedirect(w http.ResponseWriter, r *http.Request, token strin
 }
 
 func main() {
-	http.Handle("/", http.HandlerFunc(handler))
-	http.ListenAndServe(":8080", nil)
+	flag.Parse()
+	setup()
+
+	server := &http.Server{Addr: ":8080", Handler: http.HandlerFunc(handler)}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	<-stop
+
+	// stop serving requests, then give the queued emails some time to be sent
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+	if err := outbox.Close(ctx); err != nil {
+		log.Printf("some emails were not sent: %s", err)
+	}
 }
@@ -32,6 +32,8 @@ type (
 	Content struct {
 		E string
 		T int64
+		// O is the original address, it is omitted when it is the same as E.
+		O string
 	}
 )
 
@@ -103,11 +105,21 @@ func NewHMACWithEncryptionB64(b64Key, b64Block string) (*HMAC, error) {
 }
 
 func (h HMAC) Generate(ctx context.Context, email string, expiration time.Time) (string, error) {
+	return h.GenerateAddress(ctx, generator.Address{Canonical: email}, expiration)
+}
+
+func (h HMAC) GenerateAddress(ctx context.Context, address generator.Address, expiration time.Time) (string, error) {
+	original := address.Original
+	if original == address.Canonical {
+		original = ""
+	}
+
 	// 1 - gob marshal
 	buff := bytes.Buffer{}
 	err := gob.NewEncoder(&buff).Encode(Content{
-		E: email,
-		T: expiration.Unix()})
+		E: address.Canonical,
+		T: expiration.Unix(),
+		O: original})
 	if err != nil {
 		return "", err
 	}
@@ -125,31 +137,42 @@ func (h HMAC) Generate(ctx context.Context, email string, expiration time.Time)
 }
 
 func (h HMAC) Validate(ctx context.Context, token string) (string, error) {
+	address, err := h.ValidateAddress(ctx, token)
+	if err != nil {
+		return "", err
+	}
+	return address.Canonical, nil
+}
+
+func (h HMAC) ValidateAddress(ctx context.Context, token string) (*generator.Address, error) {
 	// 1 - check signature and base64 decode
 	content, err := h.Unsign(token)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// 2 - decrypt if block is set
 	if h.block != nil {
 		if content, err = h.Decrypt(content); err != nil {
 			log.Print("decrypt")
-			return "", err
+			return nil, err
 		}
 	}
 
 	// 3 - gob unmarshal
 	res := Content{}
 	if err := gob.NewDecoder(bytes.NewBuffer(content)).Decode(&res); err != nil {
-		return "", generator.ErrInvalid
+		return nil, generator.ErrInvalid
 
 	}
 
 	// 4 - check expiration time
 	expiration := time.Unix(res.T, 0)
 	if expiration.Before(time.Now()) {
-		return "", generator.ErrInvalid
+		return nil, generator.ErrInvalid
+	}
+	if res.O == "" {
+		res.O = res.E
 	}
-	return res.E, nil
+	return &generator.Address{Canonical: res.E, Original: res.O}, nil
 }
@@ -82,3 +82,25 @@ func (s *HMACSuite) TestExpired() {
 	s.Require().Equal(generator.ErrInvalid, err)
 	s.Require().Equal("", res)
 }
+
+func (s *HMACSuite) TestAddress() {
+	hmac, err := NewHMACWithEncryptionB64(b64Key32, b64Block16)
+	s.Require().Nil(err)
+	expiration := time.Now().Add(time.Minute)
+
+	token, err := hmac.GenerateAddress(nil, generator.Address{Canonical: email, Original: "Test@Example.com"}, expiration)
+	s.Require().Nil(err)
+	address, err := hmac.ValidateAddress(nil, token)
+	s.Require().Nil(err)
+	s.Require().Equal(&generator.Address{Canonical: email, Original: "Test@Example.com"}, address)
+	res, err := hmac.Validate(nil, token)
+	s.Require().Nil(err)
+	s.Require().Equal(email, res)
+
+	// without original address the canonical address is returned
+	token, err = hmac.Generate(nil, email, expiration)
+	s.Require().Nil(err)
+	address, err = hmac.ValidateAddress(nil, token)
+	s.Require().Nil(err)
+	s.Require().Equal(&generator.Address{Canonical: email, Original: email}, address)
+}
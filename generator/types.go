@@ -15,4 +15,19 @@ type (
 		Generate(ctx context.Context, email string, expiration time.Time) (string, error)
 		Validate(ctx context.Context, token string) (string, error)
 	}
+
+	// Address is an email address in its canonical form, with the form entered by the user.
+	Address struct {
+		Canonical string
+		Original  string
+	}
+
+	// AddressGenerator is implemented by the generators keeping the original address in the token.
+	AddressGenerator interface {
+		Generator
+		GenerateAddress(ctx context.Context, address Address, expiration time.Time) (string, error)
+		// ValidateAddress returns the address of the token, Original is the canonical address when the token
+		// was made without it.
+		ValidateAddress(ctx context.Context, token string) (*Address, error)
+	}
 )
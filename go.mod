@@ -1,10 +1,13 @@
 module github.com/fdelbos/mauth
 
-go 1.15
+go 1.16
 
 require (
 	github.com/dchest/uniuri v0.0.0-20200228104902-7aecb25e1fe5
+	github.com/go-redis/redis/v8 v8.4.4
+	github.com/mattn/go-sqlite3 v1.14.5
 	github.com/stretchr/testify v1.6.1
-	github.com/xhit/go-simple-mail/v2 v2.5.1
+	github.com/xhit/go-simple-mail/v2 v2.7.0
+	golang.org/x/net v0.0.0-20201202161906-c7110b5ffcbb
 	golang.org/x/text v0.3.4
 )
@@ -9,15 +9,43 @@ import (
 
 	"github.com/fdelbos/mauth/templates"
 
+	"github.com/fdelbos/mauth/bruteforce"
 	"github.com/fdelbos/mauth/generator"
+	"github.com/fdelbos/mauth/ratelimit"
 	"github.com/fdelbos/mauth/sender"
+	"github.com/fdelbos/mauth/suppression"
+	"github.com/fdelbos/mauth/validator"
 )
 
 type (
+	channel int
+
+	// ErrRateLimited is returned by the send functions when a limit of the RateLimiter is reached.
+	ErrRateLimited = ratelimit.ErrRateLimited
+
+	// ErrTooManyAttempts is returned by the validate functions when the Guard refuses a validation.
+	ErrTooManyAttempts = bruteforce.ErrTooManyAttempts
+
+	// Address is returned by the validate functions, with the canonical address the token was made for and the
+	// address entered by the user.
+	Address = generator.Address
+
+	// AddressNormalizer returns the canonical form of an address, see the normalizer package.
 	AddressNormalizer interface {
 		Normalize(string) string
 	}
 
+	// AddressValidator checks the email addresses before a token is minted, see the validator package.
+	AddressValidator interface {
+		Validate(ctx context.Context, address string) error
+	}
+
+	// AddressPolicy decides if an email address is authorized, it is checked after the domain lists (ie:
+	// disposable.Checker).
+	AddressPolicy interface {
+		Check(ctx context.Context, address string) error
+	}
+
 	MAuth struct {
 		Generator       generator.Generator
 		Sender          sender.Sender
@@ -28,18 +56,62 @@ type (
 		BaseURL         string
 		Param           string
 		Normalizer      AddressNormalizer
+		// Validator, when set, refuses the invalid email addresses before anything is generated or sent.
+		Validator AddressValidator
+		// Policies authorize the email addresses after the domain lists, they are checked in order and the first
+		// error refuses the address (ie: policy.Engine for wildcards and address rules).
+		Policies []AddressPolicy
+		// Channel defines the kind of addresses handled, either email addresses or phone numbers. The domain lists
+		// and the Policies only apply to the email addresses, they must be empty with the sms channel.
+		Channel channel
+		// DefaultCallingCode is used to accept national phone numbers with the sms channel (ie: "33" for France).
+		DefaultCallingCode string
+		// RateLimiter, when set, limits the messages sent per address, domain and client IP.
+		RateLimiter *ratelimit.Limiter
+		// Guard, when set, counts the failed validations per client IP and refuses the validations after too many
+		// failures. The client IP comes from the request with ValidateRequest, and from WithClientIP with Validate
+		// and ValidateAddress, which fail with ErrNoClientIP without it.
+		Guard *bruteforce.Guard
+		// Suppression, when set, is checked before sending to skip the addresses that bounced or complained, both
+		// the canonical and the original addresses are looked up. Give the store wrapped with suppression.Normalized
+		// to the senders and the bounce handlers, so that they record the canonical addresses.
+		Suppression suppression.Store
+		// TemplateData is given to the templates as .Data with every message (ie: the name of the product), the
+		// values given with WithData replace it.
+		TemplateData map[string]interface{}
 	}
 
+	// SendOption customizes a send call.
+	SendOption func(*sendOptions)
+
+	sendOptions struct {
+		data     map[string]interface{}
+		location *time.Location
+	}
+
+	clientIPKey struct{}
+
 	preparation struct {
-		email      string
-		expiration time.Time
-		url        string
+		// recipient is the address the message is sent to, the canonical address the token is made for
+		recipient string
+		params    templates.Params
 	}
 )
 
+const (
+	ChannelEmail channel = iota
+	ChannelSMS
+)
+
 var (
 	ErrInvalidBaseURL     = errors.New("only http or https url schemes are supported")
 	ErrBlacklistedAddress = errors.New("email address is blacklisted")
+	ErrSuppressedAddress  = errors.New("address is in the suppression list")
+	ErrNoSuppressionStore = errors.New("no suppression store is set")
+	ErrSMSAddressRules    = errors.New("the domain lists and the policies are not supported with the sms channel")
+
+	// ErrNoClientIP is returned by Validate and ValidateAddress when a Guard is set and the context has no client IP.
+	ErrNoClientIP = bruteforce.ErrNoClientIP
 )
 
 // NewMAuth creates new MAuth instance with reasonable defaults
@@ -63,59 +135,209 @@ func NewMAuth(generator generator.Generator, sender sender.Sender, templates tem
 		DomainBlackList: map[string]interface{}{"": nil},
 		BaseURL:         baseUrl,
 		Param:           "mauth_token",
+		Validator:       validator.NewSyntax(),
 	}, nil
 }
 
-func (m MAuth) Send(ctx context.Context, email string) error {
-	prep, err := m.prepare(ctx, email, m.DefaultDuration)
+// NewSMSMAuth creates new MAuth instance authenticating phone numbers, the sender and templates must be made for
+// text messages (ie: sms.SMS and smstemplates.SMSTemplates). The domain lists and the Policies must be left empty,
+// the send functions fail with ErrSMSAddressRules otherwise.
+func NewSMSMAuth(generator generator.Generator, sender sender.Sender, templates templates.Templates, baseUrl string) (*MAuth, error) {
+	res, err := NewMAuth(generator, sender, templates, baseUrl)
+	if err != nil {
+		return nil, err
+	}
+	res.Channel = ChannelSMS
+	res.Validator = nil
+	res.DomainBlackList = map[string]interface{}{}
+	return res, nil
+}
+
+func (m MAuth) Send(ctx context.Context, email string, options ...SendOption) error {
+	prep, err := m.prepare(ctx, email, "", m.DefaultDuration, options)
 	if err != nil {
 		return err
 	}
 
-	tr, err := m.Templates.Generate(prep.email, prep.url, prep.expiration)
+	tr, err := m.Templates.Generate(prep.params)
 	if err != nil {
 		return err
 	}
 
-	return m.Sender.Send(ctx, prep.email, tr.Subject, tr.TXT, tr.HTML)
+	return m.Sender.Send(ctx, prep.recipient, tr.Subject, tr.TXT, tr.HTML)
 }
 
-func (m MAuth) SendLocalized(ctx context.Context, lang string, email string) error {
-	prep, err := m.prepare(ctx, email, m.DefaultDuration)
+func (m MAuth) SendLocalized(ctx context.Context, lang string, email string, options ...SendOption) error {
+	prep, err := m.prepare(ctx, email, "", m.DefaultDuration, options)
 	if err != nil {
 		return err
 	}
 
-	tr, err := m.Templates.GenerateForLang(lang, prep.email, prep.url, prep.expiration)
+	tr, err := m.Templates.GenerateForLang(lang, prep.params)
 	if err != nil {
 		return err
 	}
 
-	return m.Sender.Send(ctx, prep.email, tr.Subject, tr.TXT, tr.HTML)
+	return m.Sender.Send(ctx, prep.recipient, tr.Subject, tr.TXT, tr.HTML)
 }
 
-func (m MAuth) SendLocalizedFromRequest(ctx context.Context, r *http.Request, email string) error {
-	prep, err := m.prepare(ctx, email, m.DefaultDuration)
+// SendLocalizedFromRequest sends the message in the language of the request, the IP address of the request is used
+// for the rate limiting.
+func (m MAuth) SendLocalizedFromRequest(ctx context.Context, r *http.Request, email string, options ...SendOption) error {
+	ip := ""
+	if m.RateLimiter != nil {
+		ip = m.RateLimiter.ClientIP(r)
+	}
+
+	prep, err := m.prepare(ctx, email, ip, m.DefaultDuration, options)
 	if err != nil {
 		return err
 	}
 
-	tr, err := m.Templates.GenerateForRequest(r, prep.email, prep.url, prep.expiration)
+	tr, err := m.Templates.GenerateForRequest(r, prep.params)
 	if err != nil {
 		return err
 	}
 
-	return m.Sender.Send(ctx, prep.email, tr.Subject, tr.TXT, tr.HTML)
+	return m.Sender.Send(ctx, prep.recipient, tr.Subject, tr.TXT, tr.HTML)
+}
+
+// WithData adds values to the .Data of the templates, they replace the values of MAuth.TemplateData with the same
+// keys.
+func WithData(data map[string]interface{}) SendOption {
+	return func(o *sendOptions) {
+		for key, value := range data {
+			o.data[key] = value
+		}
+	}
+}
+
+// WithLocation gives the time zone of the recipient, the expiration date given to the templates is in this time
+// zone.
+func WithLocation(location *time.Location) SendOption {
+	return func(o *sendOptions) {
+		o.location = location
+	}
+}
+
+func (m MAuth) sendOptions(options []SendOption) sendOptions {
+	o := sendOptions{data: map[string]interface{}{}}
+	for key, value := range m.TemplateData {
+		o.data[key] = value
+	}
+	for _, option := range options {
+		option(&o)
+	}
+	return o
+}
+
+// Suppress adds the address to the suppression list, no message will be sent to it anymore.
+func (m MAuth) Suppress(ctx context.Context, address, reason string) error {
+	if m.Suppression == nil {
+		return ErrNoSuppressionStore
+	}
+	if m.Normalizer != nil {
+		address = m.Normalizer.Normalize(address)
+	}
+	return m.Suppression.Add(ctx, suppression.Entry{
+		Address:   address,
+		Reason:    reason,
+		CreatedAt: time.Now(),
+	})
 }
 
+// Unsuppress removes the address from the suppression list.
+func (m MAuth) Unsuppress(ctx context.Context, address string) error {
+	if m.Suppression == nil {
+		return ErrNoSuppressionStore
+	}
+	if m.Normalizer != nil {
+		address = m.Normalizer.Normalize(address)
+	}
+	return m.Suppression.Remove(ctx, address)
+}
+
+// Validate returns the canonical address of the token. When a Guard is set, the IP of the client must be given with
+// WithClientIP, otherwise it fails with ErrNoClientIP.
 func (m MAuth) Validate(ctx context.Context, token string) (string, error) {
-	return m.Generator.Validate(ctx, token)
+	address, err := m.validate(ctx, token, clientIP(ctx))
+	if err != nil {
+		return "", err
+	}
+	return address.Canonical, nil
 }
 
+// ValidateAddress returns both the canonical and the original address of the token. The original address is only
+// kept by the generators implementing generator.AddressGenerator, otherwise it is the canonical address. The IP of the
+// client is given with WithClientIP, like Validate.
+func (m MAuth) ValidateAddress(ctx context.Context, token string) (*Address, error) {
+	return m.validate(ctx, token, clientIP(ctx))
+}
+
+// ValidateRequest validates the token of the request, the IP address of the request is used by the Guard.
 func (m MAuth) ValidateRequest(r *http.Request) (string, error) {
-	token := r.URL.Query().Get(m.Param)
+	address, err := m.ValidateRequestAddress(r)
+	if err != nil {
+		return "", err
+	}
+	return address.Canonical, nil
+}
+
+// ValidateRequestAddress is ValidateRequest returning both the canonical and the original address.
+func (m MAuth) ValidateRequestAddress(r *http.Request) (*Address, error) {
+	ip := ""
+	if m.Guard != nil {
+		ip = m.Guard.ClientIP(r)
+	}
+	return m.validate(r.Context(), r.URL.Query().Get(m.Param), ip)
+}
+
+func (m MAuth) validate(ctx context.Context, token, ip string) (*Address, error) {
+	if m.Guard != nil {
+		// the attempt is counted as a failure until the token is known to be valid
+		if err := m.Guard.Attempt(ctx, ip, token); err != nil {
+			return nil, err
+		}
+	}
+
 	if token == "" {
-		return "", generator.ErrInvalid
+		return nil, generator.ErrInvalid
+	}
+
+	var address *Address
+	var err error
+	if g, ok := m.Generator.(generator.AddressGenerator); ok {
+		address, err = g.ValidateAddress(ctx, token)
+	} else {
+		var email string
+		email, err = m.Generator.Validate(ctx, token)
+		address = &Address{Canonical: email, Original: email}
+	}
+	if errors.Is(err, generator.ErrInvalid) {
+		return nil, err
+	}
+	if m.Guard != nil {
+		// valid token or internal error, the attempt is not a guess
+		if guardErr := m.Guard.Success(ctx, ip, token); guardErr != nil && err == nil {
+			err = guardErr
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return address, nil
+}
+
+// WithClientIP returns a copy of ctx carrying the IP of the client, it is used by the Guard with Validate and
+// ValidateAddress.
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+func clientIP(ctx context.Context) string {
+	if ctx == nil {
+		return ""
 	}
-	return m.Generator.Validate(r.Context(), token)
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
 }
@@ -0,0 +1,381 @@
+package mauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/fdelbos/mauth/bounce"
+	"github.com/fdelbos/mauth/bruteforce"
+	"github.com/fdelbos/mauth/generator"
+	"github.com/fdelbos/mauth/generator/hmac"
+	"github.com/fdelbos/mauth/normalizer"
+	"github.com/fdelbos/mauth/policy"
+	"github.com/fdelbos/mauth/ratelimit"
+	"github.com/fdelbos/mauth/sender/sms"
+	"github.com/fdelbos/mauth/suppression"
+	"github.com/fdelbos/mauth/templates/gotemplates"
+	"github.com/fdelbos/mauth/templates/smstemplates"
+	"github.com/fdelbos/mauth/validator"
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	message struct {
+		address string
+		txt     string
+	}
+
+	recorder struct {
+		messages []message
+	}
+
+	// shortTokens keeps the tokens in memory, the links fit in a text message
+	shortTokens map[string]string
+)
+
+func (r *recorder) Send(ctx context.Context, address, subject string, txt, html []byte) error {
+	r.messages = append(r.messages, message{address: address, txt: string(txt)})
+	return nil
+}
+
+func (s shortTokens) Generate(ctx context.Context, email string, expiration time.Time) (string, error) {
+	token := fmt.Sprint(len(s) + 1)
+	s[token] = email
+	return token, nil
+}
+
+func (s shortTokens) Validate(ctx context.Context, token string) (string, error) {
+	email, ok := s[token]
+	if !ok {
+		return "", generator.ErrInvalid
+	}
+	return email, nil
+}
+
+func newTestMAuth(t *testing.T) (*MAuth, *recorder) {
+	gen, err := hmac.NewHMACB64("KKXrtvs26tG3L51nekkHhuzCULqHiSxKu3mXBPFmzgk=")
+	require.NoError(t, err)
+
+	tmpl := gotemplates.NewTemplates()
+	require.NoError(t, tmpl.Add("en", "login", "{{ .Original }} {{ .Email }} {{ .URL }}", ""))
+
+	rec := &recorder{}
+	auth, err := NewMAuth(gen, rec, tmpl, "https://example.com/login")
+	require.NoError(t, err)
+	return auth, rec
+}
+
+func TestPipeline(t *testing.T) {
+	auth, rec := newTestMAuth(t)
+	auth.Normalizer = normalizer.NewDefault()
+	engine, err := policy.NewEngine(policy.Params{
+		Rules:   []policy.Rule{{Action: policy.Allow, Pattern: "gmail.com"}},
+		Default: policy.Deny,
+	})
+	require.NoError(t, err)
+	auth.Policies = []AddressPolicy{engine}
+
+	// the policy is checked on the canonical address
+	require.NoError(t, auth.Send(nil, " John.Doe+news@GoogleMail.com "))
+	require.Len(t, rec.messages, 1)
+	msg := rec.messages[0]
+	require.Equal(t, "johndoe@gmail.com", msg.address)
+
+	var original, canonical, link string
+	_, err = fmt.Sscan(msg.txt, &original, &canonical, &link)
+	require.NoError(t, err)
+	require.Equal(t, "John.Doe+news@GoogleMail.com", original)
+	require.Equal(t, "johndoe@gmail.com", canonical)
+
+	parsed, err := url.Parse(link)
+	require.NoError(t, err)
+	token := parsed.Query().Get(auth.Param)
+	address, err := auth.ValidateAddress(nil, token)
+	require.NoError(t, err)
+	require.Equal(t, &Address{Canonical: "johndoe@gmail.com", Original: "John.Doe+news@GoogleMail.com"}, address)
+	email, err := auth.Validate(nil, token)
+	require.NoError(t, err)
+	require.Equal(t, "johndoe@gmail.com", email)
+
+	err = auth.Send(nil, "john@example.com")
+	require.True(t, errors.Is(err, policy.ErrDenied))
+	require.Len(t, rec.messages, 1)
+}
+
+func TestRecipient(t *testing.T) {
+	auth, rec := newTestMAuth(t)
+	auth.Normalizer = normalizer.NewDefault()
+
+	// the mailbox receiving the link is the one authenticated by the token
+	for _, address := range []string{"John.Doe+news@GoogleMail.com", " Mary@Example.com ", "mary-jane@yahoo.com"} {
+		rec.messages = nil
+		require.NoError(t, auth.Send(nil, address))
+		require.Len(t, rec.messages, 1)
+
+		var original, email, link string
+		_, err := fmt.Sscan(rec.messages[0].txt, &original, &email, &link)
+		require.NoError(t, err)
+		parsed, err := url.Parse(link)
+		require.NoError(t, err)
+		identity, err := auth.Validate(nil, parsed.Query().Get(auth.Param))
+		require.NoError(t, err)
+		require.Equal(t, rec.messages[0].address, identity, address)
+	}
+}
+
+func TestTrimmedAddress(t *testing.T) {
+	auth, rec := newTestMAuth(t)
+
+	// the trivial forms are accepted by the validator and the message is sent to the trimmed address
+	for _, address := range []string{" john@example.com ", "john@example.com.", "\tjohn@example.com..\n"} {
+		rec.messages = nil
+		require.NoError(t, auth.Send(nil, address))
+		require.Len(t, rec.messages, 1)
+		require.Equal(t, "john@example.com", rec.messages[0].address)
+
+		var original, email, link string
+		_, err := fmt.Sscan(rec.messages[0].txt, &original, &email, &link)
+		require.NoError(t, err)
+		require.Equal(t, "john@example.com", original)
+		require.Equal(t, "john@example.com", email)
+	}
+}
+
+func TestInvalidAddress(t *testing.T) {
+	auth, rec := newTestMAuth(t)
+
+	err := auth.Send(nil, "john..doe@example.com")
+	require.True(t, errors.Is(err, validator.ErrInvalidAddress))
+	require.Empty(t, rec.messages)
+}
+
+func TestDomainLists(t *testing.T) {
+	auth, rec := newTestMAuth(t)
+	auth.DomainWhitelist = map[string]interface{}{"example.com": nil}
+
+	require.NoError(t, auth.Send(nil, "john@EXAMPLE.com"))
+	require.Equal(t, ErrBlacklistedAddress, auth.Send(nil, "john@other.com"))
+
+	auth.DomainWhitelist = map[string]interface{}{}
+	auth.DomainBlackList = map[string]interface{}{"other.com": nil}
+	require.NoError(t, auth.Send(nil, "john@example.com"))
+	require.Equal(t, ErrBlacklistedAddress, auth.Send(nil, "john@other.com"))
+	require.Len(t, rec.messages, 2)
+}
+
+func TestSMS(t *testing.T) {
+	var to, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		to = r.FormValue("To")
+		body = r.FormValue("Body")
+		w.Write([]byte(`{"sid":"SM123"}`))
+	}))
+	defer server.Close()
+
+	s, err := sms.NewSMS(sms.Params{BaseURL: server.URL, AccountSID: "AC123", From: "+15005550006"})
+	require.NoError(t, err)
+	tmpl := smstemplates.NewTemplates()
+	require.NoError(t, tmpl.Add("en", "{{ .URL }}"))
+
+	auth, err := NewSMSMAuth(shortTokens{}, s, tmpl, "https://example.com/l")
+	require.NoError(t, err)
+	auth.DefaultCallingCode = "33"
+
+	// the national number is sent to and authenticated in the E.164 format
+	require.NoError(t, auth.Send(nil, "06 12 34 56 78"))
+	require.Equal(t, "+33612345678", to)
+	parsed, err := url.Parse(body)
+	require.NoError(t, err)
+	number, err := auth.Validate(nil, parsed.Query().Get(auth.Param))
+	require.NoError(t, err)
+	require.Equal(t, "+33612345678", number)
+
+	to = ""
+	err = auth.Send(nil, "not a number")
+	require.Error(t, err)
+	require.Empty(t, to)
+
+	// the rules made for the email addresses are refused
+	auth.DomainWhitelist = map[string]interface{}{"example.com": nil}
+	require.Equal(t, ErrSMSAddressRules, auth.Send(nil, "+33612345678"))
+	auth.DomainWhitelist = map[string]interface{}{}
+	engine, err := policy.NewEngine(policy.Params{})
+	require.NoError(t, err)
+	auth.Policies = []AddressPolicy{engine}
+	require.Equal(t, ErrSMSAddressRules, auth.Send(nil, "+33612345678"))
+	require.Empty(t, to)
+}
+
+func TestWhitelist(t *testing.T) {
+	auth, rec := newTestMAuth(t)
+
+	// regression: the whitelisted domains were looked up in the blacklist, refusing all of them
+	auth.DomainWhitelist = map[string]interface{}{"example.com": nil, "example.org": nil}
+	require.NoError(t, auth.Send(nil, "john@example.com"))
+	require.NoError(t, auth.Send(nil, "john@example.org"))
+	require.Equal(t, ErrBlacklistedAddress, auth.Send(nil, "john@example.net"))
+
+	// the blacklist still applies to the whitelisted domains
+	auth.DomainBlackList = map[string]interface{}{"example.org": nil}
+	require.Equal(t, ErrBlacklistedAddress, auth.Send(nil, "john@example.org"))
+	require.Len(t, rec.messages, 2)
+}
+
+func TestTemplateData(t *testing.T) {
+	auth, rec := newTestMAuth(t)
+	tmpl := gotemplates.NewTemplates()
+	require.NoError(t, tmpl.Add("en", "login", "{{ .Data.product }} {{ .Data.name }}", ""))
+	auth.Templates = tmpl
+	auth.TemplateData = map[string]interface{}{"product": "Example", "name": "you"}
+
+	require.NoError(t, auth.Send(nil, "john@example.com"))
+	require.NoError(t, auth.Send(nil, "john@example.com", WithData(map[string]interface{}{"name": "John"})))
+	require.Equal(t, "Example you", rec.messages[0].txt)
+	require.Equal(t, "Example John", rec.messages[1].txt)
+	require.Equal(t, "you", auth.TemplateData["name"])
+}
+
+func TestLocation(t *testing.T) {
+	auth, rec := newTestMAuth(t)
+	tmpl := gotemplates.NewTemplates()
+	require.NoError(t, tmpl.Add("en", "login", `{{ .Expiration.Location }}`, ""))
+	auth.Templates = tmpl
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	require.NoError(t, auth.Send(nil, "john@example.com", WithLocation(tokyo)))
+	require.Equal(t, "Asia/Tokyo", rec.messages[0].txt)
+}
+
+func TestSuppression(t *testing.T) {
+	auth, rec := newTestMAuth(t)
+	auth.Normalizer = normalizer.NewDefault()
+	require.Equal(t, ErrNoSuppressionStore, auth.Suppress(nil, "john@example.com", suppression.ReasonManual))
+	auth.Suppression = suppression.NewMemory()
+
+	// the suppression list holds the canonical addresses
+	require.NoError(t, auth.Suppress(nil, "John.Doe@GoogleMail.com", suppression.ReasonBounce))
+	require.Equal(t, ErrSuppressedAddress, auth.Send(nil, "johndoe+news@gmail.com"))
+	require.Empty(t, rec.messages)
+
+	require.NoError(t, auth.Unsuppress(nil, "johndoe@gmail.com"))
+	require.NoError(t, auth.Send(nil, "johndoe+news@gmail.com"))
+	require.Len(t, rec.messages, 1)
+}
+
+func TestSuppressionFeeders(t *testing.T) {
+	auth, rec := newTestMAuth(t)
+	auth.Normalizer = normalizer.NewDefault()
+	store := suppression.NewMemory()
+	auth.Suppression = store
+
+	// the bounce handler records the canonical form of the address reported by the provider
+	handler := bounce.SuppressionHandler(suppression.Normalized(store, auth.Normalizer))
+	require.NoError(t, handler(nil, bounce.Event{Recipient: "John.Doe@GoogleMail.com", Type: bounce.TypeHard}))
+	for _, address := range []string{"johndoe@gmail.com", "J.O.H.N.Doe+news@gmail.com", "John.Doe@GoogleMail.com"} {
+		require.Equal(t, ErrSuppressedAddress, auth.Send(nil, address), address)
+	}
+
+	// an entry recorded without normalization still matches the address entered by the user
+	require.NoError(t, store.Add(nil, suppression.Entry{Address: "Mary.Jane+news@gmail.com", Reason: suppression.ReasonBounce}))
+	require.Equal(t, ErrSuppressedAddress, auth.Send(nil, "mary.jane+news@gmail.com"))
+
+	require.Empty(t, rec.messages)
+}
+
+func TestRateLimit(t *testing.T) {
+	auth, rec := newTestMAuth(t)
+	auth.RateLimiter = &ratelimit.Limiter{
+		Store:      ratelimit.NewMemory(),
+		PerAddress: ratelimit.Rate{Limit: 2, Per: time.Hour},
+	}
+
+	require.NoError(t, auth.Send(nil, "john@example.com"))
+	require.NoError(t, auth.Send(nil, "john@example.com"))
+	err := auth.Send(nil, "john@example.com")
+	limited := &ErrRateLimited{}
+	require.True(t, errors.As(err, &limited))
+	require.Equal(t, ratelimit.ScopeAddress, limited.Scope)
+	require.Len(t, rec.messages, 2)
+}
+
+func TestRateLimitPerIP(t *testing.T) {
+	auth, rec := newTestMAuth(t)
+	auth.RateLimiter = &ratelimit.Limiter{
+		Store:          ratelimit.NewMemory(),
+		PerIP:          ratelimit.Rate{Limit: 1, Per: time.Hour},
+		TrustedProxies: 1,
+	}
+	request := func(forwardedFor string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/login", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("X-Forwarded-For", forwardedFor)
+		return r
+	}
+
+	require.NoError(t, auth.SendLocalizedFromRequest(nil, request("203.0.113.7"), "john@example.com"))
+
+	// another address from the same client, with a spoofed header
+	err := auth.SendLocalizedFromRequest(nil, request("1.2.3.4, 203.0.113.7"), "jane@example.com")
+	limited := &ErrRateLimited{}
+	require.True(t, errors.As(err, &limited))
+	require.Equal(t, ratelimit.ScopeIP, limited.Scope)
+
+	require.NoError(t, auth.SendLocalizedFromRequest(nil, request("203.0.113.8"), "jane@example.com"))
+	require.Len(t, rec.messages, 2)
+}
+
+func TestGuard(t *testing.T) {
+	auth, rec := newTestMAuth(t)
+	auth.Guard = bruteforce.NewGuard(bruteforce.Params{
+		MaxFailures:     3,
+		LockoutDuration: time.Hour,
+		BaseDelay:       time.Nanosecond,
+		MaxDelay:        time.Nanosecond,
+	})
+	require.NoError(t, auth.Send(nil, "john@example.com"))
+	var original, canonical, link string
+	_, err := fmt.Sscan(rec.messages[0].txt, &original, &canonical, &link)
+	require.NoError(t, err)
+
+	request := func(link, ip string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, link, nil)
+		r.RemoteAddr = ip + ":1234"
+		return r
+	}
+
+	// the validations without client IP can't be counted
+	parsed, err := url.Parse(link)
+	require.NoError(t, err)
+	_, err = auth.Validate(nil, parsed.Query().Get(auth.Param))
+	require.Equal(t, ErrNoClientIP, err)
+
+	// the client IP is given with the context, the valid tokens are not counted
+	ctx := WithClientIP(context.Background(), "10.0.0.3")
+	for i := 0; i < 5; i++ {
+		email, err := auth.Validate(ctx, parsed.Query().Get(auth.Param))
+		require.NoError(t, err)
+		require.Equal(t, "john@example.com", email)
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := auth.ValidateRequest(request("https://example.com/login?mauth_token=invalid", "10.0.0.1"))
+		require.Equal(t, generator.ErrInvalid, err)
+	}
+
+	// the client is locked out, even with a valid token
+	_, err = auth.ValidateRequest(request(link, "10.0.0.1"))
+	tooMany := &ErrTooManyAttempts{}
+	require.True(t, errors.As(err, &tooMany))
+	require.Equal(t, bruteforce.ScopeIP, tooMany.Scope)
+
+	email, err := auth.ValidateRequest(request(link, "10.0.0.2"))
+	require.NoError(t, err)
+	require.Equal(t, "john@example.com", email)
+}
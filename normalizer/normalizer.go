@@ -0,0 +1,185 @@
+// Package normalizer provides mauth.AddressNormalizer implementations, so that the variants of an email address
+// are stored, rate limited and suppressed as a single mailbox.
+package normalizer
+
+import (
+	"strings"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+type (
+	// Normalizer has the same method set as mauth.AddressNormalizer.
+	Normalizer interface {
+		Normalize(string) string
+	}
+
+	// Func is a function used as a Normalizer.
+	Func func(string) string
+
+	// Case lowers the domain, which is case insensitive (RFC 4343). The local part is case sensitive according to
+	// RFC 5321, but not with most providers: its ASCII letters are lowered when LowerLocal is set, and it is fully
+	// case folded when FoldLocal is set (ie: ß becomes ss, which can be another mailbox).
+	Case struct {
+		LowerLocal bool
+		FoldLocal  bool
+	}
+
+	// SubAddressing removes the tags of the local parts (ie: john+news@fastmail.com becomes john@fastmail.com),
+	// Separators maps the domains to the separator they use.
+	SubAddressing struct {
+		Separators map[string]string
+	}
+
+	chain []Normalizer
+)
+
+var (
+	// TrimSpace removes the spaces around the address.
+	TrimSpace = Func(strings.TrimSpace)
+
+	// NFC normalizes the unicode characters to their composed form, so that the same visible address is always
+	// encoded the same way.
+	NFC = Func(norm.NFC.String)
+
+	// TrimDots removes the trailing dots of the domain (ie: john@example.com. becomes john@example.com).
+	TrimDots = Func(trimDots)
+
+	// IDN converts the internationalized domains to their ASCII form (ie: bücher.de becomes xn--bcher-kva.de).
+	IDN = Func(idn)
+
+	// Gmail removes the dots and the tag of the local part of the Gmail addresses, which are ignored by Gmail, and
+	// replaces googlemail.com by gmail.com. The domain must be lower case.
+	Gmail = Func(gmail)
+
+	// DefaultSeparators are the sub-addressing separators of well known providers, Gmail is handled by Gmail. Only
+	// the documented tags are removed: the yahoo "-" addresses are disposable aliases, not tags of an account.
+	DefaultSeparators = map[string]string{
+		"outlook.com":    "+",
+		"hotmail.com":    "+",
+		"live.com":       "+",
+		"icloud.com":     "+",
+		"me.com":         "+",
+		"fastmail.com":   "+",
+		"protonmail.com": "+",
+		"proton.me":      "+",
+		"pm.me":          "+",
+		"zoho.com":       "+",
+		"yandex.com":     "+",
+		"yandex.ru":      "+",
+	}
+)
+
+// Chain returns a Normalizer applying the normalizers in order.
+func Chain(normalizers ...Normalizer) Normalizer {
+	return chain(normalizers)
+}
+
+// NewDefault returns the chain of all the normalizers of the package. It only merges the forms of an address that
+// reach the same mailbox: the ASCII letters of the local parts are lowered but they are not case folded.
+func NewDefault() Normalizer {
+	return Chain(
+		TrimSpace,
+		NFC,
+		TrimDots,
+		IDN,
+		Case{LowerLocal: true},
+		Gmail,
+		SubAddressing{Separators: DefaultSeparators},
+	)
+}
+
+func (c chain) Normalize(address string) string {
+	for _, n := range c {
+		address = n.Normalize(address)
+	}
+	return address
+}
+
+func (f Func) Normalize(address string) string {
+	return f(address)
+}
+
+func (c Case) Normalize(address string) string {
+	local, domain, ok := split(address)
+	if !ok {
+		return address
+	}
+	if !strings.HasPrefix(local, `"`) {
+		if c.FoldLocal {
+			local = cases.Fold().String(local)
+		} else if c.LowerLocal {
+			local = lowerASCII(local)
+		}
+	}
+	return local + "@" + strings.ToLower(domain)
+}
+
+func lowerASCII(s string) string {
+	res := []byte(s)
+	for i, c := range res {
+		if 'A' <= c && c <= 'Z' {
+			res[i] = c + 'a' - 'A'
+		}
+	}
+	return string(res)
+}
+
+func (s SubAddressing) Normalize(address string) string {
+	local, domain, ok := split(address)
+	if !ok {
+		return address
+	}
+	separator, ok := s.Separators[domain]
+	if !ok || separator == "" {
+		return address
+	}
+	return removeTag(local, separator) + "@" + domain
+}
+
+func trimDots(address string) string {
+	local, domain, ok := split(address)
+	if !ok {
+		return address
+	}
+	return local + "@" + strings.TrimRight(domain, ".")
+}
+
+func idn(address string) string {
+	local, domain, ok := split(address)
+	if !ok {
+		return address
+	}
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return address
+	}
+	return local + "@" + ascii
+}
+
+func gmail(address string) string {
+	local, domain, ok := split(address)
+	if !ok || (domain != "gmail.com" && domain != "googlemail.com") {
+		return address
+	}
+	local = removeTag(local, "+")
+	return strings.ReplaceAll(local, ".", "") + "@gmail.com"
+}
+
+// removeTag removes the separator and what follows, unless the separator is the first character.
+func removeTag(local, separator string) string {
+	if i := strings.Index(local, separator); i > 0 {
+		return local[:i]
+	}
+	return local
+}
+
+func split(address string) (string, string, bool) {
+	at := strings.LastIndexByte(address, '@')
+	if at < 0 {
+		return "", "", false
+	}
+	return address[:at], address[at+1:], true
+}
@@ -0,0 +1,52 @@
+package normalizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizers(t *testing.T) {
+	for _, test := range []struct {
+		normalizer Normalizer
+		in, out    string
+	}{
+		{TrimSpace, " john@example.com\t", "john@example.com"},
+		{NFC, "josé@example.com", "josé@example.com"},
+		{TrimDots, "john@example.com..", "john@example.com"},
+		{IDN, "john@Bücher.de", "john@xn--bcher-kva.de"},
+		{IDN, "john@invalid_domain", "john@invalid_domain"},
+		{Case{}, "John@EXAMPLE.com", "John@example.com"},
+		{Case{FoldLocal: true}, "John.STRAßE@EXAMPLE.com", "john.strasse@example.com"},
+		{Case{LowerLocal: true}, "John.STRAßE@EXAMPLE.com", "john.straße@example.com"},
+		{Case{LowerLocal: true}, "\u212Aelvin@example.com", "\u212Aelvin@example.com"},
+		{Case{FoldLocal: true}, `"John"@example.com`, `"John"@example.com`},
+		{Gmail, "j.o.h.n+news@gmail.com", "john@gmail.com"},
+		{Gmail, "john.doe@googlemail.com", "johndoe@gmail.com"},
+		{Gmail, "john.doe+news@example.com", "john.doe+news@example.com"},
+		{SubAddressing{Separators: DefaultSeparators}, "john+news@fastmail.com", "john@fastmail.com"},
+		{SubAddressing{Separators: DefaultSeparators}, "john-news@yahoo.com", "john-news@yahoo.com"},
+		{SubAddressing{Separators: DefaultSeparators}, "john-doe@outlook.com", "john-doe@outlook.com"},
+		{SubAddressing{Separators: DefaultSeparators}, "+john@fastmail.com", "+john@fastmail.com"},
+		{SubAddressing{Separators: map[string]string{"example.com": "-"}}, "john-a-b@example.com", "john@example.com"},
+		{Case{FoldLocal: true}, "not an address", "not an address"},
+	} {
+		require.Equal(t, test.out, test.normalizer.Normalize(test.in), test.in)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	n := NewDefault()
+	for _, address := range []string{
+		"johndoe@gmail.com",
+		" John.Doe@gmail.com ",
+		"j.o.h.n.d.o.e+signup@GoogleMail.com.",
+	} {
+		require.Equal(t, "johndoe@gmail.com", n.Normalize(address))
+	}
+	require.Equal(t, "john@xn--bcher-kva.de", n.Normalize("JOHN@BÜCHER.de"))
+
+	// the distinct mailboxes are not merged
+	require.Equal(t, "john-shop@yahoo.com", n.Normalize("John-shop@Yahoo.com"))
+	require.Equal(t, "straße@example.com", n.Normalize("STRAßE@example.com"))
+}
@@ -0,0 +1,63 @@
+// Package phone normalizes and validates phone numbers in the E.164 format (ie: +14155552671).
+package phone
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrInvalidNumber = errors.New("phone number is invalid")
+)
+
+const (
+	minDigits = 7
+	maxDigits = 15
+)
+
+// Normalize returns the number in the E.164 format. Separators are removed and an international '00' prefix is
+// replaced by '+'. National numbers (without prefix) are only accepted when a default calling code is provided
+// (ie: "33" for France), in which case the leading trunk '0' is removed.
+func Normalize(number, defaultCallingCode string) (string, error) {
+	cleaned := strings.Builder{}
+	for _, r := range strings.TrimSpace(number) {
+		switch r {
+		case ' ', '-', '.', '(', ')', '/', '\u00a0':
+			continue
+		}
+		cleaned.WriteRune(r)
+	}
+	res := cleaned.String()
+
+	switch {
+	case strings.HasPrefix(res, "+"):
+	case strings.HasPrefix(res, "00"):
+		res = "+" + res[2:]
+	case defaultCallingCode != "":
+		res = "+" + strings.TrimPrefix(defaultCallingCode, "+") + strings.TrimPrefix(res, "0")
+	default:
+		return "", ErrInvalidNumber
+	}
+
+	if err := Validate(res); err != nil {
+		return "", err
+	}
+	return res, nil
+}
+
+// Validate checks that the number is in the E.164 format.
+func Validate(number string) error {
+	if !strings.HasPrefix(number, "+") {
+		return ErrInvalidNumber
+	}
+	digits := number[1:]
+	if len(digits) < minDigits || len(digits) > maxDigits || digits[0] == '0' {
+		return ErrInvalidNumber
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return ErrInvalidNumber
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package phone
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	for _, c := range []struct {
+		number      string
+		callingCode string
+		expected    string
+		err         error
+	}{
+		{"+14155552671", "", "+14155552671", nil},
+		{" +1 (415) 555-2671 ", "", "+14155552671", nil},
+		{"0033 6 12 34 56 78", "", "+33612345678", nil},
+		{"06.12.34.56.78", "33", "+33612345678", nil},
+		{"06 12 34 56 78", "+33", "+33612345678", nil},
+		{"06 12 34 56 78", "", "", ErrInvalidNumber},
+		{"+0612345678", "", "", ErrInvalidNumber},
+		{"+1415555267100000", "", "", ErrInvalidNumber},
+		{"+12345", "", "", ErrInvalidNumber},
+		{"+1415CALLME", "", "", ErrInvalidNumber},
+		{"me@example.com", "33", "", ErrInvalidNumber},
+		{"", "33", "", ErrInvalidNumber},
+	} {
+		res, err := Normalize(c.number, c.callingCode)
+		require.Equal(t, c.err, err, c.number)
+		require.Equal(t, c.expected, res, c.number)
+	}
+}
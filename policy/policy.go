@@ -0,0 +1,218 @@
+// Package policy authorizes the email addresses with an ordered list of allow and deny rules.
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/net/idna"
+)
+
+type (
+	Action int
+
+	// Rule matches an address with its Pattern, which is either:
+	//  - an exact domain: example.com
+	//  - a wildcard matching all the subdomains, but not the domain itself: *.corp.example.com
+	//  - a full address: john@example.com
+	//  - a regular expression on the local part, between slashes: /^(admin|root)$/
+	Rule struct {
+		Action  Action
+		Pattern string
+	}
+
+	Params struct {
+		Rules []Rule
+		// Default is the action when no rule matches, defaults to Allow.
+		Default Action
+	}
+
+	// Engine checks the rules in order, the first matching rule decides. The rules can be replaced with SetRules
+	// while the engine is used.
+	Engine struct {
+		def   Action
+		rules atomic.Value // []matcher
+	}
+
+	// DeniedError is returned when an address is denied, Rule is nil when no rule matched and the default action
+	// is Deny.
+	DeniedError struct {
+		Address string
+		Rule    *Rule
+	}
+
+	// RuleError is returned when a rule is invalid.
+	RuleError struct {
+		Rule Rule
+		Err  error
+	}
+
+	matcher struct {
+		rule  Rule
+		match func(local, domain string) bool
+	}
+)
+
+const (
+	Allow Action = iota
+	Deny
+)
+
+var (
+	ErrDenied         = errors.New("address is denied by the policy")
+	ErrInvalidRule    = errors.New("invalid policy rule")
+	ErrInvalidAddress = errors.New("address must contain a @")
+)
+
+func NewEngine(params Params) (*Engine, error) {
+	res := &Engine{def: params.Default}
+	if err := res.SetRules(params.Rules); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// SetRules replaces all the rules at once, the current rules are kept if one of the new rules is invalid.
+func (e *Engine) SetRules(rules []Rule) error {
+	matchers := make([]matcher, 0, len(rules))
+	for _, rule := range rules {
+		m, err := compile(rule)
+		if err != nil {
+			return err
+		}
+		matchers = append(matchers, m)
+	}
+	e.rules.Store(matchers)
+	return nil
+}
+
+// Rules returns a copy of the current rules.
+func (e *Engine) Rules() []Rule {
+	matchers := e.rules.Load().([]matcher)
+	res := make([]Rule, len(matchers))
+	for i, m := range matchers {
+		res[i] = m.rule
+	}
+	return res
+}
+
+// Check returns a *DeniedError if the address is denied, it can be used as a mauth.AddressPolicy.
+func (e *Engine) Check(ctx context.Context, address string) error {
+	address = strings.TrimSpace(address)
+	at := strings.LastIndexByte(address, '@')
+	if at < 0 {
+		return ErrInvalidAddress
+	}
+	local, domain := address[:at], normalizeDomain(address[at+1:])
+
+	for _, m := range e.rules.Load().([]matcher) {
+		if !m.match(local, domain) {
+			continue
+		}
+		if m.rule.Action == Allow {
+			return nil
+		}
+		rule := m.rule
+		return &DeniedError{Address: address, Rule: &rule}
+	}
+
+	if e.def == Deny {
+		return &DeniedError{Address: address}
+	}
+	return nil
+}
+
+func compile(rule Rule) (matcher, error) {
+	res := matcher{rule: rule}
+	pattern := strings.TrimSpace(rule.Pattern)
+
+	switch {
+	case rule.Action != Allow && rule.Action != Deny:
+		return res, &RuleError{Rule: rule, Err: fmt.Errorf("unknown action %d", rule.Action)}
+
+	case pattern == "":
+		return res, &RuleError{Rule: rule, Err: errors.New("empty pattern")}
+
+	case len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/"):
+		expr, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return res, &RuleError{Rule: rule, Err: err}
+		}
+		res.match = func(local, _ string) bool {
+			return expr.MatchString(local)
+		}
+
+	case strings.Contains(pattern, "@"):
+		at := strings.LastIndexByte(pattern, '@')
+		addressLocal, addressDomain := strings.ToLower(pattern[:at]), normalizeDomain(pattern[at+1:])
+		res.match = func(local, domain string) bool {
+			return domain == addressDomain && strings.ToLower(local) == addressLocal
+		}
+
+	case strings.HasPrefix(pattern, "*."):
+		suffix := "." + normalizeDomain(pattern[2:])
+		res.match = func(_, domain string) bool {
+			return strings.HasSuffix(domain, suffix)
+		}
+
+	case strings.Contains(pattern, "*"):
+		return res, &RuleError{Rule: rule, Err: errors.New("wildcards are only supported as the first label")}
+
+	default:
+		exact := normalizeDomain(pattern)
+		res.match = func(_, domain string) bool {
+			return domain == exact
+		}
+	}
+	return res, nil
+}
+
+// normalizeDomain returns the lower case ASCII form of the domain.
+func normalizeDomain(domain string) string {
+	domain = strings.TrimSuffix(strings.TrimSpace(domain), ".")
+	if ascii, err := idna.Lookup.ToASCII(domain); err == nil {
+		return ascii
+	}
+	return strings.ToLower(domain)
+}
+
+func (a Action) String() string {
+	switch a {
+	case Allow:
+		return "allow"
+	case Deny:
+		return "deny"
+	}
+	return fmt.Sprintf("Action(%d)", int(a))
+}
+
+func (r Rule) String() string {
+	return r.Action.String() + " " + r.Pattern
+}
+
+func (e *DeniedError) Error() string {
+	if e.Rule == nil {
+		return ErrDenied.Error() + " (default)"
+	}
+	return fmt.Sprintf("%s (rule %q)", ErrDenied.Error(), e.Rule.String())
+}
+
+func (e *DeniedError) Is(target error) bool {
+	return target == ErrDenied
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("%s %q: %s", ErrInvalidRule.Error(), e.Rule.String(), e.Err.Error())
+}
+
+func (e *RuleError) Is(target error) bool {
+	return target == ErrInvalidRule
+}
+
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
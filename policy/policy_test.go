@@ -0,0 +1,101 @@
+package policy
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine(t *testing.T) {
+	e, err := NewEngine(Params{
+		Rules: []Rule{
+			{Action: Allow, Pattern: "ceo@corp.example.com"},
+			{Action: Deny, Pattern: "/^(admin|root)$/"},
+			{Action: Allow, Pattern: "*.corp.example.com"},
+			{Action: Deny, Pattern: "corp.example.com"},
+			{Action: Allow, Pattern: "Example.COM"},
+			{Action: Allow, Pattern: "bücher.de"},
+		},
+		Default: Deny,
+	})
+	require.NoError(t, err)
+
+	for _, address := range []string{
+		"CEO@corp.example.com",
+		"john@paris.corp.example.com",
+		"john@a.b.corp.example.com",
+		"john@example.com",
+		"john@EXAMPLE.com.",
+		"john@xn--bcher-kva.de",
+	} {
+		require.NoError(t, e.Check(nil, address), address)
+	}
+
+	for address, pattern := range map[string]string{
+		"admin@example.com":           "/^(admin|root)$/",
+		"root@paris.corp.example.com": "/^(admin|root)$/",
+		"john@corp.example.com":       "corp.example.com",
+	} {
+		err := e.Check(nil, address)
+		require.True(t, errors.Is(err, ErrDenied), address)
+		denied := &DeniedError{}
+		require.True(t, errors.As(err, &denied))
+		require.NotNil(t, denied.Rule)
+		require.Equal(t, pattern, denied.Rule.Pattern)
+		require.Equal(t, Deny, denied.Rule.Action)
+	}
+
+	err = e.Check(nil, "john@other.com")
+	require.True(t, errors.Is(err, ErrDenied))
+	denied := &DeniedError{}
+	require.True(t, errors.As(err, &denied))
+	require.Nil(t, denied.Rule)
+
+	require.Equal(t, ErrInvalidAddress, e.Check(nil, "john"))
+}
+
+func TestDefaultAllow(t *testing.T) {
+	e, err := NewEngine(Params{Rules: []Rule{{Action: Deny, Pattern: "*.example.com"}}})
+	require.NoError(t, err)
+	require.NoError(t, e.Check(nil, "john@example.com"))
+	require.NoError(t, e.Check(nil, "john@other.com"))
+	require.True(t, errors.Is(e.Check(nil, "john@mail.example.com"), ErrDenied))
+}
+
+func TestInvalidRules(t *testing.T) {
+	for _, rule := range []Rule{
+		{Action: Deny, Pattern: ""},
+		{Action: Deny, Pattern: "/(/"},
+		{Action: Deny, Pattern: "mail.*.example.com"},
+		{Action: Action(3), Pattern: "example.com"},
+	} {
+		_, err := NewEngine(Params{Rules: []Rule{rule}})
+		require.True(t, errors.Is(err, ErrInvalidRule), rule.String())
+	}
+}
+
+func TestSetRules(t *testing.T) {
+	e, err := NewEngine(Params{Rules: []Rule{{Action: Deny, Pattern: "example.com"}}})
+	require.NoError(t, err)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				e.Check(nil, "john@example.com")
+			}
+		}()
+	}
+	require.NoError(t, e.SetRules([]Rule{{Action: Allow, Pattern: "example.com"}}))
+	wg.Wait()
+	require.NoError(t, e.Check(nil, "john@example.com"))
+
+	// invalid rules keep the current ones
+	require.Error(t, e.SetRules([]Rule{{Action: Deny, Pattern: "example.com"}, {Action: Deny, Pattern: "/(/"}}))
+	require.Equal(t, []Rule{{Action: Allow, Pattern: "example.com"}}, e.Rules())
+	require.NoError(t, e.Check(nil, "john@example.com"))
+}
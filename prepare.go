@@ -2,21 +2,88 @@ package mauth
 
 import (
 	"context"
+	"errors"
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/fdelbos/mauth/generator"
+	"github.com/fdelbos/mauth/phone"
+	"github.com/fdelbos/mauth/suppression"
+	"github.com/fdelbos/mauth/templates"
 )
 
-func (m MAuth) prepare(ctx context.Context, email string, duration time.Duration) (*preparation, error) {
-	if err := m.checkIfAuthorized(email); err != nil {
-		return nil, err
+// prepare runs the pipeline before sending: the address is trimmed and validated, then normalized to its canonical
+// form, which is authorized, checked against the suppression list and rate limited, and finally the token is minted
+// for it. The message is sent to the canonical address, so that the mailbox receiving the link is the one
+// authenticated by the token.
+func (m MAuth) prepare(ctx context.Context, email, ip string, duration time.Duration, options []SendOption) (*preparation, error) {
+	if m.Channel == ChannelSMS &&
+		(len(m.DomainWhitelist) != 0 || len(m.DomainBlackList) != 0 || len(m.Policies) != 0) {
+		// refuse the configuration rather than ignoring rules that look enforced
+		return nil, ErrSMSAddressRules
+	}
+
+	original := strings.TrimSpace(email)
+	if m.Channel != ChannelSMS {
+		original = trimAddress(original)
+		email = original
 	}
+
+	// 1 - validate
+	if m.Channel == ChannelSMS {
+		// phone numbers are validated and normalized to E.164
+		number, err := phone.Normalize(email, m.DefaultCallingCode)
+		if err != nil {
+			return nil, err
+		}
+		email = number
+	} else if m.Validator != nil {
+		if err := m.Validator.Validate(ctx, email); err != nil {
+			return nil, err
+		}
+	}
+
+	// 2 - normalize
 	if m.Normalizer != nil {
 		email = m.Normalizer.Normalize(email)
 	}
+
+	// 3 - authorize
+	if m.Channel != ChannelSMS {
+		if err := m.checkIfAuthorized(ctx, email); err != nil {
+			return nil, err
+		}
+	}
+	if m.Suppression != nil {
+		// the entries recorded before the normalization, or by a store that doesn't normalize, use the original form
+		for _, address := range []string{email, original} {
+			_, err := m.Suppression.Get(ctx, address)
+			if err == nil {
+				return nil, ErrSuppressedAddress
+			} else if !errors.Is(err, suppression.ErrNotFound) {
+				return nil, err
+			}
+		}
+	}
+	if m.RateLimiter != nil {
+		if err := m.RateLimiter.Allow(ctx, email, getDomain(email), ip); err != nil {
+			return nil, err
+		}
+	}
+
+	// 4 - mint
 	expiration := time.Now().Add(duration)
+	o := m.sendOptions(options)
 
-	token, err := m.Generator.Generate(ctx, email, expiration)
+	address := generator.Address{Canonical: email, Original: original}
+	var token string
+	var err error
+	if g, ok := m.Generator.(generator.AddressGenerator); ok {
+		token, err = g.GenerateAddress(ctx, address, expiration)
+	} else {
+		token, err = m.Generator.Generate(ctx, email, expiration)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -29,14 +96,23 @@ func (m MAuth) prepare(ctx context.Context, email string, duration time.Duration
 	q.Set(m.Param, token)
 	baseURL.RawQuery = q.Encode()
 
+	if o.location != nil {
+		expiration = expiration.In(o.location)
+	}
+
 	return &preparation{
-		email:      email,
-		expiration: expiration,
-		url:        baseURL.String(),
+		recipient: email,
+		params: templates.Params{
+			Email:      email,
+			Original:   original,
+			URL:        baseURL.String(),
+			Expiration: expiration,
+			Data:       o.data,
+		},
 	}, nil
 }
 
-func (m MAuth) checkIfAuthorized(email string) error {
+func (m MAuth) checkIfAuthorized(ctx context.Context, email string) error {
 	domain := getDomain(email)
 
 	if len(m.DomainBlackList) != 0 {
@@ -46,14 +122,30 @@ func (m MAuth) checkIfAuthorized(email string) error {
 	}
 
 	if len(m.DomainWhitelist) != 0 {
-		if _, ok := m.DomainBlackList[domain]; !ok {
+		if _, ok := m.DomainWhitelist[domain]; !ok {
 			return ErrBlacklistedAddress
 		}
 	}
 
+	for _, policy := range m.Policies {
+		if err := policy.Check(ctx, email); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// trimAddress removes the trailing dots of the domain (ie: "john@example.com."), a fully qualified domain name is the
+// same domain.
+func trimAddress(email string) string {
+	at := strings.LastIndexByte(email, '@')
+	if at < 0 {
+		return email
+	}
+	return email[:at+1] + strings.TrimRight(email[at+1:], ".")
+}
+
 func getDomain(email string) string {
 	prepared := strings.TrimSpace(email)
 	prepared = strings.TrimRight(prepared, ".")
@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type (
+	bucket struct {
+		tokens  float64
+		last    time.Time
+		expires time.Time
+	}
+
+	// Memory is an in memory Store, the limits are local to the process.
+	Memory struct {
+		mu        sync.Mutex
+		buckets   map[string]*bucket
+		lastSweep time.Time
+		now       func() time.Time
+	}
+)
+
+const (
+	sweepInterval = time.Minute
+)
+
+func NewMemory() *Memory {
+	return &Memory{
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+func (m *Memory) Take(ctx context.Context, key string, rate Rate) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	// tokens per nanosecond
+	refill := float64(rate.Limit) / float64(rate.Per)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rate.Limit), last: now}
+		m.buckets[key] = b
+	} else {
+		b.tokens += float64(now.Sub(b.last)) * refill
+		if b.tokens > float64(rate.Limit) {
+			b.tokens = float64(rate.Limit)
+		}
+		b.last = now
+	}
+	// once the bucket is full again it can be forgotten
+	b.expires = now.Add(rate.Per)
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, nil
+	}
+	return time.Duration((1 - b.tokens) / refill), nil
+}
+
+func (m *Memory) Refund(ctx context.Context, key string, rate Rate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if b, ok := m.buckets[key]; ok {
+		b.tokens++
+		if b.tokens > float64(rate.Limit) {
+			b.tokens = float64(rate.Limit)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+	for key, b := range m.buckets {
+		if now.After(b.expires) {
+			delete(m.buckets, key)
+		}
+	}
+}
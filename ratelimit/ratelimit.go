@@ -0,0 +1,107 @@
+// Package ratelimit limits how often login messages can be sent, with token buckets keyed by the recipient address,
+// the recipient domain and the client IP address. This prevents a script from using the service to flood an inbox,
+// or to send messages to many addresses.
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type (
+	Limiter struct {
+		Store      Store
+		PerAddress Rate
+		PerDomain  Rate
+		PerIP      Rate
+		// TrustedProxies is the number of proxies in front of the service appending the client address to the
+		// X-Forwarded-For header, see ClientIP. The header is ignored when 0.
+		TrustedProxies int
+	}
+
+	check struct {
+		scope string
+		value string
+		rate  Rate
+	}
+)
+
+// NewLimiter returns a limiter with reasonable defaults: 5 messages per hour for an address, 500 per hour for a
+// domain and 20 per hour for an IP.
+func NewLimiter(store Store) *Limiter {
+	return &Limiter{
+		Store:      store,
+		PerAddress: Rate{Limit: 5, Per: time.Hour},
+		PerDomain:  Rate{Limit: 500, Per: time.Hour},
+		PerIP:      Rate{Limit: 20, Per: time.Hour},
+	}
+}
+
+// Allow takes a token for the address, domain and ip (the empty ones are ignored) and returns an *ErrRateLimited
+// error if one of the limits is reached. The tokens are only spent when all the limits allow the message, the ones
+// already taken are refunded otherwise.
+func (l Limiter) Allow(ctx context.Context, address, domain, ip string) error {
+	checks := []check{
+		{ScopeIP, ip, l.PerIP},
+		{ScopeAddress, address, l.PerAddress},
+		{ScopeDomain, domain, l.PerDomain},
+	}
+
+	taken := []check{}
+	for _, c := range checks {
+		if c.value == "" || !c.rate.enabled() {
+			continue
+		}
+
+		wait, err := l.Store.Take(ctx, c.key(), c.rate)
+		if err == nil && wait > 0 {
+			err = &ErrRateLimited{Scope: c.scope, RetryAfter: wait}
+		}
+		if err != nil {
+			for _, t := range taken {
+				// best effort, the token comes back with the refill anyway
+				_ = l.Store.Refund(ctx, t.key(), t.rate)
+			}
+			return err
+		}
+		taken = append(taken, c)
+	}
+	return nil
+}
+
+func (c check) key() string {
+	return c.scope + ":" + c.value
+}
+
+// ClientIP returns the IP address of the client that made the request.
+func (l Limiter) ClientIP(r *http.Request) string {
+	return ClientIP(r, l.TrustedProxies)
+}
+
+// ClientIP returns the IP address of the client that made the request. With trustedProxies set, it is the address
+// added to the X-Forwarded-For header by the farthest trusted proxy, counted from the right: the entries before it
+// are sent by the client and can't be trusted. The address of the connection is used when the header has fewer
+// entries than trusted proxies.
+func ClientIP(r *http.Request, trustedProxies int) string {
+	if trustedProxies > 0 {
+		entries := []string{}
+		for _, header := range r.Header.Values("X-Forwarded-For") {
+			for _, entry := range strings.Split(header, ",") {
+				entries = append(entries, strings.TrimSpace(entry))
+			}
+		}
+		if len(entries) >= trustedProxies {
+			if ip := entries[len(entries)-trustedProxies]; ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
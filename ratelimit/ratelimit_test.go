@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryTake(t *testing.T) {
+	now := time.Now()
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	rate := Rate{Limit: 2, Per: time.Minute}
+
+	for i := 0; i < 2; i++ {
+		wait, err := m.Take(context.Background(), "key", rate)
+		require.Nil(t, err)
+		require.Equal(t, time.Duration(0), wait)
+	}
+
+	wait, err := m.Take(context.Background(), "key", rate)
+	require.Nil(t, err)
+	require.Equal(t, 30*time.Second, wait)
+
+	// a token is refilled every 30 seconds
+	now = now.Add(30 * time.Second)
+	wait, err = m.Take(context.Background(), "key", rate)
+	require.Nil(t, err)
+	require.Equal(t, time.Duration(0), wait)
+
+	// the other keys have their own bucket
+	wait, err = m.Take(context.Background(), "other", rate)
+	require.Nil(t, err)
+	require.Equal(t, time.Duration(0), wait)
+}
+
+func TestMemorySweep(t *testing.T) {
+	now := time.Now()
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+
+	m.Take(context.Background(), "key", Rate{Limit: 1, Per: time.Second})
+	require.Len(t, m.buckets, 1)
+
+	now = now.Add(2 * sweepInterval)
+	m.Take(context.Background(), "other", Rate{Limit: 1, Per: time.Second})
+	require.Len(t, m.buckets, 1)
+}
+
+func TestAllow(t *testing.T) {
+	l := Limiter{
+		Store:      NewMemory(),
+		PerAddress: Rate{Limit: 2, Per: time.Hour},
+		PerDomain:  Rate{Limit: 3, Per: time.Hour},
+		PerIP:      Rate{Limit: 10, Per: time.Hour},
+	}
+	ctx := context.Background()
+
+	require.Nil(t, l.Allow(ctx, "a@example.com", "example.com", "10.0.0.1"))
+	require.Nil(t, l.Allow(ctx, "a@example.com", "example.com", "10.0.0.1"))
+
+	err := l.Allow(ctx, "a@example.com", "example.com", "10.0.0.1")
+	limited := &ErrRateLimited{}
+	require.True(t, errors.As(err, &limited))
+	require.Equal(t, ScopeAddress, limited.Scope)
+	require.True(t, limited.RetryAfter > 0)
+
+	require.Nil(t, l.Allow(ctx, "b@example.com", "example.com", "10.0.0.1"))
+	err = l.Allow(ctx, "c@example.com", "example.com", "10.0.0.1")
+	require.True(t, errors.As(err, &limited))
+	require.Equal(t, ScopeDomain, limited.Scope)
+
+	// without ip nor domain, only the address is limited
+	require.Nil(t, l.Allow(ctx, "+14155552671", "", ""))
+}
+
+func TestAllowRefund(t *testing.T) {
+	l := Limiter{
+		Store:      NewMemory(),
+		PerAddress: Rate{Limit: 1, Per: time.Hour},
+		PerIP:      Rate{Limit: 2, Per: time.Hour},
+	}
+	ctx := context.Background()
+
+	require.Nil(t, l.Allow(ctx, "a@example.com", "example.com", "10.0.0.1"))
+
+	// the refused messages don't spend the tokens of the ip
+	for i := 0; i < 3; i++ {
+		err := l.Allow(ctx, "a@example.com", "example.com", "10.0.0.1")
+		limited := &ErrRateLimited{}
+		require.True(t, errors.As(err, &limited))
+		require.Equal(t, ScopeAddress, limited.Scope)
+	}
+	require.Nil(t, l.Allow(ctx, "b@example.com", "example.com", "10.0.0.1"))
+}
+
+func TestClientIP(t *testing.T) {
+	r, _ := http.NewRequest("GET", "http://example.com", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("X-Forwarded-For", "192.168.1.1, 10.0.0.2")
+
+	require.Equal(t, "10.0.0.1", Limiter{}.ClientIP(r))
+	require.Equal(t, "10.0.0.2", Limiter{TrustedProxies: 1}.ClientIP(r))
+	require.Equal(t, "192.168.1.1", Limiter{TrustedProxies: 2}.ClientIP(r))
+	require.Equal(t, "10.0.0.1", Limiter{TrustedProxies: 3}.ClientIP(r))
+}
+
+func TestClientIPSpoofed(t *testing.T) {
+	ctx := context.Background()
+	l := Limiter{Store: NewMemory(), PerIP: Rate{Limit: 2, Per: time.Hour}, TrustedProxies: 1}
+
+	// the client sends a new X-Forwarded-For with each request, the proxy appends the real address
+	for i := 0; i < 3; i++ {
+		r, _ := http.NewRequest("GET", "http://example.com", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Add("X-Forwarded-For", fmt.Sprintf("1.2.3.%d", i))
+		r.Header.Add("X-Forwarded-For", "203.0.113.7")
+
+		ip := l.ClientIP(r)
+		require.Equal(t, "203.0.113.7", ip)
+		err := l.Allow(ctx, "", "", ip)
+		if i < 2 {
+			require.Nil(t, err)
+		} else {
+			limited := &ErrRateLimited{}
+			require.True(t, errors.As(err, &limited))
+			require.Equal(t, ScopeIP, limited.Scope)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Package redis implements a ratelimit.Store with Redis, so that the limits are shared between several instances of
+// the service. Each bucket is a hash updated atomically by a Lua script.
+package redis
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/fdelbos/mauth/ratelimit"
+	goredis "github.com/go-redis/redis/v8"
+)
+
+type (
+	Params struct {
+		Client goredis.UniversalClient
+		// Prefix is prepended to the keys, defaults to "mauth:ratelimit:".
+		Prefix string
+	}
+
+	Store struct {
+		client goredis.UniversalClient
+		prefix string
+		now    func() time.Time
+	}
+)
+
+const (
+	DefaultPrefix = "mauth:ratelimit:"
+)
+
+var (
+	ErrClientNil = errors.New("redis client cannot be nil")
+
+	// KEYS[1]: bucket, ARGV: limit, period (ms), now (ms). Returns the time to wait in ms.
+	takeScript = goredis.NewScript(`
+local limit = tonumber(ARGV[1])
+local per = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "last")
+local tokens = tonumber(state[1])
+local last = tonumber(state[2])
+if tokens == nil or last == nil then
+	tokens = limit
+	last = now
+end
+
+local refill = limit / per
+tokens = math.min(limit, tokens + math.max(0, now - last) * refill)
+
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+else
+	wait = math.ceil((1 - tokens) / refill)
+end
+
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "last", now)
+redis.call("PEXPIRE", KEYS[1], per)
+return wait
+`)
+
+	// KEYS[1]: bucket, ARGV: limit. Gives back a token, the bucket is unchanged if it expired.
+	refundScript = goredis.NewScript(`
+local tokens = tonumber(redis.call("HGET", KEYS[1], "tokens"))
+if tokens == nil then
+	return 0
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(math.min(tonumber(ARGV[1]), tokens + 1)))
+return 1
+`)
+)
+
+func NewStore(params Params) (*Store, error) {
+	if params.Client == nil {
+		return nil, ErrClientNil
+	}
+	prefix := params.Prefix
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	return &Store{
+		client: params.Client,
+		prefix: prefix,
+		now:    time.Now,
+	}, nil
+}
+
+func (s Store) Take(ctx context.Context, key string, rate ratelimit.Rate) (time.Duration, error) {
+	wait, err := takeScript.Run(ctx, s.client, []string{s.prefix + key},
+		rate.Limit,
+		rate.Per.Milliseconds(),
+		s.now().UnixNano()/int64(time.Millisecond),
+	).Int64()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(wait) * time.Millisecond, nil
+}
+
+func (s Store) Refund(ctx context.Context, key string, rate ratelimit.Rate) error {
+	return refundScript.Run(ctx, s.client, []string{s.prefix + key}, rate.Limit).Err()
+}
@@ -0,0 +1,86 @@
+package redis_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dchest/uniuri"
+	"github.com/fdelbos/mauth/ratelimit"
+	"github.com/fdelbos/mauth/ratelimit/redis"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/suite"
+)
+
+type (
+	RedisSuite struct {
+		suite.Suite
+		client *goredis.Client
+		store  *redis.Store
+		prefix string
+	}
+)
+
+func TestRedisSuite(t *testing.T) {
+	// REDIS_ADDR is set by the CI, the tests fail when it is set and redis is unreachable
+	if os.Getenv("REDIS_ADDR") == "" {
+		t.Skip("REDIS_ADDR is not set")
+	}
+	suite.Run(t, &RedisSuite{})
+}
+
+func (s *RedisSuite) SetupTest() {
+	s.client = goredis.NewClient(&goredis.Options{Addr: os.Getenv("REDIS_ADDR")})
+	s.Require().Nil(s.client.Ping(context.Background()).Err())
+
+	s.prefix = "mauth:test:" + uniuri.New() + ":"
+	var err error
+	s.store, err = redis.NewStore(redis.Params{Client: s.client, Prefix: s.prefix})
+	s.Require().Nil(err)
+}
+
+func (s *RedisSuite) TearDownTest() {
+	s.client.Close()
+}
+
+func (s *RedisSuite) TestTake() {
+	ctx := context.Background()
+	rate := ratelimit.Rate{Limit: 3, Per: time.Minute}
+
+	for i := 0; i < 3; i++ {
+		wait, err := s.store.Take(ctx, "address:dest@example.com", rate)
+		s.Require().Nil(err)
+		s.Require().Equal(time.Duration(0), wait)
+	}
+
+	wait, err := s.store.Take(ctx, "address:dest@example.com", rate)
+	s.Require().Nil(err)
+	s.Require().True(wait > 0 && wait <= 20*time.Second, wait)
+
+	ttl := s.client.PTTL(ctx, s.prefix+"address:dest@example.com").Val()
+	s.Require().True(ttl > 0 && ttl <= time.Minute)
+}
+
+func (s *RedisSuite) TestRefund() {
+	ctx := context.Background()
+	rate := ratelimit.Rate{Limit: 1, Per: time.Minute}
+
+	wait, err := s.store.Take(ctx, "ip:10.0.0.1", rate)
+	s.Require().Nil(err)
+	s.Require().Equal(time.Duration(0), wait)
+
+	s.Require().Nil(s.store.Refund(ctx, "ip:10.0.0.1", rate))
+	wait, err = s.store.Take(ctx, "ip:10.0.0.1", rate)
+	s.Require().Nil(err)
+	s.Require().Equal(time.Duration(0), wait)
+
+	// the bucket is never above the limit
+	s.Require().Nil(s.store.Refund(ctx, "ip:10.0.0.1", rate))
+	s.Require().Nil(s.store.Refund(ctx, "ip:10.0.0.1", rate))
+	_, err = s.store.Take(ctx, "ip:10.0.0.1", rate)
+	s.Require().Nil(err)
+	wait, err = s.store.Take(ctx, "ip:10.0.0.1", rate)
+	s.Require().Nil(err)
+	s.Require().True(wait > 0)
+}
@@ -0,0 +1,45 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type (
+	// Rate allows Limit events per period, with bursts up to Limit. The zero value disables the limit.
+	Rate struct {
+		Limit int
+		Per   time.Duration
+	}
+
+	// Store keeps the token buckets.
+	Store interface {
+		// Take removes a token from the bucket of the key. It returns 0 if a token was available, or the time to
+		// wait for the next one.
+		Take(ctx context.Context, key string, rate Rate) (time.Duration, error)
+		// Refund gives back a token taken from the bucket of the key.
+		Refund(ctx context.Context, key string, rate Rate) error
+	}
+
+	// ErrRateLimited is returned when a limit is reached, RetryAfter is the time to wait before trying again.
+	ErrRateLimited struct {
+		// Scope is the limit reached: ScopeAddress, ScopeDomain or ScopeIP.
+		Scope      string
+		RetryAfter time.Duration
+	}
+)
+
+const (
+	ScopeAddress = "address"
+	ScopeDomain  = "domain"
+	ScopeIP      = "ip"
+)
+
+func (r Rate) enabled() bool {
+	return r.Limit > 0 && r.Per > 0
+}
+
+func (e *ErrRateLimited) Error() string {
+	return fmt.Sprintf("too many requests for this %s, retry after %s", e.Scope, e.RetryAfter)
+}
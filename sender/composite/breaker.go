@@ -0,0 +1,81 @@
+package composite
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	breakerState int
+
+	// breaker is a circuit breaker: after threshold consecutive failures the circuit opens and the backend is skipped
+	// until the cooldown is over, then a single trial message is let through (half open) to decide if the circuit
+	// closes again.
+	breaker struct {
+		mu        sync.Mutex
+		state     breakerState
+		failures  int
+		openedAt  time.Time
+		threshold int
+		cooldown  time.Duration
+	}
+)
+
+const (
+	stateClosed breakerState = iota
+	stateOpen
+	stateHalfOpen
+)
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown}
+}
+
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case stateOpen:
+		if now.Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = stateHalfOpen
+		return true
+	case stateHalfOpen:
+		// a trial is already running
+		return false
+	default:
+		return true
+	}
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = stateClosed
+	b.failures = 0
+}
+
+func (b *breaker) failure(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == stateHalfOpen || b.failures >= b.threshold {
+		b.state = stateOpen
+		b.openedAt = now
+	}
+}
+
+// release gives back a trial that ended without telling anything about the backend health.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == stateHalfOpen {
+		b.state = stateOpen
+		b.openedAt = time.Time{}
+	}
+}
@@ -0,0 +1,222 @@
+// Package composite combines several senders into one: Failover tries the senders in order until one accepts the
+// message, RoundRobin spreads the messages between the senders according to their weight. Each backend has its own
+// circuit breaker, so a failing provider is skipped for a while instead of slowing down every message.
+//
+// Both report the name of the backend that delivered the message in the sender.Receipt.
+package composite
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fdelbos/mauth/sender"
+)
+
+type (
+	Backend struct {
+		Name   string
+		Sender sender.Sender
+		// Weight is only used by RoundRobin, defaults to 1.
+		Weight int
+	}
+
+	Params struct {
+		Backends []Backend
+		// Threshold is the number of consecutive failures opening the circuit of a backend, defaults to 5.
+		Threshold int
+		// Cooldown is the time a backend is skipped once its circuit is open, defaults to 30 seconds.
+		Cooldown time.Duration
+		// Retryable decides if the next backend should be tried after an error. By default only the errors
+		// classified as temporary (see sender.IsTemporary) are retryable, unless the context is canceled or expired:
+		// the other errors may come after the message was accepted, and retrying would send a duplicate. The
+		// failures of the backend itself (see sender.IsBackend) are always retryable and count for its circuit.
+		Retryable func(error) bool
+		Logger    *log.Logger
+	}
+
+	backend struct {
+		Backend
+		breaker *breaker
+		current int
+	}
+
+	composite struct {
+		backends  []*backend
+		retryable func(error) bool
+		log       *log.Logger
+	}
+
+	Failover struct {
+		composite
+	}
+
+	RoundRobin struct {
+		composite
+		mu sync.Mutex
+	}
+)
+
+const (
+	DefaultThreshold = 5
+	DefaultCooldown  = 30 * time.Second
+)
+
+var (
+	ErrNoBackend     = errors.New("at least one backend is required")
+	ErrInvalidWeight = errors.New("backend weight cannot be negative")
+	ErrUnavailable   = errors.New("all the backends are unavailable")
+)
+
+func newComposite(params Params, name string) (*composite, error) {
+	if len(params.Backends) == 0 {
+		return nil, ErrNoBackend
+	}
+
+	threshold := params.Threshold
+	if threshold <= 0 {
+		threshold = DefaultThreshold
+	}
+	cooldown := params.Cooldown
+	if cooldown <= 0 {
+		cooldown = DefaultCooldown
+	}
+
+	res := &composite{
+		backends:  []*backend{},
+		retryable: params.Retryable,
+		log:       params.Logger,
+	}
+	for _, b := range params.Backends {
+		if b.Weight < 0 {
+			return nil, ErrInvalidWeight
+		} else if b.Weight == 0 {
+			b.Weight = 1
+		}
+		res.backends = append(res.backends, &backend{
+			Backend: b,
+			breaker: newBreaker(threshold, cooldown),
+		})
+	}
+	if res.retryable == nil {
+		res.retryable = defaultRetryable
+	}
+	if res.log == nil {
+		res.log = log.New(os.Stdout, "mauth "+name, log.LstdFlags)
+	}
+	return res, nil
+}
+
+func defaultRetryable(err error) bool {
+	if sender.IsBackend(err) {
+		return true
+	}
+	return sender.IsTemporary(err) &&
+		!errors.Is(err, context.Canceled) &&
+		!errors.Is(err, context.DeadlineExceeded)
+}
+
+func NewFailover(params Params) (*Failover, error) {
+	c, err := newComposite(params, "failover")
+	if err != nil {
+		return nil, err
+	}
+	return &Failover{composite: *c}, nil
+}
+
+func NewRoundRobin(params Params) (*RoundRobin, error) {
+	c, err := newComposite(params, "round robin")
+	if err != nil {
+		return nil, err
+	}
+	return &RoundRobin{composite: *c}, nil
+}
+
+// try sends the message with the backends in the given order, skipping the ones with an open circuit.
+func (c composite) try(ctx context.Context, order []*backend, address, subject string, txt, html []byte) (*sender.Receipt, error) {
+	var lastErr error
+	for _, b := range order {
+		if !b.breaker.allow(time.Now()) {
+			continue
+		}
+
+		receipt, err := send(ctx, b.Sender, address, subject, txt, html)
+		if err == nil {
+			b.breaker.success()
+			res := sender.Receipt{Sender: b.Name}
+			if receipt != nil {
+				res.MessageID = receipt.MessageID
+			}
+			return &res, nil
+		}
+
+		lastErr = err
+		if !c.retryable(err) {
+			b.breaker.release()
+			return nil, err
+		}
+		b.breaker.failure(time.Now())
+		c.log.Printf("backend '%s' failed to send the email: '%s'", b.Name, err)
+	}
+
+	if lastErr == nil {
+		return nil, ErrUnavailable
+	}
+	return nil, lastErr
+}
+
+func send(ctx context.Context, s sender.Sender, address, subject string, txt, html []byte) (*sender.Receipt, error) {
+	if rs, ok := s.(sender.ReceiptSender); ok {
+		return rs.SendWithReceipt(ctx, address, subject, txt, html)
+	}
+	return nil, s.Send(ctx, address, subject, txt, html)
+}
+
+func (f *Failover) Send(ctx context.Context, address, subject string, txt, html []byte) error {
+	_, err := f.SendWithReceipt(ctx, address, subject, txt, html)
+	return err
+}
+
+func (f *Failover) SendWithReceipt(ctx context.Context, address, subject string, txt, html []byte) (*sender.Receipt, error) {
+	return f.try(ctx, f.backends, address, subject, txt, html)
+}
+
+func (r *RoundRobin) Send(ctx context.Context, address, subject string, txt, html []byte) error {
+	_, err := r.SendWithReceipt(ctx, address, subject, txt, html)
+	return err
+}
+
+// SendWithReceipt sends the message with the next backend, if it fails with a retryable error the other backends
+// are tried in order.
+func (r *RoundRobin) SendWithReceipt(ctx context.Context, address, subject string, txt, html []byte) (*sender.Receipt, error) {
+	first := r.next()
+	order := []*backend{first}
+	for _, b := range r.backends {
+		if b != first {
+			order = append(order, b)
+		}
+	}
+	return r.try(ctx, order, address, subject, txt, html)
+}
+
+// next implements the smooth weighted round robin algorithm, so that a backend with a weight of 2 is picked twice
+// as often as a backend with a weight of 1, without sending bursts to the same backend.
+func (r *RoundRobin) next() *backend {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	total := 0
+	var best *backend
+	for _, b := range r.backends {
+		b.current += b.Weight
+		total += b.Weight
+		if best == nil || b.current > best.current {
+			best = b
+		}
+	}
+	best.current -= total
+	return best
+}
@@ -0,0 +1,193 @@
+package composite
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/fdelbos/mauth/sender"
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	fakeSender struct {
+		err   error
+		calls int
+	}
+)
+
+var (
+	errTemporary = &sender.Error{Code: 503, Err: errors.New("service unavailable")}
+	logger       = log.New(ioutil.Discard, "", 0)
+)
+
+func (f *fakeSender) Send(ctx context.Context, address, subject string, txt, html []byte) error {
+	f.calls++
+	return f.err
+}
+
+func TestFailover(t *testing.T) {
+	primary := &fakeSender{err: errTemporary}
+	backup := &fakeSender{}
+
+	f, err := NewFailover(Params{
+		Backends: []Backend{
+			{Name: "primary", Sender: primary},
+			{Name: "backup", Sender: backup},
+		},
+		Threshold: 2,
+		Cooldown:  time.Hour,
+		Logger:    logger,
+	})
+	require.Nil(t, err)
+
+	for i := 0; i < 4; i++ {
+		receipt, err := f.SendWithReceipt(nil, "dest@example.com", "hello", []byte("text"), nil)
+		require.Nil(t, err)
+		require.Equal(t, "backup", receipt.Sender)
+	}
+	// the circuit of the primary opened after 2 failures
+	require.Equal(t, 2, primary.calls)
+	require.Equal(t, 4, backup.calls)
+}
+
+func TestFailoverNotRetryable(t *testing.T) {
+	errPermanent := errors.New("permanent")
+	primary := &fakeSender{err: errPermanent}
+	backup := &fakeSender{}
+
+	f, err := NewFailover(Params{
+		Backends: []Backend{
+			{Name: "primary", Sender: primary},
+			{Name: "backup", Sender: backup},
+		},
+		Retryable: func(err error) bool { return err != errPermanent },
+		Logger:    logger,
+	})
+	require.Nil(t, err)
+
+	require.Equal(t, errPermanent, f.Send(nil, "dest@example.com", "hello", []byte("text"), nil))
+	require.Equal(t, 0, backup.calls)
+}
+
+func TestFailoverNotClassified(t *testing.T) {
+	// ie: the response of the provider can't be decoded, the message may have been accepted
+	errResponse := errors.New("invalid response")
+	primary := &fakeSender{err: errResponse}
+	backup := &fakeSender{}
+
+	f, err := NewFailover(Params{
+		Backends: []Backend{
+			{Name: "primary", Sender: primary},
+			{Name: "backup", Sender: backup},
+		},
+		Logger: logger,
+	})
+	require.Nil(t, err)
+
+	require.Equal(t, errResponse, f.Send(nil, "dest@example.com", "hello", []byte("text"), nil))
+	require.Equal(t, 0, backup.calls)
+}
+
+func TestFailoverBackendError(t *testing.T) {
+	primary := &fakeSender{err: &sender.Error{Code: 401, Backend: true, Err: errors.New("invalid API key")}}
+	backup := &fakeSender{}
+
+	f, err := NewFailover(Params{
+		Backends: []Backend{
+			{Name: "primary", Sender: primary},
+			{Name: "backup", Sender: backup},
+		},
+		Threshold: 2,
+		Cooldown:  time.Hour,
+		Logger:    logger,
+	})
+	require.Nil(t, err)
+
+	// a primary with a revoked key fails over and its circuit opens
+	for i := 0; i < 3; i++ {
+		require.Nil(t, f.Send(nil, "dest@example.com", "hello", []byte("text"), nil))
+	}
+	require.Equal(t, 2, primary.calls)
+	require.Equal(t, 3, backup.calls)
+}
+
+func TestFailoverUnavailable(t *testing.T) {
+	f, err := NewFailover(Params{
+		Backends:  []Backend{{Name: "primary", Sender: &fakeSender{err: errTemporary}}},
+		Threshold: 1,
+		Cooldown:  time.Hour,
+		Logger:    logger,
+	})
+	require.Nil(t, err)
+
+	require.Equal(t, errTemporary, f.Send(nil, "dest@example.com", "hello", []byte("text"), nil))
+	require.Equal(t, ErrUnavailable, f.Send(nil, "dest@example.com", "hello", []byte("text"), nil))
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	b := newBreaker(1, time.Minute)
+	now := time.Now()
+
+	require.True(t, b.allow(now))
+	b.failure(now)
+	require.False(t, b.allow(now.Add(30*time.Second)))
+
+	// after the cooldown a single trial is allowed
+	require.True(t, b.allow(now.Add(time.Minute)))
+	require.False(t, b.allow(now.Add(time.Minute)))
+	b.success()
+	require.True(t, b.allow(now.Add(time.Minute)))
+}
+
+func TestRoundRobin(t *testing.T) {
+	a := &fakeSender{}
+	b := &fakeSender{}
+
+	r, err := NewRoundRobin(Params{
+		Backends: []Backend{
+			{Name: "a", Sender: a, Weight: 2},
+			{Name: "b", Sender: b},
+		},
+		Logger: logger,
+	})
+	require.Nil(t, err)
+
+	names := []string{}
+	for i := 0; i < 6; i++ {
+		receipt, err := r.SendWithReceipt(nil, "dest@example.com", "hello", []byte("text"), nil)
+		require.Nil(t, err)
+		names = append(names, receipt.Sender)
+	}
+	require.Equal(t, []string{"a", "b", "a", "a", "b", "a"}, names)
+	require.Equal(t, 4, a.calls)
+	require.Equal(t, 2, b.calls)
+}
+
+func TestRoundRobinFailover(t *testing.T) {
+	a := &fakeSender{err: errTemporary}
+	b := &fakeSender{}
+
+	r, err := NewRoundRobin(Params{
+		Backends: []Backend{{Name: "a", Sender: a}, {Name: "b", Sender: b}},
+		Logger:   logger,
+	})
+	require.Nil(t, err)
+
+	for i := 0; i < 2; i++ {
+		receipt, err := r.SendWithReceipt(nil, "dest@example.com", "hello", []byte("text"), nil)
+		require.Nil(t, err)
+		require.Equal(t, "b", receipt.Sender)
+	}
+}
+
+func TestInvalidParams(t *testing.T) {
+	_, err := NewFailover(Params{})
+	require.Equal(t, ErrNoBackend, err)
+
+	_, err = NewRoundRobin(Params{Backends: []Backend{{Name: "a", Sender: &fakeSender{}, Weight: -1}}})
+	require.Equal(t, ErrInvalidWeight, err)
+}
@@ -0,0 +1,232 @@
+// Package file writes email messages to the local file system instead of sending them. Messages are stored as
+// RFC 5322 files, either as '.eml' files in a plain directory or delivered to a Maildir, which makes it possible
+// to run a mauth service without any mail server during development.
+package file
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dchest/uniuri"
+)
+
+type (
+	layout int
+
+	Params struct {
+		// Dir is the directory where the messages are written, it is created if it doesn't exist.
+		Dir    string
+		Layout layout
+		From   string
+		// Links, when set, receives the links found in each message (for example os.Stdout).
+		Links io.Writer
+	}
+
+	File struct {
+		dir    string
+		layout layout
+		from   string
+		links  io.Writer
+		host   string
+	}
+)
+
+const (
+	LayoutDir layout = iota
+	LayoutMaildir
+
+	// maxLineLength is the recommended limit of RFC 5322
+	maxLineLength = 78
+)
+
+var (
+	linkRegexp = regexp.MustCompile(`https?://[^\s"'<>]+`)
+)
+
+func NewFile(params Params) (*File, error) {
+	dirs := []string{params.Dir}
+	if params.Layout == LayoutMaildir {
+		dirs = []string{
+			filepath.Join(params.Dir, "tmp"),
+			filepath.Join(params.Dir, "new"),
+			filepath.Join(params.Dir, "cur"),
+		}
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, err
+		}
+	}
+
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+
+	return &File{
+		dir:    params.Dir,
+		layout: params.Layout,
+		from:   params.From,
+		links:  params.Links,
+		host:   strings.NewReplacer("/", `\057`, ":", `\072`).Replace(host),
+	}, nil
+}
+
+func (f File) Send(ctx context.Context, address, subject string, txt, html []byte) error {
+	msg, err := f.message(address, subject, txt, html)
+	if err != nil {
+		return err
+	}
+
+	if f.layout == LayoutMaildir {
+		err = f.deliverMaildir(msg)
+	} else {
+		err = f.deliverDir(msg)
+	}
+	if err != nil {
+		return err
+	}
+
+	if f.links != nil {
+		for _, link := range findLinks(txt, html) {
+			fmt.Fprintf(f.links, "mauth: link for %s: %s\n", address, link)
+		}
+	}
+	return nil
+}
+
+func (f File) deliverDir(msg []byte) error {
+	name := fmt.Sprintf("%d.%s.eml", time.Now().UnixNano(), uniuri.NewLen(8))
+	return ioutil.WriteFile(filepath.Join(f.dir, name), msg, 0600)
+}
+
+// deliverMaildir follows the Maildir delivery protocol: the message is written in 'tmp' and then moved to 'new'.
+func (f File) deliverMaildir(msg []byte) error {
+	now := time.Now()
+	name := fmt.Sprintf("%d.M%dP%dR%s.%s", now.Unix(), now.Nanosecond()/1000, os.Getpid(), uniuri.NewLen(8), f.host)
+
+	tmp := filepath.Join(f.dir, "tmp", name)
+	if err := ioutil.WriteFile(tmp, msg, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(f.dir, "new", name)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func (f File) message(address, subject string, txt, html []byte) ([]byte, error) {
+	buff := bytes.Buffer{}
+	header := func(key, value string) {
+		fmt.Fprintf(&buff, "%s: %s\r\n", key, value)
+	}
+
+	header("From", f.from)
+	header("To", address)
+	buff.WriteString(encodeHeader("Subject", subject) + "\r\n")
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%s@%s>", uniuri.NewLen(24), f.host))
+	header("MIME-Version", "1.0")
+
+	if txt == nil || html == nil {
+		contentType, body := "text/plain", txt
+		if html != nil {
+			contentType, body = "text/html", html
+		}
+		header("Content-Type", contentType+"; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buff.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buff, body); err != nil {
+			return nil, err
+		}
+		return buff.Bytes(), nil
+	}
+
+	writer := multipart.NewWriter(&buff)
+	header("Content-Type", "multipart/alternative; boundary="+writer.Boundary())
+	buff.WriteString("\r\n")
+
+	for _, part := range []struct {
+		contentType string
+		body        []byte
+	}{
+		{"text/plain", txt},
+		{"text/html", html},
+	} {
+		w, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buff.Bytes(), nil
+}
+
+// encodeHeader returns the header line, the value is encoded with RFC 2047 when it is not plain ASCII and the line is
+// folded so that it is not longer than 78 characters when possible. The file sender is the only one writing the
+// MIME message itself: go-simple-mail encodes and folds the headers of the SMTP sender, and the API senders give the
+// subject as a JSON or form field that the provider encodes.
+func encodeHeader(key, value string) string {
+	res := strings.Builder{}
+	res.WriteString(key + ":")
+	length := res.Len()
+	for _, word := range strings.Split(mime.QEncoding.Encode("utf-8", value), " ") {
+		if length+1+len(word) > maxLineLength && length > 0 {
+			res.WriteString("\r\n")
+			length = 0
+		}
+		res.WriteString(" " + word)
+		length += 1 + len(word)
+	}
+	return res.String()
+}
+
+func writeQuotedPrintable(w io.Writer, body []byte) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write(body); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// findLinks returns the distinct links of the text body, or of the html body if there is no text.
+func findLinks(txt, htmlBody []byte) []string {
+	content := string(txt)
+	if txt == nil {
+		content = html.UnescapeString(string(htmlBody))
+	}
+
+	res := []string{}
+	seen := map[string]interface{}{}
+	for _, link := range linkRegexp.FindAllString(content, -1) {
+		if _, ok := seen[link]; ok {
+			continue
+		}
+		seen[link] = nil
+		res = append(res, link)
+	}
+	return res
+}
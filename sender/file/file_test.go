@@ -0,0 +1,143 @@
+package file_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fdelbos/mauth/sender/file"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	htmlBody = []byte(`<div><a href="https://example.com/login?mauth_token=a&amp;b=c">login</a></div>`)
+	textBody = []byte(`follow https://example.com/login?mauth_token=a&b=c to login`)
+)
+
+func readMessages(t *testing.T, dir string) []*mail.Message {
+	files, err := ioutil.ReadDir(dir)
+	require.Nil(t, err)
+
+	res := []*mail.Message{}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		require.Nil(t, err)
+		msg, err := mail.ReadMessage(bytes.NewReader(content))
+		require.Nil(t, err)
+		res = append(res, msg)
+	}
+	return res
+}
+
+func TestSendDir(t *testing.T) {
+	dir := t.TempDir()
+	links := bytes.Buffer{}
+	sender, err := file.NewFile(file.Params{
+		Dir:   dir,
+		From:  "sender@example.com",
+		Links: &links,
+	})
+	require.Nil(t, err)
+
+	err = sender.Send(nil, "dest@example.com", "héllo", textBody, htmlBody)
+	require.Nil(t, err)
+
+	msgs := readMessages(t, dir)
+	require.Len(t, msgs, 1)
+	msg := msgs[0]
+
+	require.Equal(t, "sender@example.com", msg.Header.Get("From"))
+	require.Equal(t, "dest@example.com", msg.Header.Get("To"))
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	require.Nil(t, err)
+	require.Equal(t, "héllo", subject)
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.Nil(t, err)
+	require.Equal(t, "multipart/alternative", mediaType)
+
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for _, expected := range [][]byte{textBody, htmlBody} {
+		part, err := reader.NextPart()
+		require.Nil(t, err)
+		body, err := ioutil.ReadAll(part)
+		require.Nil(t, err)
+		require.Equal(t, expected, body)
+	}
+
+	require.Equal(t,
+		"mauth: link for dest@example.com: https://example.com/login?mauth_token=a&b=c\n",
+		links.String())
+}
+
+func TestSendHTMLOnly(t *testing.T) {
+	dir := t.TempDir()
+	links := bytes.Buffer{}
+	sender, err := file.NewFile(file.Params{Dir: dir, From: "sender@example.com", Links: &links})
+	require.Nil(t, err)
+
+	require.Nil(t, sender.Send(nil, "dest@example.com", "hello", nil, htmlBody))
+
+	msgs := readMessages(t, dir)
+	require.Len(t, msgs, 1)
+	require.True(t, strings.HasPrefix(msgs[0].Header.Get("Content-Type"), "text/html"))
+	require.Contains(t, links.String(), "https://example.com/login?mauth_token=a&b=c")
+}
+
+func TestSendMaildir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "Maildir")
+	sender, err := file.NewFile(file.Params{
+		Dir:    dir,
+		Layout: file.LayoutMaildir,
+		From:   "sender@example.com",
+	})
+	require.Nil(t, err)
+
+	for i := 0; i < 3; i++ {
+		require.Nil(t, sender.Send(nil, "dest@example.com", "hello", textBody, nil))
+	}
+
+	require.Len(t, readMessages(t, filepath.Join(dir, "new")), 3)
+	require.Len(t, readMessages(t, filepath.Join(dir, "tmp")), 0)
+	require.Len(t, readMessages(t, filepath.Join(dir, "cur")), 0)
+}
+
+func TestLongSubject(t *testing.T) {
+	dir := t.TempDir()
+	sender, err := file.NewFile(file.Params{Dir: dir, From: "sender@example.com", Links: ioutil.Discard})
+	require.Nil(t, err)
+
+	subjects := []string{
+		strings.TrimSpace(strings.Repeat("Your sign-in link for Example ", 5)),
+		strings.TrimSpace(strings.Repeat("Votre lien de connexion à Exemple, valable jusqu'à 12h30 ", 4)),
+	}
+	for _, subject := range subjects {
+		require.Nil(t, sender.Send(nil, "dest@example.com", subject, textBody, nil))
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	require.Nil(t, err)
+	for _, f := range files {
+		content, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		require.Nil(t, err)
+		for _, line := range strings.Split(string(content), "\r\n") {
+			require.LessOrEqual(t, len(line), 78, line)
+		}
+	}
+
+	decoded := []string{}
+	for _, msg := range readMessages(t, dir) {
+		subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+		require.Nil(t, err)
+		decoded = append(decoded, subject)
+	}
+	require.ElementsMatch(t, subjects, decoded)
+}
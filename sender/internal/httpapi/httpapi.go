@@ -0,0 +1,166 @@
+// Package httpapi contains the HTTP plumbing shared by the senders using a provider HTTP API.
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fdelbos/mauth/sender"
+)
+
+type (
+	// Client sends requests to a provider API and retries them when the provider rate limits the calls.
+	Client struct {
+		HTTP       *http.Client
+		MaxRetries int
+		// RetryDelay is the first delay used when the provider doesn't send a Retry-After header, it is doubled
+		// on every retry.
+		RetryDelay time.Duration
+	}
+
+	// Response is a successful (2xx) response with its body already read.
+	Response struct {
+		StatusCode int
+		Header     http.Header
+		Body       []byte
+	}
+
+	// StatusError is returned, wrapped in a sender.Error, when the provider replies with a non 2xx status code.
+	StatusError struct {
+		StatusCode int
+		Body       string
+	}
+)
+
+const (
+	DefaultMaxRetries = 3
+	DefaultRetryDelay = time.Second
+	maxRetryDelay     = time.Minute
+)
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("provider replied with http status %d: %s", e.StatusCode, e.Body)
+}
+
+// Decode parses the JSON body of the response. The provider accepted the message, so a failure is permanent: sending
+// it again would deliver a duplicate.
+func (r *Response) Decode(v interface{}) error {
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		return &sender.Error{Code: r.StatusCode, Permanent: true, Err: err}
+	}
+	return nil
+}
+
+// NewClient returns a client with the defaults applied for the zero values.
+func NewClient(httpClient *http.Client, maxRetries int) Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if maxRetries == 0 {
+		maxRetries = DefaultMaxRetries
+	} else if maxRetries < 0 {
+		maxRetries = 0
+	}
+	return Client{
+		HTTP:       httpClient,
+		MaxRetries: maxRetries,
+		RetryDelay: DefaultRetryDelay,
+	}
+}
+
+// Do executes the request created by newRequest. newRequest is called for every attempt so that requests can be
+// signed with a fresh date. Errors are returned as a *sender.Error: client errors (4xx) are permanent, rate limits,
+// server errors and network failures are temporary.
+func (c Client) Do(ctx context.Context, newRequest func(ctx context.Context) (*http.Request, error)) (*Response, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	delay := c.RetryDelay
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := c.HTTP.Do(req)
+		if err != nil {
+			return nil, &sender.Error{Err: err}
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, &sender.Error{Err: err}
+		}
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}, nil
+		}
+
+		statusErr := classify(&StatusError{StatusCode: resp.StatusCode, Body: string(body)})
+		if resp.StatusCode != http.StatusTooManyRequests || attempt >= c.MaxRetries {
+			return nil, statusErr
+		}
+
+		wait, ok := retryAfter(resp.Header.Get("Retry-After"))
+		if !ok {
+			wait = delay
+			delay *= 2
+		}
+		if wait > maxRetryDelay {
+			wait = maxRetryDelay
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, statusErr
+		case <-timer.C:
+		}
+	}
+}
+
+// classify wraps the status error in a sender.Error: 401 and 403 are failures of the provider account (ie: revoked API
+// key, suspended account) and not of the recipient, the other 4xx except 408 and 429 are permanent.
+func classify(err *StatusError) *sender.Error {
+	switch err.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return &sender.Error{Code: err.StatusCode, Backend: true, Err: err}
+	}
+
+	permanent := err.StatusCode >= 400 && err.StatusCode < 500 &&
+		err.StatusCode != http.StatusTooManyRequests &&
+		err.StatusCode != http.StatusRequestTimeout
+	return &sender.Error{
+		Code:      err.StatusCode,
+		Permanent: permanent,
+		Err:       err,
+	}
+}
+
+// retryAfter parses a Retry-After header expressed either in seconds or as an HTTP date.
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
@@ -0,0 +1,113 @@
+// Package mailgun sends email messages with the Mailgun messages API.
+package mailgun
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/fdelbos/mauth/sender"
+	"github.com/fdelbos/mauth/sender/internal/httpapi"
+)
+
+type (
+	Params struct {
+		// BaseURL defaults to DefaultBaseURL, use EUBaseURL for domains hosted in the EU region.
+		BaseURL string
+		// Domain is the sending domain configured in Mailgun.
+		Domain string
+		APIKey string
+		From   string
+		// HTTPClient defaults to http.DefaultClient.
+		HTTPClient *http.Client
+		// MaxRetries is the number of retries when rate limited, defaults to 3, use a negative value to disable.
+		MaxRetries int
+		Logger     *log.Logger
+	}
+
+	Mailgun struct {
+		client  httpapi.Client
+		baseURL string
+		domain  string
+		apiKey  string
+		from    string
+		log     *log.Logger
+	}
+
+	response struct {
+		ID      string `json:"id"`
+		Message string `json:"message"`
+	}
+)
+
+const (
+	Name           = "mailgun"
+	DefaultBaseURL = "https://api.mailgun.net"
+	EUBaseURL      = "https://api.eu.mailgun.net"
+)
+
+func NewMailgun(params Params) (*Mailgun, error) {
+	res := &Mailgun{
+		client:  httpapi.NewClient(params.HTTPClient, params.MaxRetries),
+		baseURL: strings.TrimRight(params.BaseURL, "/"),
+		domain:  params.Domain,
+		apiKey:  params.APIKey,
+		from:    params.From,
+		log:     params.Logger,
+	}
+	if res.baseURL == "" {
+		res.baseURL = DefaultBaseURL
+	}
+	if res.log == nil {
+		res.log = log.New(os.Stdout, "mauth mailgun", log.LstdFlags)
+	}
+	return res, nil
+}
+
+func (m Mailgun) Send(ctx context.Context, address, subject string, txt, html []byte) error {
+	_, err := m.SendWithReceipt(ctx, address, subject, txt, html)
+	return err
+}
+
+func (m Mailgun) SendWithReceipt(ctx context.Context, address, subject string, txt, html []byte) (*sender.Receipt, error) {
+	form := url.Values{}
+	form.Set("from", m.from)
+	form.Set("to", address)
+	form.Set("subject", subject)
+	if txt != nil {
+		form.Set("text", string(txt))
+	}
+	if html != nil {
+		form.Set("html", string(html))
+	}
+	body := form.Encode()
+	endpoint := m.baseURL + "/v3/" + url.PathEscape(m.domain) + "/messages"
+
+	resp, err := m.client.Do(ctx, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.SetBasicAuth("api", m.apiKey)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	})
+	if err != nil {
+		m.log.Printf("error while sending the email: '%s'", err)
+		return nil, err
+	}
+
+	res := response{}
+	if err := resp.Decode(&res); err != nil {
+		m.log.Printf("invalid response from mailgun: '%s'", err)
+		return nil, err
+	}
+
+	return &sender.Receipt{
+		Sender:    Name,
+		MessageID: strings.Trim(res.ID, "<>"),
+	}, nil
+}
@@ -0,0 +1,53 @@
+package mailgun_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fdelbos/mauth/sender/mailgun"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSend(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		require.Equal(t, "/v3/mg.example.com/messages", r.URL.Path)
+		user, password, ok := r.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "api", user)
+		require.Equal(t, "key", password)
+
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		require.Nil(t, r.ParseForm())
+		require.Equal(t, "from@example.com", r.PostForm.Get("from"))
+		require.Equal(t, "dest@example.com", r.PostForm.Get("to"))
+		require.Equal(t, "hello", r.PostForm.Get("subject"))
+		require.Equal(t, "<b>html</b>", r.PostForm.Get("html"))
+		_, hasText := r.PostForm["text"]
+		require.False(t, hasText)
+
+		w.Write([]byte(`{"id":"<20201201.1@mg.example.com>","message":"Queued. Thank you."}`))
+	}))
+	defer server.Close()
+
+	s, err := mailgun.NewMailgun(mailgun.Params{
+		BaseURL: server.URL,
+		Domain:  "mg.example.com",
+		APIKey:  "key",
+		From:    "from@example.com",
+	})
+	require.Nil(t, err)
+
+	receipt, err := s.SendWithReceipt(nil, "dest@example.com", "hello", nil, []byte("<b>html</b>"))
+	require.Nil(t, err)
+	require.Equal(t, 2, calls)
+	require.Equal(t, mailgun.Name, receipt.Sender)
+	require.Equal(t, "20201201.1@mg.example.com", receipt.MessageID)
+}
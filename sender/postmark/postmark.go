@@ -0,0 +1,143 @@
+// Package postmark sends email messages with the Postmark email API.
+package postmark
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/fdelbos/mauth/sender"
+	"github.com/fdelbos/mauth/sender/internal/httpapi"
+)
+
+type (
+	Params struct {
+		// BaseURL defaults to DefaultBaseURL.
+		BaseURL     string
+		ServerToken string
+		From        string
+		// MessageStream defaults to the "outbound" transactional stream.
+		MessageStream string
+		// HTTPClient defaults to http.DefaultClient.
+		HTTPClient *http.Client
+		// MaxRetries is the number of retries when rate limited, defaults to 3, use a negative value to disable.
+		MaxRetries int
+		Logger     *log.Logger
+	}
+
+	Postmark struct {
+		client        httpapi.Client
+		baseURL       string
+		serverToken   string
+		from          string
+		messageStream string
+		log           *log.Logger
+	}
+
+	email struct {
+		From          string
+		To            string
+		Subject       string
+		TextBody      string `json:",omitempty"`
+		HtmlBody      string `json:",omitempty"`
+		MessageStream string `json:",omitempty"`
+	}
+
+	response struct {
+		ErrorCode int
+		Message   string
+		MessageID string
+	}
+)
+
+const (
+	Name           = "postmark"
+	DefaultBaseURL = "https://api.postmarkapp.com"
+)
+
+func NewPostmark(params Params) (*Postmark, error) {
+	res := &Postmark{
+		client:        httpapi.NewClient(params.HTTPClient, params.MaxRetries),
+		baseURL:       strings.TrimRight(params.BaseURL, "/"),
+		serverToken:   params.ServerToken,
+		from:          params.From,
+		messageStream: params.MessageStream,
+		log:           params.Logger,
+	}
+	if res.baseURL == "" {
+		res.baseURL = DefaultBaseURL
+	}
+	if res.log == nil {
+		res.log = log.New(os.Stdout, "mauth postmark", log.LstdFlags)
+	}
+	return res, nil
+}
+
+func (p Postmark) Send(ctx context.Context, address, subject string, txt, html []byte) error {
+	_, err := p.SendWithReceipt(ctx, address, subject, txt, html)
+	return err
+}
+
+func (p Postmark) SendWithReceipt(ctx context.Context, address, subject string, txt, html []byte) (*sender.Receipt, error) {
+	body, err := json.Marshal(email{
+		From:          p.from,
+		To:            address,
+		Subject:       subject,
+		TextBody:      string(txt),
+		HtmlBody:      string(html),
+		MessageStream: p.messageStream,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(ctx, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/email", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Postmark-Server-Token", p.serverToken)
+		return req, nil
+	})
+	if err != nil {
+		p.log.Printf("error while sending the email: '%s'", err)
+		return nil, err
+	}
+
+	res := response{}
+	if err := resp.Decode(&res); err != nil {
+		p.log.Printf("invalid response from postmark: '%s'", err)
+		return nil, err
+	}
+	if res.ErrorCode != 0 {
+		err := classify(resp.StatusCode, res)
+		p.log.Printf("error while sending the email: '%s'", err)
+		return nil, err
+	}
+
+	return &sender.Receipt{
+		Sender:    Name,
+		MessageID: res.MessageID,
+	}, nil
+}
+
+// classify wraps the API error code of a response in a sender.Error: the codes about the server token or the account
+// (ie: 10 bad API token, 405 no more credits) are failures of the backend, 100 (maintenance) is temporary and the
+// other ones reject the message (ie: 300 invalid request, 406 inactive recipient).
+func classify(status int, res response) *sender.Error {
+	err := fmt.Errorf("postmark error %d: %s", res.ErrorCode, res.Message)
+	switch res.ErrorCode {
+	case 10, 400, 401, 405, 412:
+		return &sender.Error{Code: status, Backend: true, Err: err}
+	case 100:
+		return &sender.Error{Code: status, Err: err}
+	}
+	return &sender.Error{Code: status, Permanent: true, Err: err}
+}
@@ -0,0 +1,93 @@
+package postmark_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fdelbos/mauth/sender"
+	"github.com/fdelbos/mauth/sender/postmark"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSend(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		require.Equal(t, "/email", r.URL.Path)
+		require.Equal(t, "token", r.Header.Get("X-Postmark-Server-Token"))
+
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		msg := map[string]interface{}{}
+		require.Nil(t, json.Unmarshal(body, &msg))
+		require.Equal(t, "from@example.com", msg["From"])
+		require.Equal(t, "dest@example.com", msg["To"])
+		require.Equal(t, "text", msg["TextBody"])
+		require.Equal(t, "<b>html</b>", msg["HtmlBody"])
+		require.Equal(t, "outbound", msg["MessageStream"])
+
+		w.Write([]byte(`{"To":"dest@example.com","ErrorCode":0,"Message":"OK","MessageID":"pm-id"}`))
+	}))
+	defer server.Close()
+
+	s, err := postmark.NewPostmark(postmark.Params{
+		BaseURL:       server.URL,
+		ServerToken:   "token",
+		From:          "from@example.com",
+		MessageStream: "outbound",
+	})
+	require.Nil(t, err)
+
+	receipt, err := s.SendWithReceipt(nil, "dest@example.com", "hello", []byte("text"), []byte("<b>html</b>"))
+	require.Nil(t, err)
+	require.Equal(t, 2, calls)
+	require.Equal(t, postmark.Name, receipt.Sender)
+	require.Equal(t, "pm-id", receipt.MessageID)
+}
+
+func TestSendError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"ErrorCode":300,"Message":"Invalid email request"}`))
+	}))
+	defer server.Close()
+
+	s, err := postmark.NewPostmark(postmark.Params{BaseURL: server.URL})
+	require.Nil(t, err)
+
+	require.NotNil(t, s.Send(nil, "dest@example.com", "hello", []byte("text"), nil))
+}
+
+func TestSendErrorCode(t *testing.T) {
+	for code, check := range map[int]func(error) bool{
+		10:  sender.IsBackend,
+		100: func(err error) bool { return sender.IsTemporary(err) && !sender.IsBackend(err) },
+		300: sender.IsPermanent,
+		406: sender.IsPermanent,
+	} {
+		code := code
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(fmt.Sprintf(`{"ErrorCode":%d,"Message":"rejected"}`, code)))
+		}))
+
+		s, err := postmark.NewPostmark(postmark.Params{BaseURL: server.URL})
+		require.Nil(t, err)
+
+		err = s.Send(nil, "dest@example.com", "hello", []byte("text"), nil)
+		sendErr := &sender.Error{}
+		require.True(t, errors.As(err, &sendErr), code)
+		require.Equal(t, http.StatusOK, sendErr.Code)
+		require.True(t, check(err), code)
+		server.Close()
+	}
+}
@@ -0,0 +1,122 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type (
+	// Memory is an in memory Store, the messages are lost when the process stops.
+	Memory struct {
+		mu       sync.Mutex
+		messages []*Message
+		notify   chan struct{}
+		now      func() time.Time
+	}
+)
+
+func NewMemory() *Memory {
+	return &Memory{
+		messages: []*Message{},
+		notify:   make(chan struct{}, 1),
+		now:      time.Now,
+	}
+}
+
+func (m *Memory) signal() {
+	select {
+	case m.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Memory) Push(ctx context.Context, msg *Message) error {
+	m.mu.Lock()
+	m.messages = append(m.messages, msg)
+	m.mu.Unlock()
+
+	m.signal()
+	return nil
+}
+
+func (m *Memory) Offer(ctx context.Context, msg *Message, capacity int) (bool, error) {
+	m.mu.Lock()
+	if len(m.messages) >= capacity {
+		m.mu.Unlock()
+		return false, nil
+	}
+	m.messages = append(m.messages, msg)
+	m.mu.Unlock()
+
+	m.signal()
+	return true, nil
+}
+
+func (m *Memory) Pop(ctx context.Context) (*Message, error) {
+	var err error
+	for {
+		msg, next := m.next()
+		if msg != nil {
+			return msg, nil
+		}
+
+		var timer *time.Timer
+		var wait <-chan time.Time
+		if !next.IsZero() {
+			timer = time.NewTimer(next.Sub(m.now()))
+			wait = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-m.notify:
+		case <-wait:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// next removes the first available message, otherwise it returns the time at which the next one will be available,
+// if any.
+func (m *Memory) next() (*Message, time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	var next time.Time
+	for i, msg := range m.messages {
+		if msg.NotBefore.After(now) {
+			if next.IsZero() || msg.NotBefore.Before(next) {
+				next = msg.NotBefore
+			}
+			continue
+		}
+
+		copy(m.messages[i:], m.messages[i+1:])
+		m.messages[len(m.messages)-1] = nil
+		m.messages = m.messages[:len(m.messages)-1]
+		if len(m.messages) > 0 {
+			// wake up another waiting worker
+			m.signal()
+		}
+		return msg, time.Time{}
+	}
+	return nil, next
+}
+
+func (m *Memory) Ack(ctx context.Context, msg *Message) error {
+	return nil
+}
+
+func (m *Memory) Len(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.messages), nil
+}
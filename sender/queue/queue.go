@@ -0,0 +1,293 @@
+// Package queue sends the messages asynchronously: Send stores the message and returns right away while a bounded
+// pool of workers delivers the messages with the wrapped sender. When the queue is full Send blocks until there is
+// room or its context is done. Close stops accepting new messages and waits for the queue to drain. The temporary
+// failures are sent again after an exponential backoff.
+//
+// The messages are kept in a Store: Memory by default, or redis.Store so that the queued messages survive a restart.
+package queue
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dchest/uniuri"
+	"github.com/fdelbos/mauth/sender"
+)
+
+type (
+	Params struct {
+		// Store defaults to an in memory store.
+		Store Store
+		// Workers is the number of messages sent concurrently, defaults to 4.
+		Workers int
+		// Capacity is the maximum number of messages waiting in the store, defaults to 1000.
+		Capacity int
+		// MaxAttempts is the number of times a message is sent before being dropped when the errors are temporary,
+		// defaults to 3.
+		MaxAttempts int
+		// RetryDelay is the time before sending a message again after its first temporary failure, it doubles with
+		// each attempt. Defaults to 10 seconds.
+		RetryDelay time.Duration
+		// MaxRetryDelay caps the time between two attempts, defaults to 5 minutes.
+		MaxRetryDelay time.Duration
+		// SendTimeout limits the time spent sending each message, defaults to 1 minute.
+		SendTimeout time.Duration
+		Logger      *log.Logger
+	}
+
+	Queue struct {
+		sender        sender.Sender
+		store         Store
+		capacity      int
+		maxAttempts   int
+		retryDelay    time.Duration
+		maxRetryDelay time.Duration
+		sendTimeout   time.Duration
+		log           *log.Logger
+
+		ctx     context.Context
+		cancel  context.CancelFunc
+		workers sync.WaitGroup
+		mu      sync.RWMutex
+		closed  bool
+		// sending counts the Send calls that haven't stored their message yet
+		sending  int64
+		inflight int64
+		freed    chan struct{}
+	}
+)
+
+const (
+	DefaultWorkers       = 4
+	DefaultCapacity      = 1000
+	DefaultMaxAttempts   = 3
+	DefaultRetryDelay    = 10 * time.Second
+	DefaultMaxRetryDelay = 5 * time.Minute
+	DefaultSendTimeout   = time.Minute
+
+	pollInterval = 50 * time.Millisecond
+)
+
+var (
+	ErrSenderNil = errors.New("sender cannot be nil")
+)
+
+// NewQueue creates the queue and starts its workers.
+func NewQueue(s sender.Sender, params Params) (*Queue, error) {
+	if s == nil {
+		return nil, ErrSenderNil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &Queue{
+		sender:        s,
+		store:         params.Store,
+		capacity:      params.Capacity,
+		maxAttempts:   params.MaxAttempts,
+		retryDelay:    params.RetryDelay,
+		maxRetryDelay: params.MaxRetryDelay,
+		sendTimeout:   params.SendTimeout,
+		log:           params.Logger,
+		ctx:           ctx,
+		cancel:        cancel,
+		freed:         make(chan struct{}, 1),
+	}
+	if q.store == nil {
+		q.store = NewMemory()
+	}
+	if q.capacity <= 0 {
+		q.capacity = DefaultCapacity
+	}
+	if q.maxAttempts <= 0 {
+		q.maxAttempts = DefaultMaxAttempts
+	}
+	if q.retryDelay <= 0 {
+		q.retryDelay = DefaultRetryDelay
+	}
+	if q.maxRetryDelay <= 0 {
+		q.maxRetryDelay = DefaultMaxRetryDelay
+	}
+	if q.sendTimeout <= 0 {
+		q.sendTimeout = DefaultSendTimeout
+	}
+	if q.log == nil {
+		q.log = log.New(os.Stdout, "mauth queue", log.LstdFlags)
+	}
+
+	workers := params.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	q.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q, nil
+}
+
+// Send queues the message, it returns ErrFull if there is still no room in the queue when the context is done.
+func (q *Queue) Send(ctx context.Context, address, subject string, txt, html []byte) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	// Close waits for the calls counted before it marked the queue closed, so that their messages are delivered
+	q.mu.RLock()
+	if q.closed {
+		q.mu.RUnlock()
+		return ErrClosed
+	}
+	atomic.AddInt64(&q.sending, 1)
+	q.mu.RUnlock()
+	defer atomic.AddInt64(&q.sending, -1)
+
+	msg := &Message{
+		ID:      uniuri.New(),
+		Address: address,
+		Subject: subject,
+		TXT:     txt,
+		HTML:    html,
+	}
+	for {
+		ok, err := q.store.Offer(ctx, msg, q.capacity)
+		if err != nil || ok {
+			return err
+		}
+
+		timer := time.NewTimer(pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ErrFull
+		case <-q.ctx.Done():
+			// Close gave up waiting
+			timer.Stop()
+			return ErrClosed
+		case <-q.freed:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// Len returns the number of messages waiting to be sent.
+func (q *Queue) Len(ctx context.Context) (int, error) {
+	return q.store.Len(ctx)
+}
+
+// Close stops accepting messages and waits until all the queued messages are sent. If the context is done first,
+// the deliveries in progress are canceled and the remaining messages are left in the store.
+func (q *Queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+
+	err := q.drain(ctx)
+	q.cancel()
+	q.workers.Wait()
+	return err
+}
+
+func (q *Queue) drain(ctx context.Context) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	// the queue must be seen empty twice in a row, in case a worker just popped a message
+	empty := 0
+	for empty < 2 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		if atomic.LoadInt64(&q.sending) != 0 {
+			empty = 0
+			continue
+		}
+		n, err := q.store.Len(ctx)
+		if err == nil && n == 0 && atomic.LoadInt64(&q.inflight) == 0 {
+			empty++
+		} else {
+			empty = 0
+		}
+	}
+	return nil
+}
+
+func (q *Queue) work() {
+	defer q.workers.Done()
+
+	for {
+		msg, err := q.store.Pop(q.ctx)
+		if err != nil {
+			if q.ctx.Err() != nil {
+				return
+			}
+			q.log.Printf("error while reading the queue: '%s'", err)
+			select {
+			case <-q.ctx.Done():
+				return
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+
+		atomic.AddInt64(&q.inflight, 1)
+		select {
+		case q.freed <- struct{}{}:
+		default:
+		}
+		q.deliver(msg)
+		atomic.AddInt64(&q.inflight, -1)
+	}
+}
+
+func (q *Queue) deliver(msg *Message) {
+	ctx, cancel := context.WithTimeout(q.ctx, q.sendTimeout)
+	defer cancel()
+
+	err := q.sender.Send(ctx, msg.Address, msg.Subject, msg.TXT, msg.HTML)
+	if err != nil && q.ctx.Err() != nil {
+		// the queue has been closed before the end of the delivery, the message is not acknowledged so that a
+		// persistent store can deliver it again
+		return
+	}
+
+	if err != nil {
+		attempts := msg.Attempts + 1
+		// only the errors classified as temporary are retried, the other ones may come after the message was
+		// accepted
+		if sender.IsTemporary(err) && attempts < q.maxAttempts {
+			retry := *msg
+			retry.Attempts = attempts
+			retry.NotBefore = time.Now().Add(q.backoff(attempts))
+			if err := q.store.Push(q.ctx, &retry); err != nil {
+				q.log.Printf("error while queuing the message %s again: '%s'", msg.ID, err)
+			}
+		} else {
+			q.log.Printf("dropping the message %s after %d attempt(s): '%s'", msg.ID, attempts, err)
+		}
+	}
+
+	if err := q.store.Ack(q.ctx, msg); err != nil {
+		q.log.Printf("error while acknowledging the message %s: '%s'", msg.ID, err)
+	}
+}
+
+// backoff returns the time to wait before the next attempt, after the given number of attempts.
+func (q *Queue) backoff(attempts int) time.Duration {
+	res := q.retryDelay
+	for i := 1; i < attempts && res < q.maxRetryDelay; i++ {
+		res *= 2
+	}
+	if res > q.maxRetryDelay {
+		res = q.maxRetryDelay
+	}
+	return res
+}
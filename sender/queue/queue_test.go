@@ -0,0 +1,207 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fdelbos/mauth/sender"
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	fakeSender struct {
+		mu    sync.Mutex
+		sent  []string
+		errs  []error
+		times []time.Time
+		block chan struct{}
+	}
+)
+
+var (
+	logger = log.New(ioutil.Discard, "", 0)
+)
+
+func (f *fakeSender) Send(ctx context.Context, address, subject string, txt, html []byte) error {
+	if f.block != nil {
+		select {
+		case <-f.block:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.times = append(f.times, time.Now())
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return err
+	}
+	f.sent = append(f.sent, address)
+	return nil
+}
+
+func (f *fakeSender) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.sent)
+}
+
+func TestSendAndClose(t *testing.T) {
+	s := &fakeSender{}
+	q, err := NewQueue(s, Params{Workers: 2, Logger: logger})
+	require.Nil(t, err)
+
+	for i := 0; i < 20; i++ {
+		require.Nil(t, q.Send(nil, "dest@example.com", "hello", []byte("text"), nil))
+	}
+
+	require.Nil(t, q.Close(context.Background()))
+	require.Equal(t, 20, s.count())
+	require.Equal(t, ErrClosed, q.Send(nil, "dest@example.com", "hello", []byte("text"), nil))
+}
+
+func TestRetryTemporary(t *testing.T) {
+	s := &fakeSender{errs: []error{
+		&sender.Error{Code: 421, Err: context.DeadlineExceeded},
+		&sender.Error{Code: 550, Permanent: true, Err: context.DeadlineExceeded},
+	}}
+	q, err := NewQueue(s, Params{Workers: 1, RetryDelay: time.Millisecond, Logger: logger})
+	require.Nil(t, err)
+
+	require.Nil(t, q.Send(nil, "first@example.com", "hello", []byte("text"), nil))
+	require.Nil(t, q.Send(nil, "second@example.com", "hello", []byte("text"), nil))
+	require.Nil(t, q.Close(context.Background()))
+
+	// the first message failed temporarily and was sent again, the second one was dropped after a permanent error
+	require.Equal(t, []string{"first@example.com"}, s.sent)
+}
+
+func TestRetryNotClassified(t *testing.T) {
+	// ie: the response of the provider can't be decoded, the message may have been accepted
+	s := &fakeSender{errs: []error{errors.New("invalid response")}}
+	q, err := NewQueue(s, Params{Workers: 1, RetryDelay: time.Millisecond, Logger: logger})
+	require.Nil(t, err)
+
+	require.Nil(t, q.Send(nil, "dest@example.com", "hello", []byte("text"), nil))
+	require.Nil(t, q.Close(context.Background()))
+	require.Empty(t, s.sent)
+	require.Len(t, s.times, 1)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	s := &fakeSender{errs: []error{
+		&sender.Error{Code: 421, Err: context.DeadlineExceeded},
+		&sender.Error{Code: 421, Err: context.DeadlineExceeded},
+	}}
+	q, err := NewQueue(s, Params{Workers: 1, RetryDelay: 50 * time.Millisecond, Logger: logger})
+	require.Nil(t, err)
+
+	require.Nil(t, q.Send(nil, "dest@example.com", "hello", []byte("text"), nil))
+	require.Nil(t, q.Close(context.Background()))
+
+	// the delay doubles with each attempt
+	require.Equal(t, []string{"dest@example.com"}, s.sent)
+	require.Len(t, s.times, 3)
+	require.True(t, s.times[1].Sub(s.times[0]) >= 50*time.Millisecond)
+	require.True(t, s.times[2].Sub(s.times[1]) >= 100*time.Millisecond)
+}
+
+func TestBackpressure(t *testing.T) {
+	s := &fakeSender{block: make(chan struct{})}
+	q, err := NewQueue(s, Params{Workers: 1, Capacity: 1, Logger: logger})
+	require.Nil(t, err)
+
+	// the first message is held by the worker, the second one fills the queue
+	require.Nil(t, q.Send(nil, "dest@example.com", "hello", []byte("text"), nil))
+	require.Eventually(t, func() bool {
+		n, _ := q.Len(context.Background())
+		return n == 0
+	}, time.Second, time.Millisecond)
+	require.Nil(t, q.Send(nil, "dest@example.com", "hello", []byte("text"), nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	require.Equal(t, ErrFull, q.Send(ctx, "dest@example.com", "hello", []byte("text"), nil))
+
+	close(s.block)
+	require.Nil(t, q.Close(context.Background()))
+	require.Equal(t, 2, s.count())
+}
+
+func TestCloseTimeout(t *testing.T) {
+	s := &fakeSender{block: make(chan struct{})}
+	q, err := NewQueue(s, Params{Workers: 1, Logger: logger})
+	require.Nil(t, err)
+
+	require.Nil(t, q.Send(nil, "dest@example.com", "hello", []byte("text"), nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, q.Close(ctx))
+	require.Equal(t, 0, s.count())
+}
+
+func TestCapacity(t *testing.T) {
+	s := &fakeSender{block: make(chan struct{})}
+	q, err := NewQueue(s, Params{Workers: 1, Capacity: 2, Logger: logger})
+	require.Nil(t, err)
+
+	require.Nil(t, q.Send(nil, "dest@example.com", "hello", []byte("text"), nil))
+	require.Eventually(t, func() bool {
+		n, _ := q.Len(context.Background())
+		return n == 0
+	}, time.Second, time.Millisecond)
+
+	// the concurrent calls can't go over the capacity
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	var accepted int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if q.Send(ctx, "dest@example.com", "hello", []byte("text"), nil) == nil {
+				atomic.AddInt64(&accepted, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int64(2), accepted)
+
+	close(s.block)
+	require.Nil(t, q.Close(context.Background()))
+	require.Equal(t, 3, s.count())
+}
+
+func TestSendWhileClosing(t *testing.T) {
+	s := &fakeSender{}
+	q, err := NewQueue(s, Params{Workers: 2, Logger: logger})
+	require.Nil(t, err)
+
+	// every accepted message is delivered, even the ones sent while the queue closes
+	var accepted int64
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for q.Send(nil, "dest@example.com", "hello", []byte("text"), nil) == nil {
+				atomic.AddInt64(&accepted, 1)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	require.Nil(t, q.Close(context.Background()))
+	wg.Wait()
+	require.Equal(t, int(accepted), s.count())
+}
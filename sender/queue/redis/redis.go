@@ -0,0 +1,185 @@
+// Package redis implements a queue.Store backed by a Redis list, so that the queued messages survive a restart.
+// A popped message is moved to a processing list until it is acknowledged. Messages left in the processing list by a
+// process that stopped abruptly are not sent again automatically: call Recover at startup, before starting the
+// queues, to queue them again. The messages not available yet wait in a sorted set until their NotBefore time.
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/fdelbos/mauth/sender/queue"
+	goredis "github.com/go-redis/redis/v8"
+)
+
+type (
+	Params struct {
+		Client goredis.UniversalClient
+		// Key is the name of the list holding the messages, defaults to "mauth:queue".
+		Key string
+	}
+
+	Store struct {
+		client     goredis.UniversalClient
+		key        string
+		processing string
+		delayed    string
+		popped     *popped
+	}
+
+	// popped keeps the values of the messages being processed, as read from the list, to remove them when acknowledged
+	popped struct {
+		mu     sync.Mutex
+		values map[*queue.Message][]byte
+	}
+)
+
+const (
+	DefaultKey = "mauth:queue"
+
+	popTimeout = time.Second
+)
+
+var (
+	ErrClientNil = errors.New("redis client cannot be nil")
+	ErrNotPopped = errors.New("message was not popped from this store")
+
+	// offer pushes the message if the list and the sorted set hold less than capacity messages.
+	offer = goredis.NewScript(`
+if redis.call("LLEN", KEYS[1]) + redis.call("ZCARD", KEYS[2]) >= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call("LPUSH", KEYS[1], ARGV[1])
+return 1
+`)
+
+	// promote moves the messages available at the given time from the sorted set to the list.
+	promote = goredis.NewScript(`
+local due = redis.call("ZRANGEBYSCORE", KEYS[2], "-inf", ARGV[1])
+for _, value in ipairs(due) do
+	redis.call("LPUSH", KEYS[1], value)
+end
+if #due > 0 then
+	redis.call("ZREMRANGEBYSCORE", KEYS[2], "-inf", ARGV[1])
+end
+return #due
+`)
+)
+
+func NewStore(params Params) (*Store, error) {
+	if params.Client == nil {
+		return nil, ErrClientNil
+	}
+	key := params.Key
+	if key == "" {
+		key = DefaultKey
+	}
+	return &Store{
+		client:     params.Client,
+		key:        key,
+		processing: key + ":processing",
+		delayed:    key + ":delayed",
+		popped:     &popped{values: map[*queue.Message][]byte{}},
+	}, nil
+}
+
+func (s Store) Push(ctx context.Context, msg *queue.Message) error {
+	value, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if msg.NotBefore.After(time.Now()) {
+		return s.client.ZAdd(ctx, s.delayed, &goredis.Z{Score: score(msg.NotBefore), Member: value}).Err()
+	}
+	return s.client.LPush(ctx, s.key, value).Err()
+}
+
+func (s Store) Offer(ctx context.Context, msg *queue.Message, capacity int) (bool, error) {
+	value, err := json.Marshal(msg)
+	if err != nil {
+		return false, err
+	}
+	res, err := offer.Run(ctx, s.client, []string{s.key, s.delayed}, value, capacity).Int()
+	return res == 1, err
+}
+
+func score(t time.Time) float64 {
+	return float64(t.UnixNano() / int64(time.Millisecond))
+}
+
+func (s Store) Pop(ctx context.Context) (*queue.Message, error) {
+	for {
+		err := promote.Run(ctx, s.client, []string{s.key, s.delayed}, score(time.Now())).Err()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+
+		value, err := s.client.BRPopLPush(ctx, s.key, s.processing, popTimeout).Bytes()
+		if err == goredis.Nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		} else if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+
+		msg := &queue.Message{}
+		if err := json.Unmarshal(value, msg); err != nil {
+			// not a message, remove it so it doesn't block the processing list
+			s.client.LRem(ctx, s.processing, 1, value)
+			return nil, err
+		}
+
+		s.popped.mu.Lock()
+		s.popped.values[msg] = value
+		s.popped.mu.Unlock()
+		return msg, nil
+	}
+}
+
+// Ack removes the message returned by Pop from the processing list.
+func (s Store) Ack(ctx context.Context, msg *queue.Message) error {
+	s.popped.mu.Lock()
+	value, ok := s.popped.values[msg]
+	delete(s.popped.values, msg)
+	s.popped.mu.Unlock()
+	if !ok {
+		return ErrNotPopped
+	}
+	return s.client.LRem(ctx, s.processing, 1, value).Err()
+}
+
+func (s Store) Len(ctx context.Context) (int, error) {
+	n, err := s.client.LLen(ctx, s.key).Result()
+	if err != nil {
+		return 0, err
+	}
+	delayed, err := s.client.ZCard(ctx, s.delayed).Result()
+	return int(n + delayed), err
+}
+
+// Recover queues again the messages left in the processing list, and returns their number. It must be called at
+// startup, before starting the queues using this store: the messages being processed by a running queue would be
+// sent twice.
+func (s Store) Recover(ctx context.Context) (int, error) {
+	count := 0
+	for {
+		err := s.client.RPopLPush(ctx, s.processing, s.key).Err()
+		if err == goredis.Nil {
+			return count, nil
+		} else if err != nil {
+			return count, err
+		}
+		count++
+	}
+}
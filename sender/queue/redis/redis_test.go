@@ -0,0 +1,131 @@
+package redis_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dchest/uniuri"
+	"github.com/fdelbos/mauth/sender/queue"
+	"github.com/fdelbos/mauth/sender/queue/redis"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/suite"
+)
+
+type (
+	RedisSuite struct {
+		suite.Suite
+		client *goredis.Client
+		store  *redis.Store
+		key    string
+	}
+)
+
+func TestRedisSuite(t *testing.T) {
+	// REDIS_ADDR is set by the CI, the tests fail when it is set and redis is unreachable
+	if os.Getenv("REDIS_ADDR") == "" {
+		t.Skip("REDIS_ADDR is not set")
+	}
+	suite.Run(t, &RedisSuite{})
+}
+
+func (s *RedisSuite) SetupTest() {
+	s.client = goredis.NewClient(&goredis.Options{Addr: os.Getenv("REDIS_ADDR")})
+	s.Require().Nil(s.client.Ping(context.Background()).Err())
+
+	s.key = "mauth:test:" + uniuri.New()
+	var err error
+	s.store, err = redis.NewStore(redis.Params{Client: s.client, Key: s.key})
+	s.Require().Nil(err)
+}
+
+func (s *RedisSuite) TearDownTest() {
+	s.client.Del(context.Background(), s.key, s.key+":processing", s.key+":delayed")
+	s.client.Close()
+}
+
+func (s *RedisSuite) TestPushPopAck() {
+	ctx := context.Background()
+	msg := &queue.Message{ID: uniuri.New(), Address: "dest@example.com", Subject: "hello", TXT: []byte("text")}
+	s.Require().Nil(s.store.Push(ctx, msg))
+
+	n, err := s.store.Len(ctx)
+	s.Require().Nil(err)
+	s.Require().Equal(1, n)
+
+	popped, err := s.store.Pop(ctx)
+	s.Require().Nil(err)
+	s.Require().Equal(msg, popped)
+
+	s.Require().Equal(int64(1), s.client.LLen(ctx, s.key+":processing").Val())
+	s.Require().Nil(s.store.Ack(ctx, popped))
+	s.Require().Equal(int64(0), s.client.LLen(ctx, s.key+":processing").Val())
+	s.Require().Equal(redis.ErrNotPopped, s.store.Ack(ctx, popped))
+}
+
+func (s *RedisSuite) TestAckModified() {
+	ctx := context.Background()
+	s.Require().Nil(s.store.Push(ctx, &queue.Message{ID: uniuri.New(), Address: "dest@example.com"}))
+
+	popped, err := s.store.Pop(ctx)
+	s.Require().Nil(err)
+
+	// the value read from the list is removed, whatever happened to the message
+	popped.Attempts++
+	s.Require().Nil(s.store.Ack(ctx, popped))
+	s.Require().Equal(int64(0), s.client.LLen(ctx, s.key+":processing").Val())
+}
+
+func (s *RedisSuite) TestRecover() {
+	ctx := context.Background()
+	s.Require().Nil(s.store.Push(ctx, &queue.Message{ID: uniuri.New(), Address: "dest@example.com"}))
+	_, err := s.store.Pop(ctx)
+	s.Require().Nil(err)
+
+	// the process stopped without acknowledging the message
+	count, err := s.store.Recover(ctx)
+	s.Require().Nil(err)
+	s.Require().Equal(1, count)
+
+	n, err := s.store.Len(ctx)
+	s.Require().Nil(err)
+	s.Require().Equal(1, n)
+}
+
+func (s *RedisSuite) TestPopCanceled() {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := s.store.Pop(ctx)
+	s.Require().NotNil(err)
+}
+
+func (s *RedisSuite) TestOffer() {
+	ctx := context.Background()
+	ok, err := s.store.Offer(ctx, &queue.Message{ID: uniuri.New()}, 2)
+	s.Require().Nil(err)
+	s.Require().True(ok)
+	s.Require().Nil(s.store.Push(ctx, &queue.Message{ID: uniuri.New(), NotBefore: time.Now().Add(time.Hour)}))
+
+	// the delayed messages count for the capacity
+	ok, err = s.store.Offer(ctx, &queue.Message{ID: uniuri.New()}, 2)
+	s.Require().Nil(err)
+	s.Require().False(ok)
+}
+
+func (s *RedisSuite) TestNotBefore() {
+	ctx := context.Background()
+	msg := &queue.Message{ID: uniuri.New(), NotBefore: time.Now().Add(500 * time.Millisecond)}
+	s.Require().Nil(s.store.Push(ctx, msg))
+
+	n, err := s.store.Len(ctx)
+	s.Require().Nil(err)
+	s.Require().Equal(1, n)
+
+	popped, err := s.store.Pop(ctx)
+	s.Require().Nil(err)
+	s.Require().Equal(msg.ID, popped.ID)
+	// the sorted set has a millisecond precision
+	s.Require().False(time.Now().Before(msg.NotBefore.Add(-time.Millisecond)))
+}
@@ -0,0 +1,42 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type (
+	// Message is a message waiting to be sent.
+	Message struct {
+		ID       string
+		Address  string
+		Subject  string
+		TXT      []byte
+		HTML     []byte
+		Attempts int
+		// NotBefore is the time before which the message must not be popped, when it is sent again after a temporary
+		// failure.
+		NotBefore time.Time
+	}
+
+	// Store persists the messages of the queue.
+	Store interface {
+		Push(ctx context.Context, msg *Message) error
+		// Offer pushes the message only if the store holds less than capacity messages, the check and the push are
+		// atomic. It returns false when the store is full.
+		Offer(ctx context.Context, msg *Message, capacity int) (bool, error)
+		// Pop blocks until a message is available or the context is done, in which case it returns the context
+		// error. The messages are not available before their NotBefore time.
+		Pop(ctx context.Context) (*Message, error)
+		// Ack is called once a message returned by Pop has been processed, the store can then forget it.
+		Ack(ctx context.Context, msg *Message) error
+		// Len returns the number of messages in the store, including the ones not available yet.
+		Len(ctx context.Context) (int, error)
+	}
+)
+
+var (
+	ErrClosed = errors.New("queue is closed")
+	ErrFull   = errors.New("queue is full")
+)
@@ -0,0 +1,129 @@
+// Package retry wraps a sender to retry the temporary failures with a jittered exponential backoff. The other errors
+// are returned right away: the permanent ones (see sender.IsPermanent), the failures of the sender itself (see
+// sender.IsBackend) which don't go away with a retry, and the unclassified ones which may come after the message was
+// accepted. No retry is attempted if it would end after the context deadline.
+package retry
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/fdelbos/mauth/sender"
+)
+
+type (
+	Params struct {
+		// MaxAttempts is the total number of attempts, defaults to 3.
+		MaxAttempts int
+		// BaseDelay is the maximum delay before the first retry, it doubles with each retry. Defaults to 500ms.
+		BaseDelay time.Duration
+		// MaxDelay caps the delay between two attempts, defaults to 30 seconds.
+		MaxDelay time.Duration
+		Logger   *log.Logger
+	}
+
+	Retry struct {
+		sender      sender.Sender
+		maxAttempts int
+		baseDelay   time.Duration
+		maxDelay    time.Duration
+		log         *log.Logger
+	}
+)
+
+const (
+	DefaultMaxAttempts = 3
+	DefaultBaseDelay   = 500 * time.Millisecond
+	DefaultMaxDelay    = 30 * time.Second
+)
+
+var (
+	ErrSenderNil = errors.New("sender cannot be nil")
+)
+
+func NewRetry(s sender.Sender, params Params) (*Retry, error) {
+	if s == nil {
+		return nil, ErrSenderNil
+	}
+
+	res := &Retry{
+		sender:      s,
+		maxAttempts: params.MaxAttempts,
+		baseDelay:   params.BaseDelay,
+		maxDelay:    params.MaxDelay,
+		log:         params.Logger,
+	}
+	if res.maxAttempts <= 0 {
+		res.maxAttempts = DefaultMaxAttempts
+	}
+	if res.baseDelay <= 0 {
+		res.baseDelay = DefaultBaseDelay
+	}
+	if res.maxDelay <= 0 {
+		res.maxDelay = DefaultMaxDelay
+	}
+	if res.log == nil {
+		res.log = log.New(os.Stdout, "mauth retry", log.LstdFlags)
+	}
+	return res, nil
+}
+
+func (r Retry) Send(ctx context.Context, address, subject string, txt, html []byte) error {
+	_, err := r.SendWithReceipt(ctx, address, subject, txt, html)
+	return err
+}
+
+func (r Retry) SendWithReceipt(ctx context.Context, address, subject string, txt, html []byte) (*sender.Receipt, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var err error
+	for attempt := 0; attempt < r.maxAttempts; attempt++ {
+		if attempt > 0 {
+			delay := r.delay(attempt)
+			if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+				return nil, err
+			}
+
+			r.log.Printf("retrying in %s after error: '%s'", delay, err)
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, err
+			case <-timer.C:
+			}
+		}
+
+		var receipt *sender.Receipt
+		if rs, ok := r.sender.(sender.ReceiptSender); ok {
+			receipt, err = rs.SendWithReceipt(ctx, address, subject, txt, html)
+		} else {
+			err = r.sender.Send(ctx, address, subject, txt, html)
+		}
+		if err == nil {
+			return receipt, nil
+		}
+		if !sender.IsTemporary(err) || sender.IsBackend(err) || ctx.Err() != nil {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+// delay returns a random delay between 0 and baseDelay * 2^(attempt-1), capped to maxDelay ("full jitter").
+func (r Retry) delay(attempt int) time.Duration {
+	max := r.baseDelay
+	for i := 1; i < attempt && max < r.maxDelay; i++ {
+		max *= 2
+	}
+	if max > r.maxDelay {
+		max = r.maxDelay
+	}
+	return time.Duration(rand.Int63n(int64(max) + 1))
+}
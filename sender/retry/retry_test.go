@@ -0,0 +1,104 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"github.com/fdelbos/mauth/sender"
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	fakeSender struct {
+		errs  []error
+		calls int
+	}
+)
+
+var (
+	logger       = log.New(ioutil.Discard, "", 0)
+	errTemporary = &sender.Error{Code: 451, Err: &textproto.Error{Code: 451, Msg: "try again later"}}
+	errPermanent = &sender.Error{Code: 550, Permanent: true, Err: &textproto.Error{Code: 550, Msg: "no such user"}}
+)
+
+func (f *fakeSender) Send(ctx context.Context, address, subject string, txt, html []byte) error {
+	f.calls++
+	if len(f.errs) == 0 {
+		return nil
+	}
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return err
+}
+
+func newRetry(t *testing.T, s sender.Sender) *Retry {
+	r, err := NewRetry(s, Params{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		Logger:      logger,
+	})
+	require.Nil(t, err)
+	return r
+}
+
+func TestRetryTemporary(t *testing.T) {
+	s := &fakeSender{errs: []error{errTemporary, errTemporary}}
+	require.Nil(t, newRetry(t, s).Send(nil, "dest@example.com", "hello", []byte("text"), nil))
+	require.Equal(t, 3, s.calls)
+}
+
+func TestRetryMaxAttempts(t *testing.T) {
+	s := &fakeSender{errs: []error{errTemporary, errTemporary, errTemporary, errTemporary}}
+	err := newRetry(t, s).Send(nil, "dest@example.com", "hello", []byte("text"), nil)
+	require.True(t, sender.IsTemporary(err))
+	require.Equal(t, 3, s.calls)
+}
+
+func TestRetryPermanent(t *testing.T) {
+	s := &fakeSender{errs: []error{errPermanent}}
+	err := newRetry(t, s).Send(nil, "dest@example.com", "hello", []byte("text"), nil)
+	require.True(t, sender.IsPermanent(err))
+	require.True(t, errors.Is(err, errPermanent))
+	require.Equal(t, 1, s.calls)
+}
+
+func TestRetryNotClassified(t *testing.T) {
+	for _, sendErr := range []error{
+		// ie: the response of the provider can't be decoded, the message may have been accepted
+		errors.New("invalid response"),
+		&sender.Error{Code: 535, Backend: true, Err: &textproto.Error{Code: 535, Msg: "authentication failed"}},
+	} {
+		s := &fakeSender{errs: []error{sendErr}}
+		err := newRetry(t, s).Send(nil, "dest@example.com", "hello", []byte("text"), nil)
+		require.Equal(t, sendErr, err)
+		require.Equal(t, 1, s.calls)
+	}
+}
+
+func TestRetryDeadline(t *testing.T) {
+	s := &fakeSender{errs: []error{errTemporary, errTemporary}}
+	r, err := NewRetry(s, Params{BaseDelay: time.Hour, MaxDelay: time.Hour, Logger: logger})
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	// the backoff would end after the deadline, so it gives up without waiting
+	start := time.Now()
+	require.Equal(t, errTemporary, r.Send(ctx, "dest@example.com", "hello", []byte("text"), nil))
+	require.True(t, time.Since(start) < time.Second)
+}
+
+func TestDelay(t *testing.T) {
+	r := Retry{baseDelay: time.Second, maxDelay: 4 * time.Second}
+	for i := 0; i < 100; i++ {
+		require.True(t, r.delay(1) <= time.Second)
+		require.True(t, r.delay(2) <= 2*time.Second)
+		require.True(t, r.delay(10) <= 4*time.Second)
+	}
+}
@@ -0,0 +1,124 @@
+// Package sendgrid sends email messages with the SendGrid v3 Mail Send API.
+package sendgrid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/fdelbos/mauth/sender"
+	"github.com/fdelbos/mauth/sender/internal/httpapi"
+)
+
+type (
+	Params struct {
+		// BaseURL defaults to DefaultBaseURL.
+		BaseURL string
+		APIKey  string
+		From    string
+		// HTTPClient defaults to http.DefaultClient.
+		HTTPClient *http.Client
+		// MaxRetries is the number of retries when rate limited, defaults to 3, use a negative value to disable.
+		MaxRetries int
+		Logger     *log.Logger
+	}
+
+	SendGrid struct {
+		client  httpapi.Client
+		baseURL string
+		apiKey  string
+		from    string
+		log     *log.Logger
+	}
+
+	address struct {
+		Email string `json:"email"`
+	}
+
+	content struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	}
+
+	personalization struct {
+		To []address `json:"to"`
+	}
+
+	mailSend struct {
+		Personalizations []personalization `json:"personalizations"`
+		From             address           `json:"from"`
+		Subject          string            `json:"subject"`
+		Content          []content         `json:"content"`
+	}
+)
+
+const (
+	Name           = "sendgrid"
+	DefaultBaseURL = "https://api.sendgrid.com"
+)
+
+func NewSendGrid(params Params) (*SendGrid, error) {
+	res := &SendGrid{
+		client:  httpapi.NewClient(params.HTTPClient, params.MaxRetries),
+		baseURL: strings.TrimRight(params.BaseURL, "/"),
+		apiKey:  params.APIKey,
+		from:    params.From,
+		log:     params.Logger,
+	}
+	if res.baseURL == "" {
+		res.baseURL = DefaultBaseURL
+	}
+	if res.log == nil {
+		res.log = log.New(os.Stdout, "mauth sendgrid", log.LstdFlags)
+	}
+	return res, nil
+}
+
+func (s SendGrid) Send(ctx context.Context, address, subject string, txt, html []byte) error {
+	_, err := s.SendWithReceipt(ctx, address, subject, txt, html)
+	return err
+}
+
+func (s SendGrid) SendWithReceipt(ctx context.Context, to, subject string, txt, html []byte) (*sender.Receipt, error) {
+	msg := mailSend{
+		Personalizations: []personalization{{To: []address{{Email: to}}}},
+		From:             address{Email: s.from},
+		Subject:          subject,
+		Content:          []content{},
+	}
+	// SendGrid requires the text/plain content to come first
+	if txt != nil {
+		msg.Content = append(msg.Content, content{Type: "text/plain", Value: string(txt)})
+	}
+	if html != nil {
+		msg.Content = append(msg.Content, content{Type: "text/html", Value: string(html)})
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(ctx, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+"/v3/mail/send", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		s.log.Printf("error while sending the email: '%s'", err)
+		return nil, err
+	}
+
+	return &sender.Receipt{
+		Sender:    Name,
+		MessageID: resp.Header.Get("X-Message-Id"),
+	}, nil
+}
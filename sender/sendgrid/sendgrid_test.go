@@ -0,0 +1,108 @@
+package sendgrid_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fdelbos/mauth/sender"
+	"github.com/fdelbos/mauth/sender/internal/httpapi"
+	"github.com/fdelbos/mauth/sender/sendgrid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSend(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		require.Equal(t, "/v3/mail/send", r.URL.Path)
+		require.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		msg := map[string]interface{}{}
+		require.Nil(t, json.Unmarshal(body, &msg))
+		require.Equal(t, "hello", msg["subject"])
+		require.Equal(t, map[string]interface{}{"email": "from@example.com"}, msg["from"])
+		require.Equal(t, []interface{}{
+			map[string]interface{}{"type": "text/plain", "value": "text"},
+			map[string]interface{}{"type": "text/html", "value": "<b>html</b>"},
+		}, msg["content"])
+
+		w.Header().Set("X-Message-Id", "sg-id")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	s, err := sendgrid.NewSendGrid(sendgrid.Params{
+		BaseURL: server.URL,
+		APIKey:  "key",
+		From:    "from@example.com",
+	})
+	require.Nil(t, err)
+
+	receipt, err := s.SendWithReceipt(nil, "dest@example.com", "hello", []byte("text"), []byte("<b>html</b>"))
+	require.Nil(t, err)
+	require.Equal(t, 2, calls)
+	require.Equal(t, sendgrid.Name, receipt.Sender)
+	require.Equal(t, "sg-id", receipt.MessageID)
+}
+
+func TestSendRateLimited(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	s, err := sendgrid.NewSendGrid(sendgrid.Params{BaseURL: server.URL, MaxRetries: 2})
+	require.Nil(t, err)
+
+	err = s.Send(nil, "dest@example.com", "hello", []byte("text"), nil)
+	require.True(t, sender.IsTemporary(err))
+	statusErr := &httpapi.StatusError{}
+	require.True(t, errors.As(err, &statusErr))
+	require.Equal(t, http.StatusTooManyRequests, statusErr.StatusCode)
+	require.Equal(t, 3, calls)
+}
+
+func TestSendRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"errors":[{"message":"Does not contain a valid address.","field":"personalizations.0.to.0.email"}]}`))
+	}))
+	defer server.Close()
+
+	s, err := sendgrid.NewSendGrid(sendgrid.Params{BaseURL: server.URL})
+	require.Nil(t, err)
+
+	err = s.Send(nil, "invalid", "hello", []byte("text"), nil)
+	require.True(t, sender.IsPermanent(err))
+}
+
+func TestSendUnauthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"errors":[{"message":"The provided authorization grant is invalid, expired, or revoked"}]}`))
+	}))
+	defer server.Close()
+
+	s, err := sendgrid.NewSendGrid(sendgrid.Params{BaseURL: server.URL})
+	require.Nil(t, err)
+
+	// a revoked key is not the fault of the recipient
+	err = s.Send(nil, "dest@example.com", "hello", []byte("text"), nil)
+	require.True(t, sender.IsBackend(err))
+	require.True(t, sender.IsTemporary(err))
+	require.False(t, sender.IsPermanent(err))
+}
@@ -0,0 +1,163 @@
+// Package ses sends email messages with the Amazon SES v2 API. Requests are signed with AWS Signature Version 4, so
+// no AWS SDK is needed.
+package ses
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fdelbos/mauth/sender"
+	"github.com/fdelbos/mauth/sender/internal/httpapi"
+)
+
+type (
+	Params struct {
+		// BaseURL defaults to the regional endpoint: https://email.<region>.amazonaws.com
+		BaseURL         string
+		Region          string
+		AccessKeyID     string
+		SecretAccessKey string
+		// SessionToken is only needed with temporary credentials.
+		SessionToken string
+		From         string
+		// ConfigurationSet is the optional SES configuration set used for the messages.
+		ConfigurationSet string
+		// HTTPClient defaults to http.DefaultClient.
+		HTTPClient *http.Client
+		// MaxRetries is the number of retries when rate limited, defaults to 3, use a negative value to disable.
+		MaxRetries int
+		Logger     *log.Logger
+	}
+
+	SES struct {
+		client           httpapi.Client
+		baseURL          string
+		region           string
+		accessKeyID      string
+		secretAccessKey  string
+		sessionToken     string
+		from             string
+		configurationSet string
+		log              *log.Logger
+	}
+
+	content struct {
+		Data    string
+		Charset string
+	}
+
+	body struct {
+		Text *content `json:",omitempty"`
+		Html *content `json:",omitempty"`
+	}
+
+	sendEmailRequest struct {
+		FromEmailAddress string
+		Destination      struct {
+			ToAddresses []string
+		}
+		Content struct {
+			Simple struct {
+				Subject content
+				Body    body
+			}
+		}
+		ConfigurationSetName string `json:",omitempty"`
+	}
+
+	sendEmailResponse struct {
+		MessageId string
+	}
+)
+
+const (
+	Name    = "ses"
+	service = "ses"
+	charset = "UTF-8"
+)
+
+var (
+	ErrRegionEmpty = errors.New("region is empty")
+)
+
+func NewSES(params Params) (*SES, error) {
+	if params.Region == "" {
+		return nil, ErrRegionEmpty
+	}
+
+	res := &SES{
+		client:           httpapi.NewClient(params.HTTPClient, params.MaxRetries),
+		baseURL:          strings.TrimRight(params.BaseURL, "/"),
+		region:           params.Region,
+		accessKeyID:      params.AccessKeyID,
+		secretAccessKey:  params.SecretAccessKey,
+		sessionToken:     params.SessionToken,
+		from:             params.From,
+		configurationSet: params.ConfigurationSet,
+		log:              params.Logger,
+	}
+	if res.baseURL == "" {
+		res.baseURL = "https://email." + params.Region + ".amazonaws.com"
+	}
+	if res.log == nil {
+		res.log = log.New(os.Stdout, "mauth ses", log.LstdFlags)
+	}
+	return res, nil
+}
+
+func (s SES) Send(ctx context.Context, address, subject string, txt, html []byte) error {
+	_, err := s.SendWithReceipt(ctx, address, subject, txt, html)
+	return err
+}
+
+func (s SES) SendWithReceipt(ctx context.Context, address, subject string, txt, html []byte) (*sender.Receipt, error) {
+	msg := sendEmailRequest{
+		FromEmailAddress:     s.from,
+		ConfigurationSetName: s.configurationSet,
+	}
+	msg.Destination.ToAddresses = []string{address}
+	msg.Content.Simple.Subject = content{Data: subject, Charset: charset}
+	if txt != nil {
+		msg.Content.Simple.Body.Text = &content{Data: string(txt), Charset: charset}
+	}
+	if html != nil {
+		msg.Content.Simple.Body.Html = &content{Data: string(html), Charset: charset}
+	}
+
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(ctx, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+"/v2/email/outbound-emails", bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		sign(req, payload, s.accessKeyID, s.secretAccessKey, s.sessionToken, s.region, service, time.Now())
+		return req, nil
+	})
+	if err != nil {
+		s.log.Printf("error while sending the email: '%s'", err)
+		return nil, err
+	}
+
+	res := sendEmailResponse{}
+	if err := resp.Decode(&res); err != nil {
+		s.log.Printf("invalid response from ses: '%s'", err)
+		return nil, err
+	}
+
+	return &sender.Receipt{
+		Sender:    Name,
+		MessageID: res.MessageId,
+	}, nil
+}
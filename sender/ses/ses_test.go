@@ -0,0 +1,72 @@
+package ses
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// test vector "get-vanilla" from the AWS Signature Version 4 test suite
+func TestSignVanilla(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	require.Nil(t, err)
+
+	now, err := time.Parse(amzDateFmt, "20150830T123600Z")
+	require.Nil(t, err)
+	sign(req, nil, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "", "us-east-1", "service", now)
+
+	require.Equal(t,
+		"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+			"SignedHeaders=host;x-amz-date, "+
+			"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		req.Header.Get("Authorization"))
+}
+
+func TestSend(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		require.Equal(t, "/v2/email/outbound-emails", r.URL.Path)
+		require.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/"))
+		require.Contains(t, r.Header.Get("Authorization"), "/eu-west-1/ses/aws4_request")
+
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		msg := sendEmailRequest{}
+		require.Nil(t, json.Unmarshal(body, &msg))
+		require.Equal(t, "from@example.com", msg.FromEmailAddress)
+		require.Equal(t, []string{"dest@example.com"}, msg.Destination.ToAddresses)
+		require.Equal(t, "hello", msg.Content.Simple.Subject.Data)
+		require.Equal(t, "text", msg.Content.Simple.Body.Text.Data)
+		require.Nil(t, msg.Content.Simple.Body.Html)
+
+		w.Write([]byte(`{"MessageId":"0100-ses-id"}`))
+	}))
+	defer server.Close()
+
+	s, err := NewSES(Params{
+		BaseURL:         server.URL,
+		Region:          "eu-west-1",
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+		From:            "from@example.com",
+	})
+	require.Nil(t, err)
+
+	receipt, err := s.SendWithReceipt(nil, "dest@example.com", "hello", []byte("text"), nil)
+	require.Nil(t, err)
+	require.Equal(t, 2, calls)
+	require.Equal(t, Name, receipt.Sender)
+	require.Equal(t, "0100-ses-id", receipt.MessageID)
+}
@@ -0,0 +1,127 @@
+// Package sms sends text messages with a Twilio compatible HTTP API. Only the text body of the message is sent, the
+// subject and the html body are ignored.
+package sms
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/fdelbos/mauth/sender"
+	"github.com/fdelbos/mauth/sender/internal/httpapi"
+)
+
+type (
+	Params struct {
+		// BaseURL defaults to DefaultBaseURL, set it to use another Twilio compatible provider or a local stub.
+		BaseURL    string
+		AccountSID string
+		AuthToken  string
+		// From is the sender phone number, it can be left empty when MessagingServiceSID is set.
+		From                string
+		MessagingServiceSID string
+		// HTTPClient defaults to http.DefaultClient.
+		HTTPClient *http.Client
+		// MaxRetries is the number of retries when rate limited, defaults to 3, use a negative value to disable.
+		MaxRetries int
+		Logger     *log.Logger
+	}
+
+	SMS struct {
+		client              httpapi.Client
+		baseURL             string
+		accountSID          string
+		authToken           string
+		from                string
+		messagingServiceSID string
+		log                 *log.Logger
+	}
+
+	response struct {
+		SID string `json:"sid"`
+	}
+)
+
+const (
+	Name           = "sms"
+	DefaultBaseURL = "https://api.twilio.com"
+)
+
+var (
+	ErrBodyEmpty = errors.New("text body is empty")
+	ErrNoFrom    = errors.New("either a from number or a messaging service is required")
+)
+
+func NewSMS(params Params) (*SMS, error) {
+	if params.From == "" && params.MessagingServiceSID == "" {
+		return nil, ErrNoFrom
+	}
+
+	res := &SMS{
+		client:              httpapi.NewClient(params.HTTPClient, params.MaxRetries),
+		baseURL:             strings.TrimRight(params.BaseURL, "/"),
+		accountSID:          params.AccountSID,
+		authToken:           params.AuthToken,
+		from:                params.From,
+		messagingServiceSID: params.MessagingServiceSID,
+		log:                 params.Logger,
+	}
+	if res.baseURL == "" {
+		res.baseURL = DefaultBaseURL
+	}
+	if res.log == nil {
+		res.log = log.New(os.Stdout, "mauth sms", log.LstdFlags)
+	}
+	return res, nil
+}
+
+func (s SMS) Send(ctx context.Context, address, subject string, txt, html []byte) error {
+	_, err := s.SendWithReceipt(ctx, address, subject, txt, html)
+	return err
+}
+
+func (s SMS) SendWithReceipt(ctx context.Context, address, subject string, txt, html []byte) (*sender.Receipt, error) {
+	if len(txt) == 0 {
+		return nil, ErrBodyEmpty
+	}
+
+	form := url.Values{}
+	form.Set("To", address)
+	form.Set("Body", string(txt))
+	if s.messagingServiceSID != "" {
+		form.Set("MessagingServiceSid", s.messagingServiceSID)
+	} else {
+		form.Set("From", s.from)
+	}
+	body := form.Encode()
+	endpoint := s.baseURL + "/2010-04-01/Accounts/" + url.PathEscape(s.accountSID) + "/Messages.json"
+
+	resp, err := s.client.Do(ctx, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.SetBasicAuth(s.accountSID, s.authToken)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	})
+	if err != nil {
+		s.log.Printf("error while sending the sms: '%s'", err)
+		return nil, err
+	}
+
+	res := response{}
+	if err := resp.Decode(&res); err != nil {
+		s.log.Printf("invalid response from the sms provider: '%s'", err)
+		return nil, err
+	}
+
+	return &sender.Receipt{
+		Sender:    Name,
+		MessageID: res.SID,
+	}, nil
+}
@@ -0,0 +1,49 @@
+package sms_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fdelbos/mauth/sender/sms"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSend(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/2010-04-01/Accounts/AC123/Messages.json", r.URL.Path)
+		user, password, ok := r.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "AC123", user)
+		require.Equal(t, "token", password)
+
+		require.Nil(t, r.ParseForm())
+		require.Equal(t, "+14155552671", r.PostForm.Get("To"))
+		require.Equal(t, "+15005550006", r.PostForm.Get("From"))
+		require.Equal(t, "login: https://example.com", r.PostForm.Get("Body"))
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"sid":"SM123","status":"queued"}`))
+	}))
+	defer server.Close()
+
+	s, err := sms.NewSMS(sms.Params{
+		BaseURL:    server.URL,
+		AccountSID: "AC123",
+		AuthToken:  "token",
+		From:       "+15005550006",
+	})
+	require.Nil(t, err)
+
+	receipt, err := s.SendWithReceipt(nil, "+14155552671", "ignored", []byte("login: https://example.com"), nil)
+	require.Nil(t, err)
+	require.Equal(t, sms.Name, receipt.Sender)
+	require.Equal(t, "SM123", receipt.MessageID)
+
+	require.Equal(t, sms.ErrBodyEmpty, s.Send(nil, "+14155552671", "ignored", nil, []byte("<b>html</b>")))
+}
+
+func TestNewSMSNoFrom(t *testing.T) {
+	_, err := sms.NewSMS(sms.Params{AccountSID: "AC123"})
+	require.Equal(t, sms.ErrNoFrom, err)
+}
@@ -0,0 +1,30 @@
+package smtp
+
+import (
+	"errors"
+	"net/textproto"
+
+	"github.com/fdelbos/mauth/sender"
+)
+
+// classify wraps the error in a sender.Error: 5xx replies are permanent, 4xx replies and the connection errors are
+// temporary. The authentication failures are failures of the sender, not of the recipient.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	protoErr := &textproto.Error{}
+	if errors.As(err, &protoErr) {
+		switch protoErr.Code {
+		case 530, 534, 535:
+			return &sender.Error{Code: protoErr.Code, Backend: true, Err: err}
+		}
+		return &sender.Error{
+			Code:      protoErr.Code,
+			Permanent: protoErr.Code >= 500 && protoErr.Code < 600,
+			Err:       err,
+		}
+	}
+	return &sender.Error{Err: err}
+}
@@ -0,0 +1,69 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/textproto"
+	"testing"
+
+	"github.com/fdelbos/mauth/sender"
+	"github.com/fdelbos/mauth/suppression"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	require.Nil(t, classify(nil))
+
+	for _, c := range []struct {
+		err       error
+		code      int
+		permanent bool
+		backend   bool
+	}{
+		{&textproto.Error{Code: 550, Msg: "5.1.1 user unknown"}, 550, true, false},
+		{&textproto.Error{Code: 452, Msg: "4.2.2 mailbox full"}, 452, false, false},
+		{fmt.Errorf("wrapped: %w", &textproto.Error{Code: 554, Msg: "rejected"}), 554, true, false},
+		{errors.New("Mail Error: SMTP Send timed out"), 0, false, false},
+		{&textproto.Error{Code: 535, Msg: "5.7.8 authentication credentials invalid"}, 535, false, true},
+	} {
+		err := classify(c.err)
+		sendErr := &sender.Error{}
+		require.True(t, errors.As(err, &sendErr))
+		require.Equal(t, c.code, sendErr.Code)
+		require.Equal(t, c.permanent, sender.IsPermanent(err))
+		require.Equal(t, !c.permanent, sender.IsTemporary(err))
+		require.Equal(t, c.backend, sender.IsBackend(err))
+		require.True(t, errors.Is(err, c.err))
+	}
+}
+
+func TestSuppress(t *testing.T) {
+	store := suppression.NewMemory()
+	s := SMTP{log: log.New(ioutil.Discard, "", 0), suppression: store}
+
+	s.suppress(nil, "temporary@example.com", classify(&textproto.Error{Code: 452, Msg: "mailbox full"}))
+	s.suppress(nil, "network@example.com", classify(errors.New("connection reset")))
+	s.suppress(nil, "unknown@example.com", classify(&textproto.Error{Code: 550, Msg: "5.1.1 user unknown"}))
+
+	for _, address := range []string{"temporary@example.com", "network@example.com"} {
+		_, err := store.Get(context.Background(), address)
+		require.Equal(t, suppression.ErrNotFound, err)
+	}
+
+	entry, err := store.Get(context.Background(), "unknown@example.com")
+	require.Nil(t, err)
+	require.Equal(t, suppression.ReasonBounce, entry.Reason)
+	require.Contains(t, entry.Details, "5.1.1 user unknown")
+}
+
+func TestVERPEnvelopeFrom(t *testing.T) {
+	// refused before connecting to the server
+	_, err := NewSMTP(Params{From: "bounces+mauth@our.domain", VERP: true})
+	require.Equal(t, ErrVERPEnvelopeFrom, err)
+
+	_, err = NewSMTP(Params{From: "noreply@our.domain", EnvelopeFrom: "bounces+mauth@our.domain", VERP: true})
+	require.Equal(t, ErrVERPEnvelopeFrom, err)
+}
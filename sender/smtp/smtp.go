@@ -4,10 +4,15 @@ package smtp
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"log"
+	netmail "net/mail"
 	"os"
 	"time"
 
+	"github.com/fdelbos/mauth/sender"
+	"github.com/fdelbos/mauth/sender/verp"
+	"github.com/fdelbos/mauth/suppression"
 	mail "github.com/xhit/go-simple-mail/v2"
 )
 
@@ -23,15 +28,26 @@ type (
 		Encryption encryption
 		Auth       auth
 		From       string
-		TimeOut    time.Duration
-		TLSConfig  *tls.Config
-		Logger     *log.Logger
+		// EnvelopeFrom is the address used for the SMTP MAIL FROM command, where the bounces are sent. Defaults to
+		// the From address.
+		EnvelopeFrom string
+		// VERP encodes the recipient in the envelope sender (ie: bounces+user=example.com@our.domain), so that
+		// the bounces can be mapped back to the recipient with verp.Decode.
+		VERP      bool
+		TimeOut   time.Duration
+		TLSConfig *tls.Config
+		Logger    *log.Logger
+		// Suppression, when set, records the addresses rejected with a permanent (5xx) error.
+		Suppression suppression.Store
 	}
 
 	SMTP struct {
-		client *mail.SMTPClient
-		from   string
-		log    *log.Logger
+		client       *mail.SMTPClient
+		from         string
+		envelopeFrom string
+		verp         bool
+		log          *log.Logger
+		suppression  suppression.Store
 	}
 )
 
@@ -45,7 +61,31 @@ const (
 	AuthCRAMMD5
 )
 
+var (
+	ErrInvalidEnvelopeFrom = errors.New("envelope sender must be an email address")
+	ErrVERPEnvelopeFrom    = errors.New("envelope sender cannot contain '+' with VERP")
+)
+
 func NewSMTP(params Params) (*SMTP, error) {
+	envelopeFrom := params.EnvelopeFrom
+	if envelopeFrom == "" {
+		envelopeFrom = params.From
+	}
+	if envelopeFrom != "" {
+		address, err := netmail.ParseAddress(envelopeFrom)
+		if err != nil {
+			return nil, ErrInvalidEnvelopeFrom
+		}
+		envelopeFrom = address.Address
+	} else if params.VERP {
+		return nil, ErrInvalidEnvelopeFrom
+	}
+	if params.VERP {
+		if err := verp.CheckBounceAddress(envelopeFrom); err != nil {
+			return nil, ErrVERPEnvelopeFrom
+		}
+	}
+
 	server := mail.NewSMTPClient()
 	server.Host = params.Host
 	server.Port = params.Port
@@ -85,9 +125,12 @@ func NewSMTP(params Params) (*SMTP, error) {
 	}
 
 	res := &SMTP{
-		client: client,
-		from:   params.From,
-		log:    params.Logger,
+		client:       client,
+		from:         params.From,
+		envelopeFrom: envelopeFrom,
+		verp:         params.VERP,
+		log:          params.Logger,
+		suppression:  params.Suppression,
 	}
 
 	if res.log == nil {
@@ -97,6 +140,8 @@ func NewSMTP(params Params) (*SMTP, error) {
 	return res, nil
 }
 
+// Send sends the message, the errors are returned as a *sender.Error telling apart the permanent rejections (5xx)
+// from the temporary failures.
 func (s SMTP) Send(ctx context.Context, address, subject string, txt, html []byte) error {
 	email := mail.NewMSG()
 	email.AddTo(address)
@@ -114,15 +159,46 @@ func (s SMTP) Send(ctx context.Context, address, subject string, txt, html []byt
 		email.SetBody(mail.TextPlain, string(txt))
 	}
 
-	if err := email.Send(s.client); err != nil {
-		s.log.Printf("error while sending the email: '%s'", err)
-		return err
+	envelopeFrom := s.envelopeFrom
+	if s.verp {
+		encoded, err := verp.Encode(envelopeFrom, address)
+		if err != nil {
+			return &sender.Error{Permanent: true, Err: err}
+		}
+		envelopeFrom = encoded
 	}
 
-	if err := email.GetError(); err != nil {
+	err := email.SendEnvelopeFrom(envelopeFrom, s.client)
+	if err == nil {
+		err = email.GetError()
+	}
+	if err != nil {
 		s.log.Printf("error while sending the email: '%s'", err)
+		err = classify(err)
+		s.suppress(ctx, address, err)
 		return err
 	}
 
 	return nil
 }
+
+// suppress adds the address to the suppression list when the server rejected it permanently.
+func (s SMTP) suppress(ctx context.Context, address string, err error) {
+	sendErr := &sender.Error{}
+	if s.suppression == nil || !errors.As(err, &sendErr) || !sendErr.Permanent || sendErr.Code < 500 {
+		return
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	entry := suppression.Entry{
+		Address:   address,
+		Reason:    suppression.ReasonBounce,
+		Details:   sendErr.Err.Error(),
+		CreatedAt: time.Now(),
+	}
+	if err := s.suppression.Add(ctx, entry); err != nil {
+		s.log.Printf("error while adding '%s' to the suppression list: '%s'", address, err)
+	}
+}
@@ -2,10 +2,89 @@ package sender
 
 import (
 	"context"
+	"errors"
+	"fmt"
 )
 
 type (
 	Sender interface {
 		Send(ctx context.Context, address, subject string, txt, html []byte) error
 	}
+
+	// Receipt describes a message accepted for delivery.
+	Receipt struct {
+		// Sender is the name of the sender that accepted the message (ie: "sendgrid").
+		Sender string
+		// MessageID is the identifier assigned by the provider, if any.
+		MessageID string
+	}
+
+	// ReceiptSender is implemented by the senders able to report how a message was handled.
+	ReceiptSender interface {
+		Sender
+		SendWithReceipt(ctx context.Context, address, subject string, txt, html []byte) (*Receipt, error)
+	}
+
+	// Error is a delivery error classified as permanent (ie: the address doesn't exist) or temporary (ie: the server
+	// is overloaded or unreachable). Use errors.Is with ErrPermanent or ErrTemporary to check it.
+	Error struct {
+		// Code is the SMTP reply code, or the HTTP status code for the API senders, 0 when unknown.
+		Code      int
+		Permanent bool
+		// Backend marks the failures of the sender itself rather than of the recipient (ie: invalid credentials or
+		// suspended account), they are temporary: another sender, or the same one once fixed, may work.
+		Backend bool
+		Err     error
+	}
+)
+
+var (
+	ErrPermanent = errors.New("permanent delivery failure")
+	ErrTemporary = errors.New("temporary delivery failure")
+	ErrBackend   = errors.New("sender failure")
 )
+
+func (e *Error) Error() string {
+	kind := "temporary"
+	if e.Permanent {
+		kind = "permanent"
+	} else if e.Backend {
+		kind = "sender"
+	}
+	if e.Code != 0 {
+		return fmt.Sprintf("%s delivery failure (%d): %s", kind, e.Code, e.Err)
+	}
+	return fmt.Sprintf("%s delivery failure: %s", kind, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrPermanent:
+		return e.Permanent
+	case ErrTemporary:
+		return !e.Permanent
+	case ErrBackend:
+		return e.Backend && !e.Permanent
+	}
+	return false
+}
+
+// IsPermanent reports if sending the same message again is pointless.
+func IsPermanent(err error) bool {
+	return errors.Is(err, ErrPermanent)
+}
+
+// IsTemporary reports if the error has been classified as temporary, sending the message again later may work.
+func IsTemporary(err error) bool {
+	return errors.Is(err, ErrTemporary)
+}
+
+// IsBackend reports if the error comes from the sender itself and not from the recipient (ie: the API key was
+// revoked), see Error.Backend.
+func IsBackend(err error) bool {
+	return errors.Is(err, ErrBackend)
+}
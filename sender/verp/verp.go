@@ -0,0 +1,75 @@
+// Package verp implements Variable Envelope Return Paths: the recipient of a message is encoded in the envelope
+// sender, so that a bounce received on this address tells which recipient failed, even when the bounce itself
+// cannot be parsed. For example the recipient "user@example.com" with the bounce address "bounces@our.domain" gives
+// "bounces+user=example.com@our.domain".
+package verp
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	delimiter = "+"
+	separator = "="
+)
+
+var (
+	ErrInvalidAddress = errors.New("invalid address")
+	ErrNotVERP        = errors.New("address is not a verp address")
+)
+
+func split(address string) (string, string, error) {
+	idx := strings.LastIndex(address, "@")
+	if idx <= 0 || idx == len(address)-1 {
+		return "", "", ErrInvalidAddress
+	}
+	return address[:idx], address[idx+1:], nil
+}
+
+// CheckBounceAddress returns ErrInvalidAddress if the address can't be used to encode the recipients: its local part
+// can't contain the "+" delimiter, Decode would split it there.
+func CheckBounceAddress(bounceAddress string) error {
+	bounceLocal, _, err := split(bounceAddress)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(bounceLocal, delimiter) {
+		return ErrInvalidAddress
+	}
+	return nil
+}
+
+// Encode returns the bounce address for the recipient.
+func Encode(bounceAddress, recipient string) (string, error) {
+	if err := CheckBounceAddress(bounceAddress); err != nil {
+		return "", err
+	}
+	bounceLocal, bounceDomain, _ := split(bounceAddress)
+	local, domain, err := split(recipient)
+	if err != nil {
+		return "", err
+	}
+	return bounceLocal + delimiter + local + separator + domain + "@" + bounceDomain, nil
+}
+
+// Decode returns the recipient encoded in the bounce address.
+func Decode(address string) (string, error) {
+	local, _, err := split(address)
+	if err != nil {
+		return "", err
+	}
+
+	idx := strings.Index(local, delimiter)
+	if idx < 0 {
+		return "", ErrNotVERP
+	}
+	encoded := local[idx+1:]
+
+	// the domain can't contain the separator, the local part may
+	sep := strings.LastIndex(encoded, separator)
+	if sep <= 0 || sep == len(encoded)-1 {
+		return "", ErrNotVERP
+	}
+	return encoded[:sep] + "@" + encoded[sep+1:], nil
+}
@@ -0,0 +1,46 @@
+package verp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	for _, c := range []struct {
+		recipient string
+		encoded   string
+	}{
+		{"user@example.com", "bounces+user=example.com@our.domain"},
+		{"first.last+tag@mail.example.com", "bounces+first.last+tag=mail.example.com@our.domain"},
+		{"a=b@example.com", "bounces+a=b=example.com@our.domain"},
+	} {
+		encoded, err := Encode("bounces@our.domain", c.recipient)
+		require.Nil(t, err)
+		require.Equal(t, c.encoded, encoded)
+
+		decoded, err := Decode(encoded)
+		require.Nil(t, err)
+		require.Equal(t, c.recipient, decoded)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	require.Nil(t, CheckBounceAddress("bounces@our.domain"))
+	require.Equal(t, ErrInvalidAddress, CheckBounceAddress("bounces+x@our.domain"))
+
+	_, err := Encode("bounces+x@our.domain", "user@example.com")
+	require.Equal(t, ErrInvalidAddress, err)
+
+	_, err = Encode("bounces@our.domain", "not an address")
+	require.Equal(t, ErrInvalidAddress, err)
+
+	_, err = Decode("bounces@our.domain")
+	require.Equal(t, ErrNotVERP, err)
+
+	_, err = Decode("bounces+user@our.domain")
+	require.Equal(t, ErrNotVERP, err)
+
+	_, err = Decode("bounces+user=@our.domain")
+	require.Equal(t, ErrNotVERP, err)
+}
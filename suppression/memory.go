@@ -0,0 +1,55 @@
+// Package suppression keeps the list of addresses that must not receive messages anymore, because they hard bounced
+// or marked the messages as spam. Mailing them again damages the reputation of the sender.
+package suppression
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type (
+	// Memory is an in memory Store.
+	Memory struct {
+		mu      sync.RWMutex
+		entries map[string]Entry
+	}
+)
+
+func NewMemory() *Memory {
+	return &Memory{entries: map[string]Entry{}}
+}
+
+func (m *Memory) Add(ctx context.Context, entry Entry) error {
+	key := Key(entry.Address)
+	if key == "" {
+		return ErrAddressEmpty
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	entry.Address = key
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = entry
+	return nil
+}
+
+func (m *Memory) Remove(ctx context.Context, address string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, Key(address))
+	return nil
+}
+
+func (m *Memory) Get(ctx context.Context, address string) (*Entry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, ok := m.entries[Key(address)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &entry, nil
+}
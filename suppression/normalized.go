@@ -0,0 +1,36 @@
+package suppression
+
+import (
+	"context"
+)
+
+type (
+	// Normalizer returns the canonical form of an address, see the normalizer package.
+	Normalizer interface {
+		Normalize(address string) string
+	}
+
+	normalized struct {
+		store      Store
+		normalizer Normalizer
+	}
+)
+
+// Normalized returns a Store recording and looking up the canonical form of the addresses, so that the entries added
+// by the senders and the bounce handlers match the addresses checked by MAuth. Use the Normalizer of MAuth.
+func Normalized(store Store, normalizer Normalizer) Store {
+	return normalized{store: store, normalizer: normalizer}
+}
+
+func (n normalized) Add(ctx context.Context, entry Entry) error {
+	entry.Address = n.normalizer.Normalize(entry.Address)
+	return n.store.Add(ctx, entry)
+}
+
+func (n normalized) Remove(ctx context.Context, address string) error {
+	return n.store.Remove(ctx, n.normalizer.Normalize(address))
+}
+
+func (n normalized) Get(ctx context.Context, address string) (*Entry, error) {
+	return n.store.Get(ctx, n.normalizer.Normalize(address))
+}
@@ -0,0 +1,139 @@
+// Package sql implements a suppression.Store with database/sql. It only uses portable SQL, the placeholder style is
+// chosen according to the driver (ie: PostgreSQL uses $1, MySQL and SQLite use ?).
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/fdelbos/mauth/suppression"
+)
+
+type (
+	placeholder int
+
+	Params struct {
+		DB *sql.DB
+		// Table defaults to "mauth_suppressions".
+		Table       string
+		Placeholder placeholder
+	}
+
+	Store struct {
+		db          *sql.DB
+		table       string
+		placeholder placeholder
+	}
+)
+
+const (
+	PlaceholderQuestion placeholder = iota
+	PlaceholderDollar
+
+	DefaultTable = "mauth_suppressions"
+)
+
+var (
+	ErrDBNil        = errors.New("db cannot be nil")
+	ErrInvalidTable = errors.New("invalid table name")
+
+	tableRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+)
+
+func NewStore(params Params) (*Store, error) {
+	if params.DB == nil {
+		return nil, ErrDBNil
+	}
+	table := params.Table
+	if table == "" {
+		table = DefaultTable
+	}
+	if !tableRegexp.MatchString(table) {
+		return nil, ErrInvalidTable
+	}
+	return &Store{
+		db:          params.DB,
+		table:       table,
+		placeholder: params.Placeholder,
+	}, nil
+}
+
+// query replaces the '?' placeholders for the drivers using another style.
+func (s Store) query(query string) string {
+	if s.placeholder != PlaceholderDollar {
+		return query
+	}
+	n := 0
+	res := []byte{}
+	for i := 0; i < len(query); i++ {
+		if query[i] == '?' {
+			n++
+			res = append(res, []byte(fmt.Sprintf("$%d", n))...)
+		} else {
+			res = append(res, query[i])
+		}
+	}
+	return string(res)
+}
+
+// CreateTable creates the table if it doesn't exist.
+func (s Store) CreateTable(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+s.table+` (
+		address VARCHAR(320) NOT NULL PRIMARY KEY,
+		reason VARCHAR(64) NOT NULL,
+		details TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	)`)
+	return err
+}
+
+func (s Store) Add(ctx context.Context, entry suppression.Entry) error {
+	key := suppression.Key(entry.Address)
+	if key == "" {
+		return suppression.ErrAddressEmpty
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	// delete then insert, as the upsert syntax is not portable
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, s.query(`DELETE FROM `+s.table+` WHERE address = ?`), key); err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		s.query(`INSERT INTO `+s.table+` (address, reason, details, created_at) VALUES (?, ?, ?, ?)`),
+		key, entry.Reason, entry.Details, entry.CreatedAt.UTC())
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s Store) Remove(ctx context.Context, address string) error {
+	_, err := s.db.ExecContext(ctx, s.query(`DELETE FROM `+s.table+` WHERE address = ?`), suppression.Key(address))
+	return err
+}
+
+func (s Store) Get(ctx context.Context, address string) (*suppression.Entry, error) {
+	res := suppression.Entry{}
+	err := s.db.QueryRowContext(ctx,
+		s.query(`SELECT address, reason, details, created_at FROM `+s.table+` WHERE address = ?`),
+		suppression.Key(address),
+	).Scan(&res.Address, &res.Reason, &res.Details, &res.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, suppression.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
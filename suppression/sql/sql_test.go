@@ -0,0 +1,57 @@
+package sql_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fdelbos/mauth/suppression"
+	suppressionsql "github.com/fdelbos/mauth/suppression/sql"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	require.Nil(t, err)
+	defer db.Close()
+
+	store, err := suppressionsql.NewStore(suppressionsql.Params{DB: db})
+	require.Nil(t, err)
+	require.Nil(t, store.CreateTable(ctx))
+
+	_, err = store.Get(ctx, "dest@example.com")
+	require.Equal(t, suppression.ErrNotFound, err)
+
+	createdAt := time.Date(2020, 12, 1, 10, 0, 0, 0, time.UTC)
+	require.Nil(t, store.Add(ctx, suppression.Entry{
+		Address:   "Dest@Example.com",
+		Reason:    suppression.ReasonBounce,
+		Details:   "550 5.1.1 user unknown",
+		CreatedAt: createdAt,
+	}))
+	// adding it again replaces the entry
+	require.Nil(t, store.Add(ctx, suppression.Entry{
+		Address:   "dest@example.com",
+		Reason:    suppression.ReasonComplaint,
+		CreatedAt: createdAt,
+	}))
+
+	entry, err := store.Get(ctx, "DEST@example.com")
+	require.Nil(t, err)
+	require.Equal(t, "dest@example.com", entry.Address)
+	require.Equal(t, suppression.ReasonComplaint, entry.Reason)
+	require.True(t, createdAt.Equal(entry.CreatedAt))
+
+	require.Nil(t, store.Remove(ctx, "dest@example.com"))
+	_, err = store.Get(ctx, "dest@example.com")
+	require.Equal(t, suppression.ErrNotFound, err)
+}
+
+func TestInvalidTable(t *testing.T) {
+	_, err := suppressionsql.NewStore(suppressionsql.Params{DB: &sql.DB{}, Table: "users; DROP TABLE users"})
+	require.Equal(t, suppressionsql.ErrInvalidTable, err)
+}
@@ -0,0 +1,55 @@
+package suppression
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+
+	_, err := m.Get(ctx, "dest@example.com")
+	require.Equal(t, ErrNotFound, err)
+
+	require.Nil(t, m.Add(ctx, Entry{Address: " Dest@Example.com", Reason: ReasonBounce, Details: "550 5.1.1 user unknown"}))
+
+	entry, err := m.Get(ctx, "dest@example.COM")
+	require.Nil(t, err)
+	require.Equal(t, "dest@example.com", entry.Address)
+	require.Equal(t, ReasonBounce, entry.Reason)
+	require.False(t, entry.CreatedAt.IsZero())
+
+	require.Nil(t, m.Remove(ctx, "DEST@example.com"))
+	_, err = m.Get(ctx, "dest@example.com")
+	require.Equal(t, ErrNotFound, err)
+
+	require.Equal(t, ErrAddressEmpty, m.Add(ctx, Entry{Address: " "}))
+}
+
+type lowerLocal struct{}
+
+func (lowerLocal) Normalize(address string) string {
+	return strings.ReplaceAll(strings.ToLower(address), ".", "")
+}
+
+func TestNormalized(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	n := Normalized(m, lowerLocal{})
+
+	require.Nil(t, n.Add(ctx, Entry{Address: "John.Doe@example", Reason: ReasonBounce}))
+	entry, err := m.Get(ctx, "johndoe@example")
+	require.Nil(t, err)
+	require.Equal(t, "johndoe@example", entry.Address)
+
+	_, err = n.Get(ctx, "j.o.h.n.doe@example")
+	require.Nil(t, err)
+
+	require.Nil(t, n.Remove(ctx, "JohnDoe@example"))
+	_, err = m.Get(ctx, "johndoe@example")
+	require.Equal(t, ErrNotFound, err)
+}
@@ -0,0 +1,43 @@
+package suppression
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+type (
+	// Entry is an address that must not receive messages anymore.
+	Entry struct {
+		Address string
+		Reason  string
+		// Details is a free text, like the SMTP reply of a bounce.
+		Details   string
+		CreatedAt time.Time
+	}
+
+	Store interface {
+		// Add adds the address to the list, or replaces its entry.
+		Add(ctx context.Context, entry Entry) error
+		Remove(ctx context.Context, address string) error
+		// Get returns ErrNotFound if the address is not in the list.
+		Get(ctx context.Context, address string) (*Entry, error)
+	}
+)
+
+const (
+	ReasonBounce    = "bounce"
+	ReasonComplaint = "complaint"
+	ReasonManual    = "manual"
+)
+
+var (
+	ErrNotFound     = errors.New("address is not suppressed")
+	ErrAddressEmpty = errors.New("address is empty")
+)
+
+// Key returns the form of the address used to store and lookup the entries, so that the case doesn't matter.
+func Key(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
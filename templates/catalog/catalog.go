@@ -0,0 +1,521 @@
+// Package catalog implements templates.Templates with a single set of templates for all the languages, the texts
+// are translated with message catalogs (JSON or gettext PO files) and golang.org/x/text/message:
+//
+//	<p>{{ t "login.intro" .Email }}</p>
+//	<p>{{ t "login.expires" 20 }}</p>
+//
+// The first argument of the plural messages selects the plural form. A message missing in a language is taken from
+// the default language, and the message ID is used when the default language doesn't have it either. The functions
+// of the localized package (relative, formatDate, plural...) can be used too.
+package catalog
+
+import (
+	"bytes"
+	"errors"
+	htmlTemplate "html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	txtTemplate "text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/fdelbos/mauth/templates"
+	"github.com/fdelbos/mauth/templates/cssinline"
+	"github.com/fdelbos/mauth/templates/htmltext"
+	"github.com/fdelbos/mauth/templates/localized"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	xcatalog "golang.org/x/text/message/catalog"
+)
+
+type (
+	// FuncMap are the functions available in the templates, see text/template.FuncMap.
+	FuncMap map[string]interface{}
+
+	// Catalog can load messages while generating, the messages and the templates are replaced together when a
+	// loading succeeds.
+	Catalog struct {
+		mu sync.RWMutex
+		state
+	}
+
+	// state is the configuration of the catalog, it is restored when a change fails.
+	state struct {
+		// builder and messages are replaced, never modified, so that the translation functions of the templates
+		// keep the ones they were built with
+		builder *xcatalog.Builder
+		strict  bool
+		// noText disables the text version made from the html template
+		noText    bool
+		inlineCSS bool
+		// messages are the messages of each language by ID
+		messages map[language.Tag]map[string][]xcatalog.Message
+		tags     []language.Tag
+		matcher  language.Matcher
+		// now is the clock of the relative function
+		now func() time.Time
+
+		subject string
+		txt     string
+		html    string
+		funcs   []FuncMap
+		locales map[language.Tag]locale
+	}
+
+	locale struct {
+		subject *txtTemplate.Template
+		txt     *txtTemplate.Template
+		html    *htmlTemplate.Template
+	}
+)
+
+const (
+	// TranslateFunc is the name of the translation function of the templates.
+	TranslateFunc = "t"
+)
+
+var (
+	ErrUnsupportedLanguage   = errors.New("language is not supported")
+	ErrNoTemplateForLanguage = errors.New("no catalog for this language")
+	ErrNoTemplates           = errors.New("templates are not set")
+	ErrTemplatesEmpty        = errors.New("both templates are empty")
+	ErrSubjectEmpty          = errors.New("subject is empty")
+	ErrInvalidCatalog        = errors.New("invalid catalog")
+)
+
+func NewCatalog() *Catalog {
+	return &Catalog{state: state{
+		builder:  xcatalog.NewBuilder(),
+		messages: map[language.Tag]map[string][]xcatalog.Message{},
+		tags:     []language.Tag{},
+		locales:  map[language.Tag]locale{},
+		now:      time.Now,
+	}}
+}
+
+// SetTemplates sets the templates used by all the languages, the subject is a text template and the line breaks of
+// its result are removed. The functions of funcs can be used in addition to the translation function t and the
+// localized functions.
+func (c *Catalog) SetTemplates(subject, txt, html string, funcs ...FuncMap) error {
+	if subject == "" {
+		return ErrSubjectEmpty
+	} else if txt == "" && html == "" {
+		return ErrTemplatesEmpty
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev := c.state
+	c.subject, c.txt, c.html, c.funcs = subject, txt, html, funcs
+	if err := c.build(); err != nil {
+		c.state = prev
+		return err
+	}
+	return nil
+}
+
+// SetDefaultLanguage sets the language used when the language of the recipient is not supported, and for the
+// missing messages.
+func (c *Catalog) SetDefaultLanguage(lang string) error {
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return ErrUnsupportedLanguage
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.messages[tag]; !ok {
+		return ErrNoTemplateForLanguage
+	}
+
+	prev := c.state
+	newTags := []language.Tag{tag}
+	for _, t := range c.tags {
+		if t != tag {
+			newTags = append(newTags, t)
+		}
+	}
+	c.tags = newTags
+	c.matcher = language.NewMatcher(c.tags)
+	if err := c.build(); err != nil {
+		c.state = prev
+		return err
+	}
+	return nil
+}
+
+func parseLanguage(lang string) (language.Tag, error) {
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return tag, ErrUnsupportedLanguage
+	}
+	return tag, nil
+}
+
+// SetInlineCSS moves the CSS rules of the style elements of the html template to the style attributes of the
+// elements, see the cssinline package. The template is inlined once before it is parsed.
+func (c *Catalog) SetInlineCSS(enabled bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev := c.state
+	c.inlineCSS = enabled
+	if err := c.build(); err != nil {
+		c.state = prev
+		return err
+	}
+	return nil
+}
+
+// SetStrict makes the execution of the templates fail when they use a missing key of a map, like .Data.Name when
+// Name wasn't given. By default the missing keys are rendered as "<no value>" in the text templates, and as an empty
+// string in the html templates.
+func (c *Catalog) SetStrict(strict bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.strict = strict
+	for _, loc := range c.locales {
+		loc.subject.Option(c.missingKey())
+		if loc.txt != nil {
+			loc.txt.Option(c.missingKey())
+		}
+		if loc.html != nil {
+			loc.html.Option(c.missingKey())
+		}
+	}
+}
+
+func (c *state) missingKey() string {
+	if c.strict {
+		return "missingkey=error"
+	}
+	return "missingkey=default"
+}
+
+// SetClock sets the clock giving the current time to the relative function, it defaults to time.Now.
+func (c *Catalog) SetClock(now func() time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev := c.state
+	c.now = now
+	if err := c.build(); err != nil {
+		c.state = prev
+		return err
+	}
+	return nil
+}
+
+// SetTextFromHTML enables the text version made from the html template when there is no text template, so that
+// the messages always have a text part. It is enabled by default.
+func (c *Catalog) SetTextFromHTML(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.noText = !enabled
+}
+
+// add adds the messages of a language. The messages are loaded in a new builder, which replaces the current one with
+// the templates built with it only when everything succeeded.
+func (c *Catalog) add(tag language.Tag, added map[string][]xcatalog.Message) error {
+	if len(added) == 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	messages := make(map[language.Tag]map[string][]xcatalog.Message, len(c.messages)+1)
+	for t, msgs := range c.messages {
+		messages[t] = msgs
+	}
+	tags := c.tags
+	msgs, ok := messages[tag]
+	if !ok {
+		tags = append(append([]language.Tag{}, c.tags...), tag)
+	}
+	merged := make(map[string][]xcatalog.Message, len(msgs)+len(added))
+	for key, msg := range msgs {
+		merged[key] = msg
+	}
+	for key, msg := range added {
+		merged[key] = msg
+	}
+	messages[tag] = merged
+
+	builder := xcatalog.NewBuilder()
+	for t, msgs := range messages {
+		for key, msg := range msgs {
+			if err := builder.Set(t, key, msg...); err != nil {
+				return err
+			}
+		}
+	}
+
+	prev := c.state
+	c.builder, c.messages, c.tags, c.matcher = builder, messages, tags, language.NewMatcher(tags)
+	if err := c.build(); err != nil {
+		c.state = prev
+		return err
+	}
+	return nil
+}
+
+// build parses the templates for each language, with a translation function bound to the language.
+func (c *state) build() error {
+	if c.subject == "" {
+		return nil
+	}
+
+	html := c.html
+	if c.inlineCSS && html != "" {
+		inlined, err := cssinline.Inline([]byte(html))
+		if err != nil {
+			return err
+		}
+		html = string(inlined)
+	}
+
+	now := c.now
+	if now == nil {
+		now = time.Now
+	}
+
+	locales := map[language.Tag]locale{}
+	for _, tag := range c.tags {
+		funcs := append([]FuncMap{
+			FuncMap(localized.Funcs(tag, now)),
+			{TranslateFunc: translator(tag, c.tags[0], c.messages, c.builder)},
+		}, c.funcs...)
+		res := locale{}
+
+		res.subject = txtTemplate.New("subject").Option(c.missingKey())
+		for _, f := range funcs {
+			res.subject.Funcs(txtTemplate.FuncMap(f))
+		}
+		if _, err := res.subject.Parse(c.subject); err != nil {
+			return err
+		}
+
+		if c.txt != "" {
+			res.txt = txtTemplate.New("txt").Option(c.missingKey())
+			for _, f := range funcs {
+				res.txt.Funcs(txtTemplate.FuncMap(f))
+			}
+			if _, err := res.txt.Parse(c.txt); err != nil {
+				return err
+			}
+		}
+
+		if html != "" {
+			res.html = htmlTemplate.New("html").Option(c.missingKey())
+			for _, f := range funcs {
+				res.html.Funcs(htmlTemplate.FuncMap(f))
+			}
+			if _, err := res.html.Parse(html); err != nil {
+				return err
+			}
+		}
+		locales[tag] = res
+	}
+	c.locales = locales
+	return nil
+}
+
+// translator returns the translation function of a language, with the messages of the catalog when it is built.
+func translator(tag, def language.Tag, messages map[language.Tag]map[string][]xcatalog.Message, builder *xcatalog.Builder) func(key string, args ...interface{}) string {
+	return func(key string, args ...interface{}) string {
+		lang := tag
+		if _, ok := messages[lang][key]; !ok {
+			// the printer of the default language formats the numbers of its own language
+			lang = def
+		}
+		if _, ok := messages[lang][key]; !ok {
+			return key
+		}
+		return message.NewPrinter(lang, message.Catalog(builder)).Sprintf(key, args...)
+	}
+}
+
+// Untranslated returns the message IDs missing in each language, by language. The IDs are the ones used by the
+// templates with a constant string, and the ones of the default language.
+func (c *Catalog) Untranslated() map[string][]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	expected := map[string]struct{}{}
+	if len(c.tags) != 0 {
+		for key := range c.messages[c.tags[0]] {
+			expected[key] = struct{}{}
+		}
+	}
+	for _, loc := range c.locales {
+		for _, tree := range trees(loc) {
+			usedKeys(tree.Root, expected)
+		}
+		break
+	}
+
+	res := map[string][]string{}
+	for _, tag := range c.tags {
+		missing := []string{}
+		for key := range expected {
+			if _, ok := c.messages[tag][key]; !ok {
+				missing = append(missing, key)
+			}
+		}
+		if len(missing) != 0 {
+			sort.Strings(missing)
+			res[tag.String()] = missing
+		}
+	}
+	return res
+}
+
+func trees(loc locale) []*parse.Tree {
+	res := []*parse.Tree{}
+	for _, t := range loc.subject.Templates() {
+		res = append(res, t.Tree)
+	}
+	if loc.txt != nil {
+		for _, t := range loc.txt.Templates() {
+			res = append(res, t.Tree)
+		}
+	}
+	if loc.html != nil {
+		for _, t := range loc.html.Templates() {
+			res = append(res, t.Tree)
+		}
+	}
+	return res
+}
+
+// usedKeys adds the constant message IDs given to the translation function.
+func usedKeys(node parse.Node, keys map[string]struct{}) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			usedKeys(child, keys)
+		}
+	case *parse.ActionNode:
+		usedKeys(n.Pipe, keys)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			usedKeys(cmd, keys)
+		}
+	case *parse.CommandNode:
+		if len(n.Args) > 1 {
+			if ident, ok := n.Args[0].(*parse.IdentifierNode); ok && ident.Ident == TranslateFunc {
+				if key, ok := n.Args[1].(*parse.StringNode); ok {
+					keys[key.Text] = struct{}{}
+				}
+			}
+		}
+		for _, arg := range n.Args {
+			usedKeys(arg, keys)
+		}
+	case *parse.IfNode:
+		usedKeys(n.Pipe, keys)
+		usedKeys(n.List, keys)
+		usedKeys(n.ElseList, keys)
+	case *parse.RangeNode:
+		usedKeys(n.Pipe, keys)
+		usedKeys(n.List, keys)
+		usedKeys(n.ElseList, keys)
+	case *parse.WithNode:
+		usedKeys(n.Pipe, keys)
+		usedKeys(n.List, keys)
+		usedKeys(n.ElseList, keys)
+	case *parse.TemplateNode:
+		usedKeys(n.Pipe, keys)
+	}
+}
+
+func (c *Catalog) generate(tag language.Tag, params templates.Params) (*templates.TemplateResult, error) {
+	loc, ok := c.locales[tag]
+	if !ok {
+		return nil, ErrNoTemplates
+	}
+
+	subject := strings.Builder{}
+	if err := loc.subject.Execute(&subject, params); err != nil {
+		return nil, err
+	}
+	res := templates.TemplateResult{Subject: templates.SanitizeSubject(subject.String())}
+
+	if loc.txt != nil {
+		dest := bytes.Buffer{}
+		if err := loc.txt.Execute(&dest, params); err != nil {
+			return nil, err
+		}
+		res.TXT = dest.Bytes()
+	}
+
+	if loc.html != nil {
+		dest := bytes.Buffer{}
+		if err := loc.html.Execute(&dest, params); err != nil {
+			return nil, err
+		}
+		res.HTML = dest.Bytes()
+	}
+
+	if len(res.TXT) == 0 && len(res.HTML) != 0 && !c.noText {
+		txt, err := htmltext.Convert(res.HTML)
+		if err != nil {
+			return nil, err
+		}
+		res.TXT = txt
+	}
+
+	return &res, nil
+}
+
+func (c *Catalog) Generate(params templates.Params) (*templates.TemplateResult, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.tags) == 0 {
+		return nil, ErrNoTemplateForLanguage
+	}
+	return c.generate(c.tags[0], params)
+}
+
+func (c *Catalog) GenerateForLang(lang string, params templates.Params) (*templates.TemplateResult, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.tags) == 0 {
+		return nil, ErrNoTemplateForLanguage
+	}
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return c.generate(c.tags[0], params)
+	}
+	_, idx, _ := c.matcher.Match(tag)
+	return c.generate(c.tags[idx], params)
+}
+
+func (c *Catalog) GenerateForRequest(r *http.Request, params templates.Params) (*templates.TemplateResult, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.tags) == 0 {
+		return nil, ErrNoTemplateForLanguage
+	}
+	tags, _, err := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	if err != nil {
+		return c.generate(c.tags[0], params)
+	}
+	_, idx, _ := c.matcher.Match(tags...)
+	return c.generate(c.tags[idx], params)
+}
@@ -0,0 +1,286 @@
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fdelbos/mauth/templates"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	enJSON = `{
+	"subject": "Sign in to %s",
+	"intro": "Hello %s, click the link below:",
+	"expires": {"=0": "The link expires now.", "one": "The link expires in %d minute.", "other": "The link expires in %d minutes."},
+	"footer": "Thanks"
+}`
+
+	frPO = `# French translation
+msgid ""
+msgstr ""
+"Language: fr\n"
+"Plural-Forms: nplurals=2; plural=(n > 1);\n"
+
+msgid "subject"
+msgstr "Connexion à %s"
+
+msgid "intro"
+msgstr ""
+"Bonjour %s, "
+"cliquez sur le lien :"
+
+msgid "expires"
+msgid_plural "expires"
+msgstr[0] "Le lien expire dans %d minute."
+msgstr[1] "Le lien expire dans %d minutes."
+
+#, fuzzy
+msgid "footer"
+msgstr "Merci"
+`
+
+	ruPO = `msgid ""
+msgstr ""
+"Plural-Forms: nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);\n"
+
+msgid "expires"
+msgid_plural "expires"
+msgstr[0] "%d минута"
+msgstr[1] "%d минуты"
+msgstr[2] "%d минут"
+`
+
+	subject = `{{ t "subject" .Data.Product }}`
+	txt     = `{{ t "intro" .Email }} {{ .URL }}
+{{ t "expires" .Data.Minutes }}
+{{ t "footer" }}`
+	html = `<p>{{ t "intro" .Email }}</p><p>{{ t "expires" .Data.Minutes }}</p>{{ t "signature" }}`
+)
+
+func newParams(minutes int) templates.Params {
+	return templates.Params{
+		Email:      "<me@example.com>",
+		URL:        "https://example.com",
+		Expiration: time.Now(),
+		Data:       map[string]interface{}{"Product": "Acme", "Minutes": minutes},
+	}
+}
+
+func createCatalog(t *testing.T) *Catalog {
+	c := NewCatalog()
+	require.Nil(t, c.AddJSON("en", strings.NewReader(enJSON)))
+	require.Nil(t, c.AddPO("fr", strings.NewReader(frPO)))
+	require.Nil(t, c.SetTemplates(subject, txt, html))
+	require.Nil(t, c.SetDefaultLanguage("en"))
+	return c
+}
+
+func TestGenerate(t *testing.T) {
+	c := createCatalog(t)
+
+	res, err := c.Generate(newParams(1))
+	require.Nil(t, err)
+	require.Equal(t, "Sign in to Acme", res.Subject)
+	require.Equal(t, "Hello <me@example.com>, click the link below: https://example.com\nThe link expires in 1 minute.\nThanks", string(res.TXT))
+	require.Equal(t, "<p>Hello &lt;me@example.com&gt;, click the link below:</p><p>The link expires in 1 minute.</p>signature", string(res.HTML))
+
+	res, err = c.Generate(newParams(0))
+	require.Nil(t, err)
+	require.Contains(t, string(res.TXT), "The link expires now.")
+
+	res, err = c.Generate(newParams(20))
+	require.Nil(t, err)
+	require.Contains(t, string(res.TXT), "The link expires in 20 minutes.")
+}
+
+func TestGenerateForLang(t *testing.T) {
+	c := createCatalog(t)
+
+	res, err := c.GenerateForLang("fr-CA", newParams(1))
+	require.Nil(t, err)
+	require.Equal(t, "Connexion à Acme", res.Subject)
+	// the fuzzy footer falls back to the default language
+	require.Equal(t, "Bonjour <me@example.com>, cliquez sur le lien : https://example.com\nLe lien expire dans 1 minute.\nThanks", string(res.TXT))
+
+	res, err = c.GenerateForLang("fr", newParams(0))
+	require.Nil(t, err)
+	require.Contains(t, string(res.TXT), "Le lien expire dans 0 minute.")
+
+	res, err = c.GenerateForLang("fr", newParams(3))
+	require.Nil(t, err)
+	require.Contains(t, string(res.TXT), "Le lien expire dans 3 minutes.")
+
+	res, err = c.GenerateForLang("de", newParams(3))
+	require.Nil(t, err)
+	require.Equal(t, "Sign in to Acme", res.Subject)
+
+	req, err := http.NewRequest(http.MethodGet, "/", nil)
+	require.Nil(t, err)
+	req.Header.Set("Accept-Language", "fr-FR,fr;q=0.9,en;q=0.8")
+	res, err = c.GenerateForRequest(req, newParams(3))
+	require.Nil(t, err)
+	require.Equal(t, "Connexion à Acme", res.Subject)
+}
+
+func TestPOPlural(t *testing.T) {
+	c := createCatalog(t)
+	require.Nil(t, c.AddPO("ru", strings.NewReader(ruPO)))
+
+	for minutes, expected := range map[int]string{
+		1:  "1 минута",
+		3:  "3 минуты",
+		5:  "5 минут",
+		11: "11 минут",
+		21: "21 минута",
+		22: "22 минуты",
+	} {
+		res, err := c.GenerateForLang("ru", newParams(minutes))
+		require.Nil(t, err)
+		require.Contains(t, string(res.TXT), expected)
+	}
+
+	f, err := parsePlural("n==0 ? 0 : n==1 ? 1 : n==2 ? 2 : n%100>=3 && n%100<=10 ? 3 : n%100>=11 ? 4 : 5")
+	require.Nil(t, err)
+	require.Equal(t, []int{0, 1, 2, 3, 4, 5}, []int{f(0), f(1), f(2), f(7), f(11), f(100)})
+
+	_, err = parsePlural("n == ")
+	require.NotNil(t, err)
+	_, err = parsePlural("(n != 1")
+	require.NotNil(t, err)
+}
+
+func TestUntranslated(t *testing.T) {
+	c := createCatalog(t)
+	require.Nil(t, c.AddPO("ru", strings.NewReader(ruPO)))
+
+	require.Equal(t, map[string][]string{
+		"en": {"signature"},
+		"fr": {"footer", "signature"},
+		"ru": {"footer", "intro", "signature", "subject"},
+	}, c.Untranslated())
+}
+
+func TestErrors(t *testing.T) {
+	c := NewCatalog()
+
+	_, err := c.Generate(newParams(1))
+	require.True(t, errors.Is(err, ErrNoTemplateForLanguage))
+
+	require.True(t, errors.Is(c.AddJSON("en", strings.NewReader(`{"a": 1}`)), ErrInvalidCatalog))
+	require.True(t, errors.Is(c.AddJSON("en", strings.NewReader(`{"a": {"one": "a"}}`)), ErrInvalidCatalog))
+	require.True(t, errors.Is(c.AddJSON("en", strings.NewReader(`{"a": {"some": "a", "other": "b"}}`)), ErrInvalidCatalog))
+	require.True(t, errors.Is(c.AddPO("en", strings.NewReader(`msgid "a" unquoted`)), ErrInvalidCatalog))
+	require.True(t, errors.Is(c.AddPO("en", strings.NewReader(`msgstr[x] "a"`)), ErrInvalidCatalog))
+	require.Equal(t, ErrUnsupportedLanguage, c.AddJSON("not a language", strings.NewReader(`{}`)))
+	require.Equal(t, ErrNoTemplateForLanguage, c.SetDefaultLanguage("fr"))
+
+	require.Nil(t, c.AddJSON("en", strings.NewReader(enJSON)))
+	_, err = c.Generate(newParams(1))
+	require.Equal(t, ErrNoTemplates, err)
+
+	require.Equal(t, ErrSubjectEmpty, c.SetTemplates("", txt, html))
+	require.Equal(t, ErrTemplatesEmpty, c.SetTemplates(subject, "", ""))
+	require.NotNil(t, c.SetTemplates(subject, "{{ t ", html))
+	require.NotNil(t, c.SetTemplates(subject, txt, "{{ unknown }}"))
+	require.Nil(t, c.SetTemplates(subject, txt, ""))
+
+	res, err := c.Generate(newParams(1))
+	require.Nil(t, err)
+	require.Nil(t, res.HTML)
+}
+
+func TestTextFromHTML(t *testing.T) {
+	c := createCatalog(t)
+	require.Nil(t, c.SetTemplates(subject, "", html))
+
+	res, err := c.GenerateForLang("fr", newParams(2))
+	require.Nil(t, err)
+	require.Equal(t, "Bonjour <me@example.com>, cliquez sur le lien :\n\nLe lien expire dans 2 minutes.\n\nsignature\n", string(res.TXT))
+
+	c.SetTextFromHTML(false)
+	res, err = c.GenerateForLang("fr", newParams(2))
+	require.Nil(t, err)
+	require.Nil(t, res.TXT)
+}
+
+func TestInlineCSS(t *testing.T) {
+	c := createCatalog(t)
+	require.Nil(t, c.SetInlineCSS(true))
+	require.Nil(t, c.SetTemplates(subject, txt, `<style>p { margin: 0 }</style><p>{{ t "intro" .Email }}</p>`))
+
+	res, err := c.GenerateForLang("fr", newParams(2))
+	require.Nil(t, err)
+	require.Equal(t, `<p style="margin: 0;">Bonjour &lt;me@example.com&gt;, cliquez sur le lien :</p>`, string(res.HTML))
+}
+
+func TestAddFailure(t *testing.T) {
+	c := createCatalog(t)
+
+	// a catalog failing to load leaves the messages as they were
+	require.NotNil(t, c.AddJSON("en", strings.NewReader(`{"footer": "Bye", "zz": {"one": "a"}}`)))
+	require.NotNil(t, c.AddJSON("de", strings.NewReader(`{"footer": "Danke", "zz": 1}`)))
+	require.Equal(t, ErrNoTemplateForLanguage, c.SetDefaultLanguage("de"))
+
+	res, err := c.Generate(newParams(1))
+	require.Nil(t, err)
+	require.True(t, strings.HasSuffix(string(res.TXT), "Thanks"))
+	require.Equal(t, map[string][]string{"en": {"signature"}, "fr": {"footer", "signature"}}, c.Untranslated())
+}
+
+func TestConcurrentAdd(t *testing.T) {
+	c := createCatalog(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				res, err := c.GenerateForLang("de", newParams(j))
+				require.Nil(t, err)
+				require.NotEmpty(t, res.TXT)
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		require.Nil(t, c.AddJSON("de", strings.NewReader(fmt.Sprintf(`{"footer": "Danke %d"}`, i))))
+	}
+	wg.Wait()
+}
+
+func TestLocalizedFuncs(t *testing.T) {
+	c := createCatalog(t)
+	current := time.Date(2021, time.March, 5, 13, 45, 0, 0, time.UTC)
+	require.Nil(t, c.SetClock(func() time.Time { return current }))
+	require.Nil(t, c.SetTemplates(subject, `{{ relative .Expiration }}|{{ formatDate .Expiration }}|{{ plural .Data.Minutes "minute" "minutes" }}`, ""))
+
+	params := newParams(1)
+	params.Expiration = current.Add(20 * time.Minute)
+	res, err := c.GenerateForLang("fr", params)
+	require.Nil(t, err)
+	require.Equal(t, "dans 20 minutes|5 mars 2021|minute", string(res.TXT))
+}
+
+func TestStrict(t *testing.T) {
+	c := createCatalog(t)
+	require.Nil(t, c.SetTemplates(subject, `{{ .Data.Missing }}`, ""))
+
+	res, err := c.Generate(newParams(1))
+	require.Nil(t, err)
+	require.Equal(t, "<no value>", string(res.TXT))
+
+	c.SetStrict(true)
+	_, err = c.Generate(newParams(1))
+	require.NotNil(t, err)
+
+	// the templates set afterwards are strict too
+	require.Nil(t, c.SetTemplates(subject, `{{ .Data.Other }}`, ""))
+	_, err = c.Generate(newParams(1))
+	require.NotNil(t, err)
+}
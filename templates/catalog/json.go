@@ -0,0 +1,98 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/feature/plural"
+	xcatalog "golang.org/x/text/message/catalog"
+)
+
+// pluralForms are the CLDR plural categories, in the order of the cases given to plural.Selectf.
+var pluralForms = []string{"zero", "one", "two", "few", "many", "other"}
+
+// AddJSON adds the messages of a language from a JSON object keyed by message ID. The messages are fmt formats, a
+// message with plural forms is an object keyed by CLDR category (zero, one, two, few, many and other, which is
+// required) or by exact value (=0, =1...), the form is selected by the first argument:
+//
+//	{
+//		"login.subject": "Sign in to %s",
+//		"login.expires": {"one": "The link expires in %d minute.", "other": "The link expires in %d minutes."}
+//	}
+func (c *Catalog) AddJSON(lang string, r io.Reader) error {
+	tag, err := parseLanguage(lang)
+	if err != nil {
+		return err
+	}
+
+	entries := map[string]json.RawMessage{}
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidCatalog, err)
+	}
+
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	messages := map[string][]xcatalog.Message{}
+	for _, key := range keys {
+		var msg string
+		if err := json.Unmarshal(entries[key], &msg); err == nil {
+			messages[key] = []xcatalog.Message{xcatalog.String(msg)}
+			continue
+		}
+
+		forms := map[string]string{}
+		if err := json.Unmarshal(entries[key], &forms); err != nil {
+			return fmt.Errorf("%w: %s: a message must be a string or an object of plural forms", ErrInvalidCatalog, key)
+		}
+		cases, err := pluralCases(forms)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidCatalog, key, err)
+		}
+		messages[key] = []xcatalog.Message{plural.Selectf(1, "", cases...)}
+	}
+	return c.add(tag, messages)
+}
+
+// pluralCases returns the cases of plural.Selectf, the exact values first since the first matching case wins.
+func pluralCases(forms map[string]string) ([]interface{}, error) {
+	if _, ok := forms["other"]; !ok {
+		return nil, fmt.Errorf("the other form is missing")
+	}
+
+	exact := []string{}
+	for form := range forms {
+		if strings.HasPrefix(form, "=") {
+			exact = append(exact, form)
+		} else if !isPluralForm(form) {
+			return nil, fmt.Errorf("unknown plural form %q", form)
+		}
+	}
+	sort.Strings(exact)
+
+	cases := []interface{}{}
+	for _, form := range exact {
+		cases = append(cases, form, forms[form])
+	}
+	for _, form := range pluralForms {
+		if msg, ok := forms[form]; ok {
+			cases = append(cases, form, msg)
+		}
+	}
+	return cases, nil
+}
+
+func isPluralForm(form string) bool {
+	for _, f := range pluralForms {
+		if f == form {
+			return true
+		}
+	}
+	return false
+}
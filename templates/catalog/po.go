@@ -0,0 +1,445 @@
+package catalog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/feature/plural"
+	"golang.org/x/text/language"
+	xcatalog "golang.org/x/text/message/catalog"
+)
+
+type (
+	poEntry struct {
+		ctxt   bool
+		id     string
+		plural string
+		strs   map[int]string
+		fuzzy  bool
+	}
+
+	// pluralFunc is the plural expression of a PO file, it returns the index of the msgstr to use for n.
+	pluralFunc func(n int) int
+
+	exprParser struct {
+		tokens []string
+		pos    int
+	}
+)
+
+// defaultPlural is the plural expression of the PO files without a Plural-Forms header.
+const defaultPlural = "n != 1"
+
+// AddPO adds the messages of a language from a gettext PO file. The msgid are the message IDs and the messages are
+// fmt formats. The plural forms (msgid_plural and msgstr[N]) are matched to the CLDR categories with the
+// Plural-Forms header, the form is selected by the first argument. The fuzzy and untranslated entries are skipped,
+// so that the message of the default language is used, and the entries with a msgctxt are ignored.
+func (c *Catalog) AddPO(lang string, r io.Reader) error {
+	tag, err := parseLanguage(lang)
+	if err != nil {
+		return err
+	}
+
+	entries, err := parsePO(r)
+	if err != nil {
+		return err
+	}
+
+	pluralExpr := defaultPlural
+	for _, e := range entries {
+		if e.id == "" && !e.ctxt {
+			pluralExpr = headerPlural(e.strs[0])
+		}
+	}
+	selectForm, err := parsePlural(pluralExpr)
+	if err != nil {
+		return fmt.Errorf("%w: Plural-Forms: %s", ErrInvalidCatalog, err)
+	}
+
+	messages := map[string][]xcatalog.Message{}
+	for _, e := range entries {
+		if e.id == "" || e.ctxt || e.fuzzy {
+			continue
+		}
+		if e.plural == "" {
+			if msg := e.strs[0]; msg != "" {
+				messages[e.id] = []xcatalog.Message{xcatalog.String(msg)}
+			}
+			continue
+		}
+		if cases := poCases(tag, selectForm, e.strs); cases != nil {
+			messages[e.id] = []xcatalog.Message{plural.Selectf(1, "", cases...)}
+		}
+	}
+	return c.add(tag, messages)
+}
+
+// poCases returns the cases of plural.Selectf, the msgstr of each CLDR category is the one selected by the plural
+// expression for an example of the category. It returns nil when a msgstr is missing.
+func poCases(tag language.Tag, selectForm pluralFunc, strs map[int]string) []interface{} {
+	for _, s := range strs {
+		if s == "" {
+			return nil
+		}
+	}
+
+	examples := map[string]int{}
+	for n := 0; n < 1000 && len(examples) < len(pluralForms); n++ {
+		form := formName(plural.Cardinal.MatchPlural(tag, n, 0, 0, 0, 0))
+		if _, ok := examples[form]; !ok {
+			examples[form] = n
+		}
+	}
+
+	cases := []interface{}{}
+	for _, form := range pluralForms {
+		n, ok := examples[form]
+		if !ok {
+			continue
+		}
+		msg, ok := strs[selectForm(n)]
+		if !ok {
+			return nil
+		}
+		cases = append(cases, form, msg)
+	}
+	return cases
+}
+
+func formName(form plural.Form) string {
+	switch form {
+	case plural.Zero:
+		return "zero"
+	case plural.One:
+		return "one"
+	case plural.Two:
+		return "two"
+	case plural.Few:
+		return "few"
+	case plural.Many:
+		return "many"
+	default:
+		return "other"
+	}
+}
+
+// headerPlural returns the plural expression of the header entry (ie: "n != 1" for
+// "Plural-Forms: nplurals=2; plural=n != 1;").
+func headerPlural(header string) string {
+	for _, line := range strings.Split(header, "\n") {
+		name := strings.SplitN(line, ":", 2)
+		if len(name) != 2 || !strings.EqualFold(strings.TrimSpace(name[0]), "Plural-Forms") {
+			continue
+		}
+		for _, field := range strings.Split(name[1], ";") {
+			field = strings.TrimSpace(field)
+			if strings.HasPrefix(field, "plural=") {
+				return strings.TrimPrefix(field, "plural=")
+			}
+		}
+	}
+	return defaultPlural
+}
+
+func parsePO(r io.Reader) ([]*poEntry, error) {
+	entries := []*poEntry{}
+	var current *poEntry
+	// last appends the continuation lines to the last string
+	var last func(string)
+	fuzzy := false
+
+	// start creates a new entry when the current one is complete
+	start := func() {
+		if current == nil || len(current.strs) != 0 {
+			current = &poEntry{strs: map[int]string{}, fuzzy: fuzzy}
+			entries = append(entries, current)
+			fuzzy = false
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		fail := func(msg string) error {
+			return fmt.Errorf("%w: line %d: %s", ErrInvalidCatalog, lineNum, msg)
+		}
+
+		switch {
+		case line == "":
+			last = nil
+			continue
+		case strings.HasPrefix(line, "#,"):
+			if strings.Contains(line, "fuzzy") {
+				fuzzy = true
+			}
+			continue
+		case strings.HasPrefix(line, "#"):
+			continue
+		}
+
+		keyword, value := line, ""
+		if i := strings.IndexAny(line, " \t"); i != -1 {
+			keyword, value = line[:i], strings.TrimSpace(line[i:])
+		}
+		if strings.HasPrefix(line, `"`) {
+			keyword, value = "", line
+		}
+		text, err := strconv.Unquote(value)
+		if err != nil {
+			return nil, fail("invalid string " + value)
+		}
+
+		switch {
+		case keyword == "":
+			if last == nil {
+				return nil, fail("string without keyword")
+			}
+			last(text)
+		case keyword == "msgctxt":
+			start()
+			current.ctxt = true
+			last = nil
+		case keyword == "msgid":
+			start()
+			entry := current
+			entry.id = text
+			last = func(text string) { entry.id += text }
+		case keyword == "msgid_plural":
+			if current == nil {
+				return nil, fail("msgid_plural without msgid")
+			}
+			entry := current
+			entry.plural = text
+			last = func(text string) { entry.plural += text }
+		case keyword == "msgstr" || strings.HasPrefix(keyword, "msgstr["):
+			if current == nil {
+				return nil, fail("msgstr without msgid")
+			}
+			idx := 0
+			if keyword != "msgstr" {
+				idx, err = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(keyword, "msgstr["), "]"))
+				if err != nil || !strings.HasSuffix(keyword, "]") {
+					return nil, fail("invalid keyword " + keyword)
+				}
+			}
+			entry := current
+			entry.strs[idx] = text
+			last = func(text string) { entry.strs[idx] += text }
+		default:
+			return nil, fail("unknown keyword " + keyword)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// parsePlural compiles the C expression of a Plural-Forms header (ie: "n%10==1 && n%100!=11 ? 0 : 1").
+func parsePlural(expr string) (pluralFunc, error) {
+	tokens, err := tokenize(strings.TrimSuffix(strings.TrimSpace(expr), ";"))
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	f, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return f, nil
+}
+
+func tokenize(expr string) ([]string, error) {
+	tokens := []string{}
+	for i := 0; i < len(expr); {
+		switch ch := expr[i]; {
+		case ch == ' ' || ch == '\t':
+			i++
+		case ch >= '0' && ch <= '9':
+			j := i
+			for j < len(expr) && expr[j] >= '0' && expr[j] <= '9' {
+				j++
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		case i+1 < len(expr) && isOperator(expr[i:i+2]):
+			tokens = append(tokens, expr[i:i+2])
+			i += 2
+		case strings.IndexByte("n?:<>%*/+-!()", ch) != -1:
+			tokens = append(tokens, string(ch))
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected %q", ch)
+		}
+	}
+	return tokens, nil
+}
+
+func isOperator(token string) bool {
+	switch token {
+	case "==", "!=", "<=", ">=", "&&", "||":
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+// binary parses the operators of a precedence level, next parses the higher level.
+func (p *exprParser) binary(next func() (pluralFunc, error), ops map[string]func(a, b int) int) (pluralFunc, error) {
+	left, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := ops[p.peek()]
+		if !ok {
+			return left, nil
+		}
+		p.pos++
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(n int) int { return op(l(n), right(n)) }
+	}
+}
+
+func (p *exprParser) ternary() (pluralFunc, error) {
+	cond, err := p.or()
+	if err != nil || p.peek() != "?" {
+		return cond, err
+	}
+	p.pos++
+	yes, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != ":" {
+		return nil, fmt.Errorf("missing :")
+	}
+	p.pos++
+	no, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	return func(n int) int {
+		if cond(n) != 0 {
+			return yes(n)
+		}
+		return no(n)
+	}, nil
+}
+
+func (p *exprParser) or() (pluralFunc, error) {
+	return p.binary(p.and, map[string]func(a, b int) int{
+		"||": func(a, b int) int { return boolInt(a != 0 || b != 0) },
+	})
+}
+
+func (p *exprParser) and() (pluralFunc, error) {
+	return p.binary(p.equality, map[string]func(a, b int) int{
+		"&&": func(a, b int) int { return boolInt(a != 0 && b != 0) },
+	})
+}
+
+func (p *exprParser) equality() (pluralFunc, error) {
+	return p.binary(p.relational, map[string]func(a, b int) int{
+		"==": func(a, b int) int { return boolInt(a == b) },
+		"!=": func(a, b int) int { return boolInt(a != b) },
+	})
+}
+
+func (p *exprParser) relational() (pluralFunc, error) {
+	return p.binary(p.additive, map[string]func(a, b int) int{
+		"<":  func(a, b int) int { return boolInt(a < b) },
+		"<=": func(a, b int) int { return boolInt(a <= b) },
+		">":  func(a, b int) int { return boolInt(a > b) },
+		">=": func(a, b int) int { return boolInt(a >= b) },
+	})
+}
+
+func (p *exprParser) additive() (pluralFunc, error) {
+	return p.binary(p.multiplicative, map[string]func(a, b int) int{
+		"+": func(a, b int) int { return a + b },
+		"-": func(a, b int) int { return a - b },
+	})
+}
+
+func (p *exprParser) multiplicative() (pluralFunc, error) {
+	return p.binary(p.unary, map[string]func(a, b int) int{
+		"*": func(a, b int) int { return a * b },
+		"/": func(a, b int) int {
+			if b == 0 {
+				return 0
+			}
+			return a / b
+		},
+		"%": func(a, b int) int {
+			if b == 0 {
+				return 0
+			}
+			return a % b
+		},
+	})
+}
+
+func (p *exprParser) unary() (pluralFunc, error) {
+	if p.peek() != "!" {
+		return p.primary()
+	}
+	p.pos++
+	f, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return func(n int) int { return boolInt(f(n) == 0) }, nil
+}
+
+func (p *exprParser) primary() (pluralFunc, error) {
+	token := p.peek()
+	p.pos++
+	switch {
+	case token == "n":
+		return func(n int) int { return n }, nil
+	case token == "(":
+		f, err := p.ternary()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return f, nil
+	case token != "" && token[0] >= '0' && token[0] <= '9':
+		value, err := strconv.Atoi(token)
+		if err != nil {
+			return nil, err
+		}
+		return func(int) int { return value }, nil
+	case token == "":
+		return nil, fmt.Errorf("unexpected end")
+	default:
+		return nil, fmt.Errorf("unexpected %q", token)
+	}
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,341 @@
+package cssinline
+
+import (
+	"regexp"
+	"strings"
+)
+
+type (
+	declaration struct {
+		property  string
+		value     string
+		important bool
+	}
+
+	// styleRule is a rule with a single selector, the rules of a group of selectors share the same order.
+	styleRule struct {
+		selector     *selector
+		declarations []declaration
+		order        int
+	}
+
+	// selector is a list of compound selectors separated by combinators, ' ' for descendant and '>' for child.
+	selector struct {
+		compounds   []compound
+		combinators []byte
+		specificity specificity
+	}
+
+	// specificity counts the ids, the classes and attributes, and the types of a selector.
+	specificity [3]int
+
+	compound struct {
+		tag     string
+		id      string
+		classes []string
+		attrs   []attrMatch
+	}
+
+	attrMatch struct {
+		key   string
+		op    string
+		value string
+	}
+)
+
+var comments = regexp.MustCompile(`(?s)/\*.*?\*/`)
+
+// parseCSS returns the rules that can be inlined, and the ones that must stay in a style element: the at-rules (ie:
+// media queries) and the rules with a selector that can't be matched without a browser (ie: :hover).
+func parseCSS(css string) ([]styleRule, []string) {
+	css = comments.ReplaceAllString(css, "")
+	rules := []styleRule{}
+	kept := []string{}
+
+	for order := 0; ; order++ {
+		css = strings.TrimSpace(css)
+		if css == "" {
+			return rules, kept
+		}
+
+		if css[0] == '@' {
+			end := strings.IndexAny(css, "{;")
+			if end == -1 {
+				kept = append(kept, css)
+				return rules, kept
+			} else if css[end] == ';' {
+				kept = append(kept, css[:end+1])
+				css = css[end+1:]
+				continue
+			}
+			end = closingBrace(css, end)
+			kept = append(kept, css[:end])
+			css = css[end:]
+			continue
+		}
+
+		open := strings.IndexByte(css, '{')
+		if open == -1 {
+			return rules, kept
+		}
+		end := closingBrace(css, open)
+		selectors := css[:open]
+		body := strings.TrimSuffix(css[open+1:end], "}")
+		css = css[end:]
+
+		declarations := parseDeclarations(body)
+		for _, s := range strings.Split(selectors, ",") {
+			s = strings.TrimSpace(s)
+			if s == "" {
+				continue
+			}
+			if sel, ok := parseSelector(s); ok {
+				rules = append(rules, styleRule{selector: sel, declarations: declarations, order: order})
+			} else {
+				kept = append(kept, s+" {"+body+"}")
+			}
+		}
+	}
+}
+
+// closingBrace returns the index after the brace closing the one at open, or the length of css.
+func closingBrace(css string, open int) int {
+	depth := 0
+	for i := open; i < len(css); i++ {
+		switch css[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(css)
+}
+
+func parseDeclarations(body string) []declaration {
+	res := []declaration{}
+	for _, decl := range strings.Split(body, ";") {
+		i := strings.IndexByte(decl, ':')
+		if i == -1 {
+			continue
+		}
+		d := declaration{
+			property: strings.ToLower(strings.TrimSpace(decl[:i])),
+			value:    strings.TrimSpace(decl[i+1:]),
+		}
+		if lower := strings.ToLower(d.value); strings.HasSuffix(lower, "important") {
+			if bang := strings.LastIndexByte(d.value, '!'); bang != -1 &&
+				strings.TrimSpace(lower[bang+1:]) == "important" {
+				d.value = strings.TrimSpace(d.value[:bang])
+				d.important = true
+			}
+		}
+		if d.property != "" && d.value != "" {
+			res = append(res, d)
+		}
+	}
+	return res
+}
+
+// parseSelector parses the type, universal, id, class and attribute selectors with the descendant and child
+// combinators, it returns false for the other selectors.
+func parseSelector(s string) (*selector, bool) {
+	sel := &selector{}
+	for i := 0; i < len(s); {
+		c, next, ok := parseCompound(s, i)
+		if !ok {
+			return nil, false
+		}
+		sel.compounds = append(sel.compounds, c)
+
+		i = skipSpaces(s, next)
+		if i == len(s) {
+			break
+		}
+		switch {
+		case s[i] == '>':
+			sel.combinators = append(sel.combinators, '>')
+			i = skipSpaces(s, i+1)
+		case i > next:
+			sel.combinators = append(sel.combinators, ' ')
+		default:
+			return nil, false
+		}
+	}
+	if len(sel.compounds) == 0 || len(sel.combinators) != len(sel.compounds)-1 {
+		return nil, false
+	}
+
+	for _, c := range sel.compounds {
+		if c.id != "" {
+			sel.specificity[0]++
+		}
+		sel.specificity[1] += len(c.classes) + len(c.attrs)
+		if c.tag != "" {
+			sel.specificity[2]++
+		}
+	}
+	return sel, true
+}
+
+func parseCompound(s string, i int) (compound, int, bool) {
+	c := compound{}
+	start := i
+	if i < len(s) && s[i] == '*' {
+		i++
+	} else if name := ident(s, i); name != "" {
+		c.tag = strings.ToLower(name)
+		i += len(name)
+	}
+
+	for i < len(s) {
+		switch s[i] {
+		case '#', '.':
+			name := ident(s, i+1)
+			if name == "" {
+				return c, i, false
+			}
+			if s[i] == '#' {
+				c.id = name
+			} else {
+				c.classes = append(c.classes, name)
+			}
+			i += 1 + len(name)
+		case '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end == -1 {
+				return c, i, false
+			}
+			m, ok := parseAttrMatch(s[i+1 : i+end])
+			if !ok {
+				return c, i, false
+			}
+			c.attrs = append(c.attrs, m)
+			i += end + 1
+		default:
+			return c, i, i > start && (s[i] == ' ' || s[i] == '\t' || s[i] == '\n' || s[i] == '>')
+		}
+	}
+	return c, i, i > start
+}
+
+func parseAttrMatch(s string) (attrMatch, bool) {
+	key := ident(s, 0)
+	if key == "" {
+		return attrMatch{}, false
+	}
+	m := attrMatch{key: strings.ToLower(key)}
+	rest := strings.TrimSpace(s[len(key):])
+	if rest == "" {
+		return m, true
+	}
+	for _, op := range []string{"=", "~=", "^=", "$=", "*="} {
+		if strings.HasPrefix(rest, op) {
+			m.op = op
+			m.value = strings.Trim(strings.TrimSpace(rest[len(op):]), `"'`)
+			return m, true
+		}
+	}
+	return m, false
+}
+
+func ident(s string, i int) string {
+	j := i
+	for j < len(s) {
+		c := s[j]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			break
+		}
+		j++
+	}
+	return s[i:j]
+}
+
+func skipSpaces(s string, i int) int {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t' || s[i] == '\n' || s[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+// matches tells if the last element of stack, with its ancestors before it, is selected.
+func (s *selector) matches(stack []*element) bool {
+	return s.matchAt(len(s.compounds)-1, stack, len(stack)-1)
+}
+
+func (s *selector) matchAt(c int, stack []*element, i int) bool {
+	if i < 0 || !s.compounds[c].matches(stack[i]) {
+		return false
+	} else if c == 0 {
+		return true
+	}
+
+	if s.combinators[c-1] == '>' {
+		return s.matchAt(c-1, stack, i-1)
+	}
+	for j := i - 1; j >= 0; j-- {
+		if s.matchAt(c-1, stack, j) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c compound) matches(e *element) bool {
+	if c.tag != "" && c.tag != e.name {
+		return false
+	}
+	if c.id != "" && c.id != e.attr("id") {
+		return false
+	}
+	classes := strings.Fields(e.attr("class"))
+	for _, class := range c.classes {
+		if !contains(classes, class) {
+			return false
+		}
+	}
+	for _, m := range c.attrs {
+		value, ok := e.lookup(m.key)
+		if !ok || !m.matches(value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m attrMatch) matches(value string) bool {
+	switch m.op {
+	case "=":
+		return value == m.value
+	case "~=":
+		return contains(strings.Fields(value), m.value)
+	case "^=":
+		return m.value != "" && strings.HasPrefix(value, m.value)
+	case "$=":
+		return m.value != "" && strings.HasSuffix(value, m.value)
+	case "*=":
+		return m.value != "" && strings.Contains(value, m.value)
+	default:
+		return true
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (a specificity) less(b specificity) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
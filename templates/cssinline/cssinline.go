@@ -0,0 +1,283 @@
+// Package cssinline moves the CSS rules of the style elements of html messages to the style attributes of the
+// elements, since many mail clients drop the style elements. The rules that can't be inlined, like the media queries
+// and the :hover rules, are kept in a style element.
+//
+// The template actions ({{ ... }}) are kept as they are, so that the templates can be inlined once before they are
+// parsed instead of inlining every message.
+package cssinline
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+type (
+	element struct {
+		name  string
+		attrs []html.Attribute
+	}
+
+	// actions replaces the template actions with placeholders that the html tokenizer keeps as they are.
+	actions struct {
+		values []string
+	}
+)
+
+const placeholderPrefix = "cssinline-action-"
+
+var (
+	// implicitEnd are the elements closed by the start of an element of the same group.
+	implicitEnd = map[string]string{
+		"li": "li", "p": "p", "option": "option", "dt": "d", "dd": "d", "tr": "tr", "td": "td", "th": "td",
+	}
+
+	voidElements = map[string]bool{
+		"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true, "input": true,
+		"link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+	}
+)
+
+// Inline returns src with the rules of its style elements in the style attributes of the matching elements. The
+// declarations are applied by specificity and order, the declarations of the style attributes win over the rules
+// unless the rules are !important. The style elements for other media than all and screen are left untouched.
+//
+// The supported selectors are the type, universal, id, class and attribute selectors, with the descendant and child
+// combinators.
+func Inline(src []byte) ([]byte, error) {
+	a := &actions{}
+	doc := a.replace(string(src))
+
+	css, err := styles(doc)
+	if err != nil {
+		return nil, err
+	}
+	if len(css) == 0 {
+		return src, nil
+	}
+	rules, kept := parseCSS(strings.Join(css, "\n"))
+
+	out := &bytes.Buffer{}
+	stack := []*element{}
+	keptWritten := false
+	z := html.NewTokenizer(strings.NewReader(doc))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if err := z.Err(); err != io.EOF {
+				return nil, err
+			}
+			break
+		}
+		raw := append([]byte{}, z.Raw()...)
+		token := z.Token()
+
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if token.Data == "style" && inlined(token) {
+				// the style element is replaced by the rules that can't be inlined
+				skipStyle(z)
+				if !keptWritten && len(kept) != 0 {
+					out.WriteString("<style>\n" + strings.Join(kept, "\n") + "\n</style>")
+				}
+				keptWritten = true
+				continue
+			}
+
+			e := &element{name: token.Data, attrs: token.Attr}
+			if group, ok := implicitEnd[e.name]; ok && len(stack) != 0 && implicitEnd[stack[len(stack)-1].name] == group {
+				stack = stack[:len(stack)-1]
+			}
+			stack = append(stack, e)
+			if style := computeStyle(rules, stack, e.attr("style")); style != "" {
+				e.set("style", style)
+				raw = []byte(e.render(tt == html.SelfClosingTagToken))
+			}
+			if tt == html.SelfClosingTagToken || voidElements[e.name] {
+				stack = stack[:len(stack)-1]
+			}
+
+		case html.EndTagToken:
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].name == token.Data {
+					stack = stack[:i]
+					break
+				}
+			}
+		}
+		out.Write(raw)
+	}
+
+	return []byte(a.restore(out.String())), nil
+}
+
+// styles returns the CSS of the style elements to inline.
+func styles(doc string) ([]string, error) {
+	css := []string{}
+	z := html.NewTokenizer(strings.NewReader(doc))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return nil, err
+			}
+			return css, nil
+		case html.StartTagToken:
+			if token := z.Token(); token.Data == "style" && inlined(token) {
+				css = append(css, skipStyle(z))
+			}
+		}
+	}
+}
+
+// inlined tells if the rules of a style element are inlined, the elements for some media only are kept.
+func inlined(token html.Token) bool {
+	for _, a := range token.Attr {
+		if a.Key == "media" {
+			media := strings.ToLower(strings.TrimSpace(a.Val))
+			return media == "" || media == "all" || media == "screen"
+		}
+	}
+	return true
+}
+
+// skipStyle reads the content of a style element until its end tag, and returns it.
+func skipStyle(z *html.Tokenizer) string {
+	css := strings.Builder{}
+	for {
+		switch z.Next() {
+		case html.ErrorToken, html.EndTagToken:
+			return css.String()
+		case html.TextToken:
+			css.Write(z.Text())
+		}
+	}
+}
+
+// computeStyle returns the style attribute of the last element of stack.
+func computeStyle(rules []styleRule, stack []*element, inline string) string {
+	matched := []styleRule{}
+	for _, rule := range rules {
+		if rule.selector.matches(stack) {
+			matched = append(matched, rule)
+		}
+	}
+	if len(matched) == 0 {
+		return ""
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if matched[i].selector.specificity != matched[j].selector.specificity {
+			return matched[i].selector.specificity.less(matched[j].selector.specificity)
+		}
+		return matched[i].order < matched[j].order
+	})
+
+	values := map[string]declaration{}
+	properties := []string{}
+	// own are the properties set by the style attribute
+	own := map[string]bool{}
+	set := func(d declaration) {
+		if _, ok := values[d.property]; !ok {
+			properties = append(properties, d.property)
+		}
+		values[d.property] = d
+	}
+	for _, rule := range matched {
+		for _, d := range rule.declarations {
+			if !values[d.property].important || d.important {
+				set(d)
+			}
+		}
+	}
+	for _, d := range parseDeclarations(inline) {
+		// the declarations of the element win over the rules which are not !important
+		if !values[d.property].important || d.important {
+			set(d)
+			own[d.property] = true
+		}
+	}
+
+	res := make([]string, len(properties))
+	for i, property := range properties {
+		d := values[property]
+		res[i] = property + ": " + d.value
+		if d.important && own[property] {
+			// the !important of the rules is dropped, so that the media queries can still override them
+			res[i] += " !important"
+		}
+	}
+	return strings.Join(res, "; ") + ";"
+}
+
+func (e *element) lookup(key string) (string, bool) {
+	for _, a := range e.attrs {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func (e *element) attr(key string) string {
+	value, _ := e.lookup(key)
+	return value
+}
+
+func (e *element) set(key, value string) {
+	for i, a := range e.attrs {
+		if a.Key == key {
+			e.attrs[i].Val = value
+			return
+		}
+	}
+	e.attrs = append(e.attrs, html.Attribute{Key: key, Val: value})
+}
+
+// render writes the start tag, the placeholders of the actions between the attributes are written alone.
+func (e *element) render(selfClosing bool) string {
+	res := strings.Builder{}
+	res.WriteString("<" + e.name)
+	for _, a := range e.attrs {
+		res.WriteString(" " + a.Key)
+		if !(strings.HasPrefix(a.Key, placeholderPrefix) && a.Val == "") {
+			res.WriteString(`="` + html.EscapeString(a.Val) + `"`)
+		}
+	}
+	if selfClosing {
+		res.WriteString(" /")
+	}
+	res.WriteString(">")
+	return res.String()
+}
+
+func (a *actions) replace(doc string) string {
+	res := strings.Builder{}
+	for {
+		start := strings.Index(doc, "{{")
+		if start == -1 {
+			break
+		}
+		end := strings.Index(doc[start:], "}}")
+		if end == -1 {
+			break
+		}
+		end += start + 2
+		res.WriteString(doc[:start] + placeholderPrefix + strconv.Itoa(len(a.values)) + "-")
+		a.values = append(a.values, doc[start:end])
+		doc = doc[end:]
+	}
+	res.WriteString(doc)
+	return res.String()
+}
+
+func (a *actions) restore(doc string) string {
+	// the actions of the rules can be copied to several elements
+	for i, value := range a.values {
+		doc = strings.ReplaceAll(doc, placeholderPrefix+strconv.Itoa(i)+"-", value)
+	}
+	return doc
+}
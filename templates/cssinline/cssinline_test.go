@@ -0,0 +1,64 @@
+package cssinline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func inline(t *testing.T, src string) string {
+	res, err := Inline([]byte(src))
+	require.Nil(t, err)
+	return string(res)
+}
+
+func TestInline(t *testing.T) {
+	src := `<html><head><style type="text/css">
+/* the base styles */
+p { color: red; margin: 0 }
+.intro, h1 { font-size: 16px; }
+#main p.intro { color: blue !important; }
+a:hover { color: green }
+@media (max-width: 600px) { p { font-size: 12px !important; } }
+</style></head><body><div id="main"><h1>Hello</h1><p class="intro" style="color: black; padding: 1px">Welcome</p><p style="color: black">Bye</p><br></div></body></html>`
+
+	require.Equal(t, `<html><head><style>
+a:hover { color: green }
+@media (max-width: 600px) { p { font-size: 12px !important; } }
+</style></head><body><div id="main"><h1 style="font-size: 16px;">Hello</h1>`+
+		`<p class="intro" style="color: blue; margin: 0; font-size: 16px; padding: 1px;">Welcome</p>`+
+		`<p style="color: black; margin: 0;">Bye</p><br></div></body></html>`, inline(t, src))
+}
+
+func TestSelectors(t *testing.T) {
+	src := `<style>
+td > a { color: red }
+table a { font-weight: bold }
+div > a { color: blue }
+[href^="https"] { text-decoration: none }
+a[title] { border: 0 }
+* { margin: 0 }
+li + li { margin-top: 4px }
+</style><table><tr><td><a href="https://example.com">a</a></td></tr></table><ul><li>one<li>two</ul>`
+
+	require.Equal(t, `<style>
+li + li { margin-top: 4px }
+</style><table style="margin: 0;"><tr style="margin: 0;"><td style="margin: 0;">`+
+		`<a href="https://example.com" style="margin: 0; color: red; font-weight: bold; text-decoration: none;">a</a>`+
+		`</td></tr></table><ul style="margin: 0;"><li style="margin: 0;">one<li style="margin: 0;">two</ul>`, inline(t, src))
+}
+
+func TestActions(t *testing.T) {
+	src := `<style>a { color: {{ .Data.Color }}; } .hidden { display: none }</style>` +
+		`<a href="{{ .URL }}" title="{{ t "login" }}" {{ if .Data.Hidden }}class="hidden"{{ end }}>{{ t "login" }}</a>` +
+		`{{ range .Data.Items }}<p class="hidden">{{ . }}</p>{{ end }}`
+
+	require.Equal(t, `<a href="{{ .URL }}" title="{{ t "login" }}" {{ if .Data.Hidden }}class="hidden" {{ end }} `+
+		`style="color: {{ .Data.Color }};">{{ t "login" }}</a>`+
+		`{{ range .Data.Items }}<p class="hidden" style="display: none;">{{ . }}</p>{{ end }}`, inline(t, src))
+}
+
+func TestNoStyle(t *testing.T) {
+	src := `<p class="a">{{ .Email }}</p><style media="print">p { color: black }</style>`
+	require.Equal(t, src, inline(t, src))
+}
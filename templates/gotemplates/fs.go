@@ -0,0 +1,323 @@
+package gotemplates
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+type (
+	// Manifest describes the files of the locales, it replaces the default layout when the directory contains
+	// a manifest.json file. The paths are relative to the directory.
+	Manifest struct {
+		// Default is the default language, optional.
+		Default string                    `json:"default"`
+		Locales map[string]ManifestLocale `json:"locales"`
+		// Layout are the layout files, optional, see SetLayout.
+		Layout ManifestLayout `json:"layout"`
+		// Partials is a directory of partials, optional. The partials are named after their files without the
+		// extension, .txt for the text version and .html for the html version (ie: footer.txt and footer.html).
+		Partials string `json:"partials"`
+	}
+
+	ManifestLayout struct {
+		Text string `json:"text"`
+		HTML string `json:"html"`
+	}
+
+	ManifestLocale struct {
+		Subject string `json:"subject"`
+		Text    string `json:"text"`
+		HTML    string `json:"html"`
+	}
+
+	// FileError is an error of a template file.
+	FileError struct {
+		File string
+		Err  error
+	}
+
+	// FilesError holds all the errors found by AddFS.
+	FilesError []*FileError
+)
+
+const (
+	ManifestFile = "manifest.json"
+	SubjectFile  = "subject.txt"
+	TextFile     = "body.txt"
+	HTMLFile     = "body.html"
+	LayoutText   = "layout.txt"
+	LayoutHTML   = "layout.html"
+	PartialsDir  = "partials"
+)
+
+var (
+	ErrNoLocale = errors.New("no locale found")
+)
+
+// NewTemplatesFS creates the templates from the directory dir of fsys, see AddFS.
+func NewTemplatesFS(fsys fs.FS, dir string, funcs ...FuncMap) (*GoTemplates, error) {
+	res := NewTemplates()
+	if err := res.AddFS(fsys, dir, funcs...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// AddFS adds all the locales of the directory dir of fsys (ie: an embed.FS). Each locale is a sub directory named
+// after its language, with the files subject.txt, body.txt and body.html (one of the bodies can be missing). The
+// optional layout.txt and layout.html files are the layouts and the partials directory holds the partials:
+//
+//	templates/layout.html
+//	templates/partials/footer.html
+//	templates/en/subject.txt
+//	templates/en/body.txt
+//	templates/en/body.html
+//	templates/fr/subject.txt
+//	...
+//
+// Another layout can be described with a manifest.json file in dir, see Manifest. The functions of funcs can be
+// used in all the templates. All the files are checked before anything is added: when a file is invalid, nothing
+// is added and a FilesError listing every invalid file is returned.
+func (t *GoTemplates) AddFS(fsys fs.FS, dir string, funcs ...FuncMap) error {
+	manifest, err := readManifest(fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	errs := FilesError{}
+	fail := func(file string, err error) {
+		errs = append(errs, &FileError{File: path.Join(dir, file), Err: err})
+	}
+
+	l := t.layout
+	l.partials = append([]partial{}, l.partials...)
+	if manifest.Layout.Text != "" || manifest.Layout.HTML != "" {
+		l.funcs = funcs
+	}
+	if txt, err := readOptional(fsys, dir, manifest.Layout.Text); err != nil {
+		fail(manifest.Layout.Text, err)
+	} else if txt != "" {
+		l.txt = source{name: path.Join(dir, manifest.Layout.Text), text: txt}
+	}
+	if html, err := readOptional(fsys, dir, manifest.Layout.HTML); err != nil {
+		fail(manifest.Layout.HTML, err)
+	} else if html != "" {
+		l.html = source{name: path.Join(dir, manifest.Layout.HTML), text: html}
+	}
+
+	if manifest.Partials != "" {
+		partials, err := readPartials(fsys, path.Join(dir, manifest.Partials))
+		if err != nil {
+			fail(manifest.Partials, err)
+		}
+		l.partials = append(l.partials, partials...)
+	}
+
+	added := map[language.Tag]localeSource{}
+	for lang, files := range manifest.Locales {
+		tag, src, fileErrs := loadLocale(fsys, dir, lang, files, funcs)
+		if len(fileErrs) != 0 {
+			errs = append(errs, fileErrs...)
+			continue
+		}
+		added[tag] = src
+	}
+	// the valid files are compiled too, so that all the errors are reported at once
+	c := t.compile(l, added)
+	if errs = append(errs, c.errs...); len(errs) != 0 {
+		return dedupe(errs).sorted()
+	}
+	if len(added) == 0 {
+		return ErrNoLocale
+	}
+
+	t.apply(c)
+	if manifest.Default != "" {
+		return t.SetDefaultLanguage(manifest.Default)
+	}
+	return nil
+}
+
+// readManifest reads the manifest of dir, or creates it from the sub directories.
+func readManifest(fsys fs.FS, dir string) (*Manifest, error) {
+	manifest := &Manifest{}
+
+	data, err := fs.ReadFile(fsys, path.Join(dir, ManifestFile))
+	if err == nil {
+		if err := json.Unmarshal(data, manifest); err != nil {
+			return nil, &FileError{File: path.Join(dir, ManifestFile), Err: err}
+		}
+		return manifest, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	manifest.Layout = ManifestLayout{Text: LayoutText, HTML: LayoutHTML}
+	manifest.Locales = map[string]ManifestLocale{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		lang := entry.Name()
+		if lang == PartialsDir {
+			manifest.Partials = PartialsDir
+			continue
+		}
+		manifest.Locales[lang] = ManifestLocale{
+			Subject: path.Join(lang, SubjectFile),
+			Text:    path.Join(lang, TextFile),
+			HTML:    path.Join(lang, HTMLFile),
+		}
+	}
+	return manifest, nil
+}
+
+// readPartials reads the .txt and .html files of dir, the other files are ignored.
+func readPartials(fsys fs.FS, dir string) ([]partial, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	partials := map[string]*partial{}
+	names := []string{}
+	for _, entry := range entries {
+		ext := path.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".txt" && ext != ".html") {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), ext)
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		p, ok := partials[name]
+		if !ok {
+			p = &partial{name: name}
+			partials[name] = p
+			names = append(names, name)
+		}
+		src := source{name: path.Join(dir, entry.Name()), text: string(data)}
+		if ext == ".txt" {
+			p.txt = src
+		} else {
+			p.html = src
+		}
+	}
+
+	res := make([]partial, len(names))
+	for i, name := range names {
+		res[i] = *partials[name]
+	}
+	return res, nil
+}
+
+func loadLocale(fsys fs.FS, dir, lang string, files ManifestLocale, funcs []FuncMap) (language.Tag, localeSource, FilesError) {
+	errs := FilesError{}
+	fail := func(file string, err error) {
+		errs = append(errs, &FileError{File: path.Join(dir, file), Err: err})
+	}
+
+	res := localeSource{funcs: funcs}
+	tag, err := parseLanguage(lang)
+	if err != nil {
+		fail(lang, err)
+	}
+
+	subject, err := readOptional(fsys, dir, files.Subject)
+	if err != nil {
+		fail(files.Subject, err)
+	} else if strings.TrimSpace(subject) == "" {
+		fail(files.Subject, ErrSubjectEmpty)
+	}
+	res.subject = source{name: path.Join(dir, files.Subject), text: strings.TrimSpace(subject)}
+
+	txt, err := readOptional(fsys, dir, files.Text)
+	if err != nil {
+		fail(files.Text, err)
+	}
+	res.txt = source{name: path.Join(dir, files.Text), text: txt}
+
+	html, err := readOptional(fsys, dir, files.HTML)
+	if err != nil {
+		fail(files.HTML, err)
+	}
+	res.html = source{name: path.Join(dir, files.HTML), text: html}
+
+	if len(errs) == 0 && txt == "" && html == "" {
+		fail(lang, ErrTemplatesEmpty)
+	}
+	return tag, res, errs
+}
+
+// readOptional returns an empty string when the file doesn't exist.
+func readOptional(fsys fs.FS, dir, file string) (string, error) {
+	if file == "" {
+		return "", nil
+	}
+	data, err := fs.ReadFile(fsys, path.Join(dir, file))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (e *FileError) Error() string {
+	return e.File + ": " + e.Err.Error()
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+func (e FilesError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e FilesError) sorted() FilesError {
+	sort.Slice(e, func(i, j int) bool {
+		return e[i].File < e[j].File
+	})
+	return e
+}
+
+// dedupe removes the errors reported by several locales, like the errors of the layout.
+func dedupe(errs FilesError) FilesError {
+	seen := map[string]bool{}
+	res := FilesError{}
+	for _, err := range errs {
+		if msg := err.Error(); !seen[msg] {
+			seen[msg] = true
+			res = append(res, err)
+		}
+	}
+	return res
+}
+
+func sortedTags(sources map[language.Tag]localeSource) []language.Tag {
+	res := make([]language.Tag, 0, len(sources))
+	for tag := range sources {
+		res = append(res, tag)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].String() < res[j].String()
+	})
+	return res
+}
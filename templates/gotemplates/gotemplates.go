@@ -7,10 +7,14 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	txtTemplate "text/template"
 	"time"
 
 	"github.com/fdelbos/mauth/templates"
+	"github.com/fdelbos/mauth/templates/cssinline"
+	"github.com/fdelbos/mauth/templates/htmltext"
+	"github.com/fdelbos/mauth/templates/localized"
 	"golang.org/x/text/language"
 )
 
@@ -18,15 +22,28 @@ type (
 	locale struct {
 		txt     *txtTemplate.Template
 		html    *htmlTemplate.Template
-		subject string
+		subject *txtTemplate.Template
+		// inlineCSS inlines the CSS of the messages, when the template couldn't be inlined
+		inlineCSS bool
 	}
 
+	// FuncMap are the functions available in the templates, see text/template.FuncMap.
+	FuncMap map[string]interface{}
+
 	GoTemplates struct {
-		locales map[language.Tag]locale
+		strict bool
+		// noText disables the text version made from the html template
+		noText    bool
+		inlineCSS bool
+		layout    layout
+		sources   map[language.Tag]localeSource
+		locales   map[language.Tag]locale
 		//txt     map[language.Tag]*txtTemplate.Template
 		//html    map[language.Tag]*htmlTemplate.Template
 		tags    []language.Tag
 		matcher language.Matcher
+		// now is the clock of the relative function
+		now func() time.Time
 	}
 
 	tmplType int
@@ -48,8 +65,27 @@ var (
 func NewTemplates() *GoTemplates {
 	return &GoTemplates{
 		tags:    []language.Tag{},
+		sources: map[language.Tag]localeSource{},
 		locales: map[language.Tag]locale{},
+		now:     time.Now,
+	}
+}
+
+// SetClock sets the clock giving the current time to the relative function, it defaults to time.Now.
+func (t *GoTemplates) SetClock(now func() time.Time) {
+	t.now = now
+}
+
+func (t *GoTemplates) clock() time.Time {
+	if t.now == nil {
+		return time.Now()
 	}
+	return t.now()
+}
+
+// localizedFuncs returns the built-in functions of the templates of a language, see the localized package.
+func (t *GoTemplates) localizedFuncs(tag language.Tag) FuncMap {
+	return FuncMap(localized.Funcs(tag, t.clock))
 }
 
 func (t *GoTemplates) hasTemplate(tag language.Tag, tmplType tmplType) bool {
@@ -101,46 +137,90 @@ func (t *GoTemplates) addLanguage(tag language.Tag) error {
 	return nil
 }
 
-func (t *GoTemplates) Add(lang, subject, txt, html string) error {
+// Add adds the templates of a language. The subject is a text template too, it receives the same data as the
+// bodies and the line breaks of the result are removed. The functions of funcs can be used in the templates, in
+// addition to the localized functions (relative, formatDate, formatTime, formatDateTime and plural).
+func (t *GoTemplates) Add(lang, subject, txt, html string, funcs ...FuncMap) error {
 	if subject == "" {
 		return ErrSubjectEmpty
 	} else if txt == "" && html == "" {
 		return ErrTemplatesEmpty
 	}
 
-	tag, err := language.Parse(lang)
+	tag, err := parseLanguage(lang)
 	if err != nil {
-		return ErrUnsupportedLanguage
+		return err
 	}
 
-	locale := locale{subject: subject}
+	err = t.setLayout(t.layout, map[language.Tag]localeSource{tag: {
+		subject: source{text: subject},
+		txt:     source{text: txt},
+		html:    source{text: html},
+		funcs:   funcs,
+	}})
+	if errs, ok := err.(FilesError); ok {
+		// the templates have no file name
+		return errs[0].Err
+	}
+	return err
+}
 
-	if txt != "" {
-		tmpl, err := txtTemplate.New("").Parse(txt)
-		if err != nil {
-			return err
-		}
-		locale.txt = tmpl
+func parseLanguage(lang string) (language.Tag, error) {
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return tag, ErrUnsupportedLanguage
 	}
+	return tag, nil
+}
 
-	if html != "" {
-		tmpl, err := htmlTemplate.New("").Parse(html)
-		if err != nil {
-			return err
+// SetStrict makes the execution of the templates fail when they use a missing key of a map, like .Data.Name when
+// Name wasn't given. By default the missing keys are rendered as "<no value>" in the text templates, and as an empty
+// string in the html templates.
+func (t *GoTemplates) SetStrict(strict bool) {
+	t.strict = strict
+	for _, locale := range t.locales {
+		locale.subject.Option(t.missingKey())
+		if locale.txt != nil {
+			locale.txt.Option(t.missingKey())
+		}
+		if locale.html != nil {
+			locale.html.Option(t.missingKey())
 		}
-		locale.html = tmpl
 	}
+}
 
-	t.locales[tag] = locale
+// SetInlineCSS moves the CSS rules of the style elements of the html templates to the style attributes of the
+// elements, see the cssinline package. The templates are inlined once before they are parsed, except when an html
+// layout or partial is set: the messages are inlined instead since the rules and the elements can be in different
+// templates.
+func (t *GoTemplates) SetInlineCSS(enabled bool) error {
+	prev := t.inlineCSS
+	t.inlineCSS = enabled
+	if err := t.setLayout(t.layout, nil); err != nil {
+		t.inlineCSS = prev
+		return err
+	}
+	return nil
+}
+
+// SetTextFromHTML enables the text version made from the html template for the languages without a text template,
+// so that the messages always have a text part. It is enabled by default.
+func (t *GoTemplates) SetTextFromHTML(enabled bool) {
+	t.noText = !enabled
+}
 
-	return t.addLanguage(tag)
+func (t *GoTemplates) missingKey() string {
+	if t.strict {
+		return "missingkey=error"
+	}
+	return "missingkey=default"
 }
 
-func (t *GoTemplates) AddBytes(lang, subject string, txt, html []byte) error {
-	return t.Add(lang, subject, string(txt), string(html))
+func (t *GoTemplates) AddBytes(lang, subject string, txt, html []byte, funcs ...FuncMap) error {
+	return t.Add(lang, subject, string(txt), string(html), funcs...)
 }
 
-func (t *GoTemplates) AddReader(lang, subject string, txt, html io.Reader) error {
+func (t *GoTemplates) AddReader(lang, subject string, txt, html io.Reader, funcs ...FuncMap) error {
 	var err error
 
 	var tmplTxt []byte
@@ -158,27 +238,25 @@ func (t *GoTemplates) AddReader(lang, subject string, txt, html io.Reader) error
 			return err
 		}
 	}
-	return t.AddBytes(lang, subject, tmplTxt, tmplHTML)
+	return t.AddBytes(lang, subject, tmplTxt, tmplHTML, funcs...)
 }
 
-func (t GoTemplates) generate(tag language.Tag, email, url string, expiration time.Time) (*templates.TemplateResult, error) {
+func (t GoTemplates) generate(tag language.Tag, params templates.Params) (*templates.TemplateResult, error) {
 	if !t.hasTemplate(tag, anyType) {
 		return nil, ErrNoTemplateForLanguage
 	}
 
 	locale := t.locales[tag]
 
-	res := templates.TemplateResult{Subject: locale.subject}
+	subject := strings.Builder{}
+	if err := locale.subject.Execute(&subject, params); err != nil {
+		return nil, err
+	}
+	res := templates.TemplateResult{Subject: templates.SanitizeSubject(subject.String())}
 
 	if locale.txt != nil {
 		dest := bytes.Buffer{}
-		data := struct {
-			Email      string
-			URL        string
-			Expiration time.Time
-		}{Email: email, URL: url, Expiration: expiration}
-
-		if err := locale.txt.Execute(&dest, data); err != nil {
+		if err := locale.txt.Execute(&dest, params); err != nil {
 			return nil, err
 		}
 		res.TXT = dest.Bytes()
@@ -186,49 +264,58 @@ func (t GoTemplates) generate(tag language.Tag, email, url string, expiration ti
 
 	if locale.html != nil {
 		dest := bytes.Buffer{}
-		data := struct {
-			Email      string
-			URL        string
-			Expiration time.Time
-		}{Email: email, URL: url, Expiration: expiration}
-
-		if err := locale.html.Execute(&dest, data); err != nil {
+		if err := locale.html.Execute(&dest, params); err != nil {
 			return nil, err
 		}
 		res.HTML = dest.Bytes()
+		if locale.inlineCSS {
+			html, err := cssinline.Inline(res.HTML)
+			if err != nil {
+				return nil, err
+			}
+			res.HTML = html
+		}
+	}
+
+	if len(res.TXT) == 0 && len(res.HTML) != 0 && !t.noText {
+		txt, err := htmltext.Convert(res.HTML)
+		if err != nil {
+			return nil, err
+		}
+		res.TXT = txt
 	}
 
 	return &res, nil
 }
 
-func (t GoTemplates) Generate(email, url string, expiration time.Time) (*templates.TemplateResult, error) {
+func (t GoTemplates) Generate(params templates.Params) (*templates.TemplateResult, error) {
 	if t.tags == nil || len(t.tags) == 0 {
 		return nil, ErrNoTemplateForLanguage
 	}
-	return t.generate(t.tags[0], email, url, expiration)
+	return t.generate(t.tags[0], params)
 }
 
-func (t GoTemplates) GenerateForLang(lang string, email, url string, expiration time.Time) (*templates.TemplateResult, error) {
+func (t GoTemplates) GenerateForLang(lang string, params templates.Params) (*templates.TemplateResult, error) {
 	if t.tags == nil || len(t.tags) == 0 {
 		return nil, ErrNoTemplateForLanguage
 	}
 	tag, err := language.Parse(lang)
 	if err != nil {
-		return t.generate(t.tags[0], email, url, expiration)
+		return t.generate(t.tags[0], params)
 	}
 	_, idx, _ := t.matcher.Match(tag)
-	return t.generate(t.tags[idx], email, url, expiration)
+	return t.generate(t.tags[idx], params)
 }
 
-func (t GoTemplates) GenerateForRequest(r *http.Request, email, url string, expiration time.Time) (*templates.TemplateResult, error) {
+func (t GoTemplates) GenerateForRequest(r *http.Request, params templates.Params) (*templates.TemplateResult, error) {
 	if t.tags == nil || len(t.tags) == 0 {
 		return nil, ErrNoTemplateForLanguage
 	}
 	tags, _, err := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
 	if err != nil {
-		return t.generate(t.tags[0], email, url, expiration)
+		return t.generate(t.tags[0], params)
 	}
 	_, idx, _ := t.matcher.Match(tags...)
 
-	return t.generate(t.tags[idx], email, url, expiration)
+	return t.generate(t.tags[idx], params)
 }
@@ -5,9 +5,11 @@ import (
 	"net/http"
 	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/dchest/uniuri"
+	"github.com/fdelbos/mauth/templates"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/text/language"
 )
@@ -29,6 +31,7 @@ var (
 	expiration = time.Now()
 	email      = "my.email@example.com"
 	url        = uniuri.New()
+	params     = templates.Params{Email: email, Original: "My.Email@Example.com", URL: url, Expiration: expiration}
 )
 
 func createTemplates(t *testing.T) *GoTemplates {
@@ -59,7 +62,7 @@ func TestSetDefaultLanguage(t *testing.T) {
 
 func TestGenerate(t *testing.T) {
 	tmpl := createTemplates(t)
-	res, err := tmpl.Generate(email, url, expiration)
+	res, err := tmpl.Generate(params)
 	require.Nil(t, err)
 	require.Nil(t, validateTemplate("en", true, res.HTML))
 	require.Nil(t, validateTemplate("en", false, res.TXT))
@@ -77,7 +80,7 @@ func TestGenerateForLang(t *testing.T) {
 		{"zh", "en"},
 		{"invalid!", "en"},
 	} {
-		res, err := tmpl.GenerateForLang(al.lang, email, url, expiration)
+		res, err := tmpl.GenerateForLang(al.lang, params)
 		require.Nil(t, err)
 		require.Nil(t, validateTemplate(al.expected, true, res.HTML), al.lang)
 		require.Nil(t, validateTemplate(al.expected, false, res.TXT), al.lang)
@@ -100,7 +103,7 @@ func TestGenerateForRequest(t *testing.T) {
 	} {
 		r, _ := http.NewRequest("GET", "example.com", strings.NewReader("Hello"))
 		r.Header.Set("Accept-Language", al.header)
-		res, err := tmpl.GenerateForRequest(r, email, url, expiration)
+		res, err := tmpl.GenerateForRequest(r, params)
 		require.Nil(t, err, al.header)
 		require.Nil(t, validateTemplate(al.expected, true, res.HTML), al.header, al.expected)
 		require.Nil(t, validateTemplate(al.expected, false, res.TXT), al.header, al.expected)
@@ -137,3 +140,294 @@ func validateTemplate(lang string, html bool, res []byte) error {
 
 	return nil
 }
+
+func TestAddFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"templates/en/subject.txt": {Data: []byte("Your login link\n")},
+		"templates/en/body.txt":    {Data: []byte(`text: lang=en {{ .Email }} {{ .URL }} {{ .Expiration.Format "Jan 02, 2006"}}`)},
+		"templates/en/body.html":   {Data: []byte(`<div>lang=en {{ .Email }} {{ .URL }} {{ .Expiration.Format "Jan 02, 2006"}}</div>`)},
+		"templates/fr/subject.txt": {Data: []byte("Votre lien de connexion")},
+		"templates/fr/body.html":   {Data: []byte(`<div>lang=fr {{ .Email }} {{ .URL }} {{ .Expiration.Format "Jan 02, 2006"}}</div>`)},
+		"templates/README.md":      {Data: []byte("ignored")},
+	}
+
+	tmpl, err := NewTemplatesFS(fsys, "templates")
+	require.Nil(t, err)
+	require.Nil(t, tmpl.SetDefaultLanguage("en"))
+
+	res, err := tmpl.Generate(params)
+	require.Nil(t, err)
+	require.Equal(t, "Your login link", res.Subject)
+	require.Nil(t, validateTemplate("en", true, res.HTML))
+	require.Nil(t, validateTemplate("en", false, res.TXT))
+
+	res, err = tmpl.GenerateForLang("fr", params)
+	require.Nil(t, err)
+	require.Equal(t, "Votre lien de connexion", res.Subject)
+	require.Nil(t, validateTemplate("fr", true, res.HTML))
+	// the text is made from the html template
+	require.Equal(t, "lang=fr "+email+" "+url+" "+expiration.Format("Jan 02, 2006")+"\n", string(res.TXT))
+
+	tmpl.SetTextFromHTML(false)
+	res, err = tmpl.GenerateForLang("fr", params)
+	require.Nil(t, err)
+	require.Nil(t, res.TXT)
+}
+
+func TestAddFSManifest(t *testing.T) {
+	fsys := fstest.MapFS{
+		"manifest.json": {Data: []byte(`{
+			"default": "fr",
+			"locales": {
+				"en": {"subject": "subjects/en.txt", "text": "english.txt"},
+				"fr": {"subject": "subjects/fr.txt", "text": "french.txt"}
+			}
+		}`)},
+		"subjects/en.txt": {Data: []byte("hello")},
+		"subjects/fr.txt": {Data: []byte("bonjour")},
+		"english.txt":     {Data: []byte(`text: lang=en {{ .Email }} {{ .URL }} {{ .Expiration.Format "Jan 02, 2006"}}`)},
+		"french.txt":      {Data: []byte(`text: lang=fr {{ .Email }} {{ .URL }} {{ .Expiration.Format "Jan 02, 2006"}}`)},
+	}
+
+	tmpl, err := NewTemplatesFS(fsys, ".")
+	require.Nil(t, err)
+	res, err := tmpl.Generate(params)
+	require.Nil(t, err)
+	require.Equal(t, "bonjour", res.Subject)
+	require.Nil(t, validateTemplate("fr", false, res.TXT))
+}
+
+func TestAddFSErrors(t *testing.T) {
+	fsys := fstest.MapFS{
+		"en/subject.txt":     {Data: []byte("hello")},
+		"en/body.txt":        {Data: []byte(`{{ .Email `)},
+		"en/body.html":       {Data: []byte(`{{ end }}`)},
+		"fr/subject.txt":     {Data: []byte(" \n")},
+		"fr/body.txt":        {Data: []byte(`ok`)},
+		"de/subject.txt":     {Data: []byte("hallo")},
+		"invalid!/body.html": {Data: []byte(`ok`)},
+	}
+
+	tmpl := NewTemplates()
+	err := tmpl.AddFS(fsys, ".")
+	filesErr := FilesError{}
+	require.True(t, errors.As(err, &filesErr))
+
+	files := []string{}
+	for _, fileErr := range filesErr {
+		files = append(files, fileErr.File)
+	}
+	require.Equal(t, []string{"de", "en/body.html", "en/body.txt", "fr/subject.txt", "invalid!", "invalid!/subject.txt"}, files)
+	require.True(t, errors.Is(filesErr[0], ErrTemplatesEmpty))
+	require.True(t, errors.Is(filesErr[3], ErrSubjectEmpty))
+	require.True(t, errors.Is(filesErr[4], ErrUnsupportedLanguage))
+
+	// nothing is added when a file is invalid
+	require.Empty(t, tmpl.tags)
+
+	_, err = NewTemplatesFS(fstest.MapFS{}, ".")
+	require.Equal(t, ErrNoLocale, err)
+}
+
+func TestSubjectTemplate(t *testing.T) {
+	tmpl := NewTemplates()
+	require.Nil(t, tmpl.Add("en", `Your link for {{ .Original }}, valid until {{ .Expiration.Format "15:04" }}`, "body", ""))
+	res, err := tmpl.Generate(params)
+	require.Nil(t, err)
+	require.Equal(t, "Your link for My.Email@Example.com, valid until "+expiration.Format("15:04"), res.Subject)
+
+	// line breaks can't add headers
+	injected := params
+	injected.Original = "john@example.com\r\nBcc: eve@example.com\n"
+	res, err = tmpl.Generate(injected)
+	require.Nil(t, err)
+	require.NotContains(t, res.Subject, "\r")
+	require.NotContains(t, res.Subject, "\n")
+	require.True(t, strings.HasPrefix(res.Subject, "Your link for john@example.com Bcc: eve@example.com ,"))
+
+	require.NotNil(t, tmpl.Add("en", `{{ .Email `, "body", ""))
+}
+
+func TestFuncsAndData(t *testing.T) {
+	tmpl := NewTemplates()
+	funcs := FuncMap{"upper": strings.ToUpper}
+	require.Nil(t, tmpl.Add("en",
+		`{{ .Data.product }} login`,
+		`Hello {{ upper .Data.name }}`,
+		`<a href="{{ .Data.support }}">{{ .Data.missing }}</a>`,
+		funcs))
+
+	data := params
+	data.Data = map[string]interface{}{"product": "Example", "name": "john", "support": "https://example.com/help"}
+	res, err := tmpl.Generate(data)
+	require.Nil(t, err)
+	require.Equal(t, "Example login", res.Subject)
+	require.Equal(t, "Hello JOHN", string(res.TXT))
+	require.Equal(t, `<a href="https://example.com/help"></a>`, string(res.HTML))
+
+	tmpl.SetStrict(true)
+	_, err = tmpl.Generate(data)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "missing")
+
+	data.Data["missing"] = "help"
+	res, err = tmpl.Generate(data)
+	require.Nil(t, err)
+	require.Equal(t, `<a href="https://example.com/help">help</a>`, string(res.HTML))
+
+	// unknown functions are parse errors
+	require.NotNil(t, tmpl.Add("fr", "subject", `{{ lower .Email }}`, ""))
+}
+
+func TestLocalizedFuncs(t *testing.T) {
+	current := time.Date(2021, time.March, 5, 13, 45, 0, 0, time.UTC)
+	tmpl := NewTemplates()
+	tmpl.SetClock(func() time.Time { return current })
+	body := `{{ relative .Expiration }}|{{ formatDate .Expiration }}|{{ formatTime .Expiration }}|{{ formatDateTime .Expiration }}|{{ plural .Data.count "one" "other" }}`
+	for _, lang := range []string{"en", "fr", "es", "de", "ja"} {
+		require.Nil(t, tmpl.Add(lang, "subject", body, ""))
+	}
+	require.Nil(t, tmpl.SetDefaultLanguage("en"))
+
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	require.Nil(t, err)
+	data := templates.Params{
+		Expiration: current.Add(20 * time.Minute).In(madrid),
+		Data:       map[string]interface{}{"count": 0},
+	}
+	for lang, expected := range map[string]string{
+		"en":    "in 20 minutes|March 5, 2021|3:05 PM|March 5, 2021 at 3:05 PM|other",
+		"fr-CA": "dans 20 minutes|5 mars 2021|15:05|5 mars 2021 à 15:05|one",
+		"es":    "en 20 minutos|5 de marzo de 2021|15:05|5 de marzo de 2021, 15:05|other",
+		"de":    "in 20 Minuten|5. März 2021|15:05|5. März 2021 um 15:05|other",
+		"ja":    "in 20 minutes|March 5, 2021|3:05 PM|March 5, 2021 at 3:05 PM|other",
+	} {
+		res, err := tmpl.GenerateForLang(lang, data)
+		require.Nil(t, err, lang)
+		require.Equal(t, expected, string(res.TXT), lang)
+	}
+
+	data.Data["count"] = "many"
+	_, err = tmpl.Generate(data)
+	require.NotNil(t, err)
+}
+
+func TestPluralForms(t *testing.T) {
+	tmpl := NewTemplates()
+	body := `{{ plural .Data.count "one" "plik" "few" "pliki" "many" "plików" "other" "pliku" }}`
+	require.Nil(t, tmpl.Add("pl", "subject", body, ""))
+	require.Nil(t, tmpl.Add("en", "subject", body, ""))
+
+	for count, expected := range map[int]string{1: "plik", 3: "pliki", 22: "pliki", 5: "plików", 12: "plików"} {
+		res, err := tmpl.GenerateForLang("pl", templates.Params{Data: map[string]interface{}{"count": count}})
+		require.Nil(t, err)
+		require.Equal(t, expected, string(res.TXT), count)
+	}
+
+	// the languages without the few and many categories use the other form
+	res, err := tmpl.GenerateForLang("en", templates.Params{Data: map[string]interface{}{"count": 3}})
+	require.Nil(t, err)
+	require.Equal(t, "pliku", string(res.TXT))
+
+	for _, forms := range []string{`"one" "plik" "few"`, `"one" "plik" "few" "pliki"`, `"one" "plik" "lots" "pliki" "other" "pliku"`} {
+		require.Nil(t, tmpl.Add("en", "subject", `{{ plural 3 `+forms+` }}`, ""))
+		_, err := tmpl.Generate(templates.Params{})
+		require.NotNil(t, err, forms)
+	}
+}
+
+func TestLayout(t *testing.T) {
+	tmpl := NewTemplates()
+	require.Nil(t, tmpl.Add("en", "hello", "Hello {{ .Email }}", "<p>Hello {{ .Email }}</p>"))
+	require.Nil(t, tmpl.Add("fr", "bonjour", "Bonjour {{ .Email }}",
+		`<p>Bonjour {{ .Email }}</p>{{ define "footer" }}<small>Pied de page</small>{{ end }}`))
+	require.Nil(t, tmpl.SetDefaultLanguage("en"))
+
+	require.Nil(t, tmpl.AddPartial("footer", "-- Example", "<small>{{ .Data.product }}</small>"))
+	require.Nil(t, tmpl.SetLayout(
+		`{{ template "content" . }}`+"\n"+`{{ template "footer" . }}`,
+		`<html>{{ block "header" . }}<h1>Example</h1>{{ end }}{{ template "content" . }}{{ template "footer" . }}</html>`,
+	))
+
+	data := params
+	data.Data = map[string]interface{}{"product": "Example"}
+	res, err := tmpl.Generate(data)
+	require.Nil(t, err)
+	require.Equal(t, "Hello "+email+"\n-- Example", string(res.TXT))
+	require.Equal(t, "<html><h1>Example</h1><p>Hello "+email+"</p><small>Example</small></html>", string(res.HTML))
+
+	// the locale overrides the partial
+	res, err = tmpl.GenerateForLang("fr", data)
+	require.Nil(t, err)
+	require.Equal(t, "<html><h1>Example</h1><p>Bonjour "+email+"</p><small>Pied de page</small></html>", string(res.HTML))
+
+	// the locales added after the layout use it
+	require.Nil(t, tmpl.Add("de", "hallo", "Hallo {{ .Email }}", `{{ define "header" }}<h2>Example</h2>{{ end }}<p>Hallo</p>`))
+	res, err = tmpl.GenerateForLang("de", data)
+	require.Nil(t, err)
+	require.Equal(t, "Hallo "+email+"\n-- Example", string(res.TXT))
+	require.Equal(t, "<html><h2>Example</h2><p>Hallo</p><small>Example</small></html>", string(res.HTML))
+
+	// an invalid layout keeps the current one
+	require.NotNil(t, tmpl.SetLayout(`{{ template "content" . `, ""))
+	res, err = tmpl.Generate(data)
+	require.Nil(t, err)
+	require.Equal(t, "Hello "+email+"\n-- Example", string(res.TXT))
+}
+
+func TestLayoutFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layout.html":          {Data: []byte(`<html>{{ template "content" . }}{{ template "footer" . }}</html>`)},
+		"partials/footer.html": {Data: []byte(`<small>{{ formatDate .Expiration }}</small>`)},
+		"partials/README.md":   {Data: []byte(`ignored`)},
+		"en/subject.txt":       {Data: []byte("hello")},
+		"en/body.html":         {Data: []byte(`<p>{{ .Email }}</p>`)},
+		"fr/subject.txt":       {Data: []byte("bonjour")},
+		"fr/body.html":         {Data: []byte(`<p>{{ .Email }}</p>`)},
+	}
+	tmpl, err := NewTemplatesFS(fsys, ".")
+	require.Nil(t, err)
+
+	data := params
+	data.Expiration = time.Date(2021, time.March, 5, 13, 45, 0, 0, time.UTC)
+	res, err := tmpl.GenerateForLang("fr", data)
+	require.Nil(t, err)
+	require.Equal(t, "<html><p>"+email+"</p><small>5 mars 2021</small></html>", string(res.HTML))
+
+	fsys["layout.html"] = &fstest.MapFile{Data: []byte(`<html>{{ template "content" . </html>`)}
+	fsys["en/body.html"] = &fstest.MapFile{Data: []byte(`<p>{{ .Email </p>`)}
+	_, err = NewTemplatesFS(fsys, ".")
+	filesErr := FilesError{}
+	require.True(t, errors.As(err, &filesErr))
+	require.Len(t, filesErr, 1, "the layout error is reported once, the locales are not parsed without layout")
+	require.Equal(t, "layout.html", filesErr[0].File)
+}
+
+func TestInlineCSS(t *testing.T) {
+	tmpl := NewTemplates()
+	require.Nil(t, tmpl.SetInlineCSS(true))
+	require.Nil(t, tmpl.Add("en", "hello", "", `<style>a { color: {{ .Data.color }} } p { margin: 0 }</style><p><a href="{{ .URL }}">{{ .Email }}</a></p>`))
+
+	data := params
+	data.Data = map[string]interface{}{"color": "red"}
+	res, err := tmpl.Generate(data)
+	require.Nil(t, err)
+	require.Equal(t, `<p style="margin: 0;"><a href="`+url+`" style="color: red;">`+email+`</a></p>`, string(res.HTML))
+	// the template is inlined when it is added
+	require.False(t, tmpl.locales[language.English].inlineCSS)
+
+	// the rules of the layout apply to the elements of the locales
+	require.Nil(t, tmpl.SetLayout("", `<html><head><style>p { margin: 0 } @media (max-width: 600px) { p { margin: 4px } }</style></head><body>{{ template "content" . }}</body></html>`))
+	require.Nil(t, tmpl.Add("fr", "bonjour", "", `<p>Bonjour</p>`))
+	res, err = tmpl.GenerateForLang("fr", data)
+	require.Nil(t, err)
+	require.Equal(t, `<html><head><style>
+@media (max-width: 600px) { p { margin: 4px } }
+</style></head><body><p style="margin: 0;">Bonjour</p></body></html>`, string(res.HTML))
+	require.True(t, tmpl.locales[language.French].inlineCSS)
+
+	require.Nil(t, tmpl.SetInlineCSS(false))
+	res, err = tmpl.GenerateForLang("fr", data)
+	require.Nil(t, err)
+	require.Contains(t, string(res.HTML), `<style>p { margin: 0 }`)
+}
@@ -0,0 +1,252 @@
+package gotemplates
+
+import (
+	htmlTemplate "html/template"
+	txtTemplate "text/template"
+
+	"github.com/fdelbos/mauth/templates/cssinline"
+	"golang.org/x/text/language"
+)
+
+type (
+	// source is the text of a template, with the name used in its errors.
+	source struct {
+		name string
+		text string
+	}
+
+	// localeSource keeps the sources of a locale, so that it can be built again when the layout changes.
+	localeSource struct {
+		subject source
+		txt     source
+		html    source
+		funcs   []FuncMap
+	}
+
+	partial struct {
+		name string
+		txt  source
+		html source
+	}
+
+	// layout is shared by all the locales.
+	layout struct {
+		txt      source
+		html     source
+		partials []partial
+		funcs    []FuncMap
+	}
+
+	// compilation holds the locales built with a layout, before they replace the current ones.
+	compilation struct {
+		layout  layout
+		added   map[language.Tag]localeSource
+		sources map[language.Tag]localeSource
+		locales map[language.Tag]locale
+		errs    FilesError
+	}
+)
+
+const (
+	// LayoutTemplate is the name of the layouts, ContentTemplate the name of the bodies of the locales when a
+	// layout is set.
+	LayoutTemplate  = "layout"
+	ContentTemplate = "content"
+)
+
+// SetLayout sets the layouts of the text and html bodies, one of them can be empty. A layout includes the body of the
+// locale with {{ template "content" . }}, and can declare named blocks that the locales can override with
+// {{ define "name" }}. All the locales are built again, nothing is changed if one of them fails.
+func (t *GoTemplates) SetLayout(txt, html string, funcs ...FuncMap) error {
+	l := t.layout
+	l.txt = source{name: LayoutTemplate, text: txt}
+	l.html = source{name: LayoutTemplate, text: html}
+	l.funcs = funcs
+	return t.setLayout(l, nil)
+}
+
+// AddPartial adds a template available to all the locales with {{ template "name" . }}, txt is used by the text
+// bodies and html by the html bodies. A locale can override it with {{ define "name" }}.
+func (t *GoTemplates) AddPartial(name, txt, html string) error {
+	l := t.layout
+	l.partials = append(append([]partial{}, l.partials...), partial{
+		name: name,
+		txt:  source{name: name, text: txt},
+		html: source{name: name, text: html},
+	})
+	return t.setLayout(l, nil)
+}
+
+// setLayout builds all the locales, with the new ones, using the layout l. The templates are only replaced when
+// everything is valid.
+func (t *GoTemplates) setLayout(l layout, added map[language.Tag]localeSource) error {
+	c := t.compile(l, added)
+	if len(c.errs) != 0 {
+		return c.errs
+	}
+	t.apply(c)
+	return nil
+}
+
+func (t *GoTemplates) compile(l layout, added map[language.Tag]localeSource) compilation {
+	c := compilation{
+		layout:  l,
+		added:   added,
+		sources: map[language.Tag]localeSource{},
+		locales: map[language.Tag]locale{},
+	}
+	for tag, src := range t.sources {
+		c.sources[tag] = src
+	}
+	for tag, src := range added {
+		c.sources[tag] = src
+	}
+
+	// the layout is checked even without locales
+	errs := t.checkLayout(l)
+	for tag, src := range c.sources {
+		loc, localeErrs := t.build(l, tag, src)
+		errs = append(errs, localeErrs...)
+		c.locales[tag] = loc
+	}
+	if len(errs) != 0 {
+		c.errs = dedupe(errs).sorted()
+	}
+	return c
+}
+
+func (t *GoTemplates) apply(c compilation) {
+	t.layout = c.layout
+	t.sources = c.sources
+	t.locales = c.locales
+	for _, tag := range sortedTags(c.added) {
+		t.addLanguage(tag)
+	}
+}
+
+func (t *GoTemplates) checkLayout(l layout) FilesError {
+	errs := FilesError{}
+	if _, err := t.buildTXT(l, language.Und, source{}, nil); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := t.buildHTML(l, language.Und, source{}, nil); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func (t *GoTemplates) build(l layout, tag language.Tag, src localeSource) (locale, FilesError) {
+	errs := FilesError{}
+	res := locale{}
+
+	funcs := append([]FuncMap{t.localizedFuncs(tag)}, src.funcs...)
+	subject := txtTemplate.New(src.subject.name).Option(t.missingKey())
+	for _, f := range funcs {
+		subject.Funcs(txtTemplate.FuncMap(f))
+	}
+	var err error
+	if res.subject, err = subject.Parse(src.subject.text); err != nil {
+		errs = append(errs, &FileError{File: src.subject.name, Err: err})
+	}
+
+	if src.txt.text != "" {
+		var fileErr *FileError
+		if res.txt, fileErr = t.buildTXT(l, tag, src.txt, src.funcs); fileErr != nil {
+			errs = append(errs, fileErr)
+		}
+	}
+	if src.html.text != "" {
+		body := src.html
+		if t.inlineCSS && !l.hasHTML() {
+			html, err := cssinline.Inline([]byte(body.text))
+			if err != nil {
+				return res, append(errs, &FileError{File: body.name, Err: err})
+			}
+			body.text = string(html)
+		}
+		res.inlineCSS = t.inlineCSS && l.hasHTML()
+
+		var fileErr *FileError
+		if res.html, fileErr = t.buildHTML(l, tag, body, src.funcs); fileErr != nil {
+			errs = append(errs, fileErr)
+		}
+	}
+	return res, errs
+}
+
+// hasHTML tells if the html bodies are built with other templates.
+func (l layout) hasHTML() bool {
+	if l.html.text != "" {
+		return true
+	}
+	for _, p := range l.partials {
+		if p.html.text != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// buildTXT parses the partials, the layout and the body, and returns the template to execute.
+func (t *GoTemplates) buildTXT(l layout, tag language.Tag, body source, funcs []FuncMap) (*txtTemplate.Template, *FileError) {
+	root := txtTemplate.New(body.name).Option(t.missingKey())
+	for _, f := range append(append([]FuncMap{t.localizedFuncs(tag)}, l.funcs...), funcs...) {
+		root.Funcs(txtTemplate.FuncMap(f))
+	}
+
+	for _, p := range l.partials {
+		if p.txt.text == "" {
+			continue
+		}
+		if _, err := root.New(p.name).Parse(p.txt.text); err != nil {
+			return nil, &FileError{File: p.txt.name, Err: err}
+		}
+	}
+
+	if l.txt.text == "" {
+		if _, err := root.Parse(body.text); err != nil {
+			return nil, &FileError{File: body.name, Err: err}
+		}
+		return root, nil
+	}
+
+	if _, err := root.New(LayoutTemplate).Parse(l.txt.text); err != nil {
+		return nil, &FileError{File: l.txt.name, Err: err}
+	}
+	if _, err := root.New(ContentTemplate).Parse(body.text); err != nil {
+		return nil, &FileError{File: body.name, Err: err}
+	}
+	return root.Lookup(LayoutTemplate), nil
+}
+
+// buildHTML is buildTXT for the html templates.
+func (t *GoTemplates) buildHTML(l layout, tag language.Tag, body source, funcs []FuncMap) (*htmlTemplate.Template, *FileError) {
+	root := htmlTemplate.New(body.name).Option(t.missingKey())
+	for _, f := range append(append([]FuncMap{t.localizedFuncs(tag)}, l.funcs...), funcs...) {
+		root.Funcs(htmlTemplate.FuncMap(f))
+	}
+
+	for _, p := range l.partials {
+		if p.html.text == "" {
+			continue
+		}
+		if _, err := root.New(p.name).Parse(p.html.text); err != nil {
+			return nil, &FileError{File: p.html.name, Err: err}
+		}
+	}
+
+	if l.html.text == "" {
+		if _, err := root.Parse(body.text); err != nil {
+			return nil, &FileError{File: body.name, Err: err}
+		}
+		return root, nil
+	}
+
+	if _, err := root.New(LayoutTemplate).Parse(l.html.text); err != nil {
+		return nil, &FileError{File: l.html.name, Err: err}
+	}
+	if _, err := root.New(ContentTemplate).Parse(body.text); err != nil {
+		return nil, &FileError{File: body.name, Err: err}
+	}
+	return root.Lookup(LayoutTemplate), nil
+}
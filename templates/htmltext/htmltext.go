@@ -0,0 +1,305 @@
+// Package htmltext converts html messages to text, it makes the text part of the messages that only have an html
+// template. The links are kept inline after their text, the list items have bullets and the lines are wrapped at
+// LineWidth columns:
+//
+//	Sign in to Acme
+//
+//	Click here (https://example.com/login?mauth_token=...) to sign in.
+//
+//	- the link can be used once
+//	- it expires in 20 minutes
+package htmltext
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+type (
+	converter struct {
+		out strings.Builder
+		// inline is the text of the current block, "\n" are the line breaks
+		inline strings.Builder
+		// gap is the number of line breaks before the next block
+		gap int
+		// bullet is the prefix of the first line of the next block, the marker of a list item
+		bullet string
+		// indents are the prefixes of the lines, one per list or quote level
+		indents []string
+		lists   []*list
+		pre     int
+	}
+
+	list struct {
+		ordered bool
+		n       int
+	}
+)
+
+const (
+	// LineWidth is the maximum length of the lines, the words longer than the line (ie: URLs) are not broken.
+	LineWidth = 78
+)
+
+// Convert returns the text version of an html document or fragment. The content of the head, script and style
+// elements is dropped.
+func Convert(src []byte) ([]byte, error) {
+	doc, err := html.Parse(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+
+	c := &converter{}
+	c.walk(doc)
+	c.flush()
+	if c.out.Len() == 0 {
+		return []byte{}, nil
+	}
+	return []byte(c.out.String() + "\n"), nil
+}
+
+func (c *converter) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		c.text(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		c.children(n)
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Head, atom.Script, atom.Style, atom.Title, atom.Template:
+
+	case atom.Br:
+		c.inline.WriteString("\n")
+
+	case atom.Hr:
+		c.block(2)
+		c.write(strings.Repeat("-", LineWidth))
+		c.block(2)
+
+	case atom.A:
+		start := c.inline.Len()
+		c.children(n)
+		if start > c.inline.Len() {
+			// a block of the link was flushed
+			start = 0
+		}
+		c.link(attr(n, "href"), c.inline.String()[start:])
+
+	case atom.Img:
+		if alt := strings.TrimSpace(attr(n, "alt")); alt != "" {
+			c.text(alt)
+		}
+
+	case atom.Td, atom.Th:
+		c.text(" ")
+		c.children(n)
+		c.text(" ")
+
+	case atom.Ul, atom.Ol:
+		c.block(listGap(len(c.lists)))
+		c.lists = append(c.lists, &list{ordered: n.DataAtom == atom.Ol, n: startAttr(n)})
+		c.children(n)
+		c.lists = c.lists[:len(c.lists)-1]
+		c.block(listGap(len(c.lists)))
+
+	case atom.Li:
+		c.block(1)
+		marker := "- "
+		if len(c.lists) != 0 && c.lists[len(c.lists)-1].ordered {
+			l := c.lists[len(c.lists)-1]
+			marker = strconv.Itoa(l.n) + ". "
+			l.n++
+		}
+		c.bullet = marker
+		c.indents = append(c.indents, strings.Repeat(" ", len(marker)))
+		c.children(n)
+		c.block(1)
+		c.indents = c.indents[:len(c.indents)-1]
+		c.bullet = ""
+
+	case atom.Blockquote:
+		c.block(2)
+		c.indents = append(c.indents, "> ")
+		c.children(n)
+		c.block(2)
+		c.indents = c.indents[:len(c.indents)-1]
+
+	case atom.Pre:
+		c.block(2)
+		c.pre++
+		c.children(n)
+		c.flush()
+		c.pre--
+		c.block(2)
+
+	case atom.P, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Table, atom.Dl:
+		c.block(2)
+		c.children(n)
+		c.block(2)
+
+	case atom.Div, atom.Tr, atom.Dt, atom.Dd, atom.Section, atom.Article, atom.Header, atom.Footer, atom.Nav,
+		atom.Main, atom.Aside, atom.Address, atom.Figure, atom.Figcaption, atom.Form, atom.Fieldset, atom.Caption:
+		c.block(1)
+		c.children(n)
+		c.block(1)
+
+	default:
+		c.children(n)
+	}
+}
+
+func (c *converter) children(n *html.Node) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		c.walk(child)
+	}
+}
+
+// text adds inline text, the white spaces are collapsed outside of the pre elements.
+func (c *converter) text(s string) {
+	if c.pre > 0 {
+		c.inline.WriteString(s)
+		return
+	}
+	for _, r := range s {
+		if isSpace(r) {
+			if current := c.inline.String(); current == "" || strings.HasSuffix(current, " ") || strings.HasSuffix(current, "\n") {
+				continue
+			}
+			r = ' '
+		}
+		c.inline.WriteRune(r)
+	}
+}
+
+// link adds the URL after the text of the link, unless the text is the URL itself.
+func (c *converter) link(href, text string) {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		return
+	}
+	text = strings.TrimSpace(text)
+	if text == href || "mailto:"+text == href {
+		return
+	}
+	if text == "" {
+		c.text(href)
+		return
+	}
+	c.text(" (" + href + ")")
+}
+
+// block ends the current block, the next block starts after at least gap line breaks (2 for a blank line).
+func (c *converter) block(gap int) {
+	c.flush()
+	if gap > c.gap {
+		c.gap = gap
+	}
+}
+
+// flush writes the current block.
+func (c *converter) flush() {
+	text := c.inline.String()
+	c.inline.Reset()
+	if c.pre == 0 {
+		text = strings.TrimSpace(text)
+	}
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+
+	if c.pre > 0 {
+		for _, line := range strings.Split(strings.Trim(text, "\n"), "\n") {
+			c.write(strings.TrimRight(line, " \t\r"))
+		}
+		return
+	}
+	for _, part := range strings.Split(text, "\n") {
+		for _, line := range wrap(strings.Fields(part), LineWidth-len(c.prefix())) {
+			c.write(line)
+		}
+	}
+}
+
+// write writes a line with the prefix of the current list or quote level.
+func (c *converter) write(line string) {
+	if c.out.Len() != 0 {
+		gap := c.gap
+		if gap == 0 {
+			gap = 1
+		}
+		c.out.WriteString(strings.Repeat("\n", gap))
+	}
+	c.gap = 0
+	c.out.WriteString(strings.TrimRight(c.prefix()+line, " "))
+	c.bullet = ""
+}
+
+func (c *converter) prefix() string {
+	prefix := strings.Join(c.indents, "")
+	if c.bullet != "" && len(c.indents) != 0 {
+		// the marker replaces the indent of its list item
+		prefix = strings.Join(c.indents[:len(c.indents)-1], "") + c.bullet
+	}
+	return prefix
+}
+
+// wrap joins the words in lines of at most width characters.
+func wrap(words []string, width int) []string {
+	lines := []string{}
+	line := ""
+	for _, word := range words {
+		switch {
+		case line == "":
+			line = word
+		case len([]rune(line))+1+len([]rune(word)) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// listGap separates the top level lists from the paragraphs, the nested lists start on the next line.
+func listGap(depth int) int {
+	if depth == 0 {
+		return 2
+	}
+	return 1
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func startAttr(n *html.Node) int {
+	if start, err := strconv.Atoi(attr(n, "start")); err == nil {
+		return start
+	}
+	return 1
+}
+
+func isSpace(r rune) bool {
+	switch r {
+	case ' ', '\t', '\n', '\r', '\f':
+		return true
+	}
+	return false
+}
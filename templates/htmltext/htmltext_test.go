@@ -0,0 +1,75 @@
+package htmltext
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func convert(t *testing.T, src string) string {
+	res, err := Convert([]byte(src))
+	require.Nil(t, err)
+	return string(res)
+}
+
+func TestConvert(t *testing.T) {
+	src := `<html><head><title>Sign in</title><style>p { color: red; }</style></head><body>
+<h1>Sign in to   Acme</h1>
+<p>Hello <b>me@example.com</b>, <a href="https://example.com/login?mauth_token=abcdefghijklmnopqrstuvwxyz0123456789">click here</a> to sign in to your account.</p>
+<ul><li>the link can be used once</li><li>it expires in 20 minutes<ul><li>nested</li></ul></li></ul>
+<p>Thanks<br>The team</p>
+<script>alert("hidden")</script>
+</body></html>`
+
+	require.Equal(t, `Sign in to Acme
+
+Hello me@example.com, click here
+(https://example.com/login?mauth_token=abcdefghijklmnopqrstuvwxyz0123456789)
+to sign in to your account.
+
+- the link can be used once
+- it expires in 20 minutes
+  - nested
+
+Thanks
+The team
+`, convert(t, src))
+}
+
+func TestWrap(t *testing.T) {
+	res := convert(t, "<p>"+strings.Repeat("word ", 40)+"</p><ol start=\"9\"><li>"+strings.Repeat("item ", 20)+"</li></ol>")
+	for _, line := range strings.Split(res, "\n") {
+		require.LessOrEqual(t, len(line), LineWidth)
+	}
+	require.Contains(t, res, "\n9. item")
+	require.Contains(t, res, "\n   item")
+
+	long := "https://example.com/" + strings.Repeat("a", 100)
+	require.Equal(t, long+"\n", convert(t, `<a href="`+long+`">`+long+`</a>`))
+}
+
+func TestLinks(t *testing.T) {
+	require.Equal(t, "https://example.com\n", convert(t, `<a href="https://example.com"><img src="logo.png"></a>`))
+	require.Equal(t, "Acme (https://example.com)\n", convert(t, `<a href="https://example.com"><img src="logo.png" alt="Acme"></a>`))
+	require.Equal(t, "me@example.com top\n", convert(t, `<a href="mailto:me@example.com">me@example.com</a> <a href="#top">top</a>`))
+}
+
+func TestBlocks(t *testing.T) {
+	require.Equal(t, `a b
+c
+
+> quoted
+
+  code
+    block
+
+end
+`, convert(t, `<table><tr><td>a</td><td>b</td></tr><tr><td>c</td></tr></table>
+<blockquote>quoted</blockquote><pre>
+  code
+    block
+</pre><div>end</div>`))
+
+	require.Equal(t, "", convert(t, "<style>p {}</style>"))
+}
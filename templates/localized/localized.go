@@ -0,0 +1,261 @@
+// Package localized implements the functions shared by the templates packages to format the dates, the durations and
+// the plural forms in the language of the recipient.
+package localized
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/feature/plural"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/message/catalog"
+)
+
+type (
+	dateFormat struct {
+		months [12]string
+		// date uses the placeholders {d}, {month} and {yyyy}
+		date string
+		// time is a time.Format layout
+		time string
+		// dateTime uses the placeholders {date} and {time}
+		dateTime string
+	}
+)
+
+var (
+	// dateFormats are written by hand, golang.org/x/text doesn't format the dates with the CLDR data
+	dateFormats = map[language.Tag]dateFormat{
+		language.English: {
+			months:   [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+			date:     "{month} {d}, {yyyy}",
+			time:     "3:04 PM",
+			dateTime: "{date} at {time}",
+		},
+		language.French: {
+			months:   [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+			date:     "{d} {month} {yyyy}",
+			time:     "15:04",
+			dateTime: "{date} à {time}",
+		},
+		language.Spanish: {
+			months:   [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+			date:     "{d} de {month} de {yyyy}",
+			time:     "15:04",
+			dateTime: "{date}, {time}",
+		},
+		language.German: {
+			months:   [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+			date:     "{d}. {month} {yyyy}",
+			time:     "15:04",
+			dateTime: "{date} um {time}",
+		},
+	}
+
+	funcsLanguages = []language.Tag{language.English, language.French, language.Spanish, language.German}
+	funcsMatcher   = language.NewMatcher(funcsLanguages)
+	funcsCatalog   = newFuncsCatalog()
+
+	pluralForms = map[string]plural.Form{
+		"zero":  plural.Zero,
+		"one":   plural.One,
+		"two":   plural.Two,
+		"few":   plural.Few,
+		"many":  plural.Many,
+		"other": plural.Other,
+	}
+)
+
+// Funcs returns the functions of the templates of a language, now gives the current time to relative:
+//
+//	relative .Expiration         in 20 minutes, dans 20 minutes, en 20 minutos, in 20 Minuten
+//	formatDate .Expiration       January 2, 2006
+//	formatTime .Expiration       3:04 PM
+//	formatDateTime .Expiration   January 2, 2006 at 3:04 PM
+//	plural .Data.count "item" "items"
+//	plural .Data.count "one" "plik" "few" "pliki" "many" "plików" "other" "pliku"
+//
+// The dates are formatted in the time zone of the recipient when it is given with the send call. The phrases and the
+// month names are only written for English, French, Spanish and German, the other languages use the English ones.
+// plural follows the CLDR rules of every language: with two forms they are the one and other forms, otherwise the
+// forms are given by category (zero, one, two, few, many and other, which is required).
+func Funcs(tag language.Tag, now func() time.Time) map[string]interface{} {
+	_, idx, confidence := funcsMatcher.Match(tag)
+	if confidence == language.No {
+		idx = 0
+	}
+	matched := funcsLanguages[idx]
+	format := dateFormats[matched]
+
+	return map[string]interface{}{
+		"relative": func(date time.Time) string {
+			return relative(matched, date.Sub(now()))
+		},
+		"formatDate": func(date time.Time) string {
+			return format.formatDate(date)
+		},
+		"formatTime": func(date time.Time) string {
+			return date.Format(format.time)
+		},
+		"formatDateTime": func(date time.Time) string {
+			return strings.NewReplacer("{date}", format.formatDate(date), "{time}", date.Format(format.time)).
+				Replace(format.dateTime)
+		},
+		"plural": func(n interface{}, forms ...string) (string, error) {
+			return pluralForm(tag, n, forms)
+		},
+	}
+}
+
+// pluralForm returns the form of the plural category of n in the language.
+func pluralForm(tag language.Tag, n interface{}, forms []string) (string, error) {
+	i, err := toInt(n)
+	if err != nil {
+		return "", err
+	}
+	form := plural.Cardinal.MatchPlural(tag, i, 0, 0, 0, 0)
+
+	if len(forms) == 2 {
+		if form == plural.One {
+			return forms[0], nil
+		}
+		return forms[1], nil
+	}
+
+	if len(forms)%2 != 0 {
+		return "", fmt.Errorf("plural: the forms must be given by category")
+	}
+	other, ok := "", false
+	for i := 0; i < len(forms); i += 2 {
+		category, found := pluralForms[forms[i]]
+		if !found {
+			return "", fmt.Errorf("plural: unknown category %q", forms[i])
+		}
+		if category == form {
+			return forms[i+1], nil
+		} else if category == plural.Other {
+			other, ok = forms[i+1], true
+		}
+	}
+	if !ok {
+		return "", fmt.Errorf("plural: the other form is required")
+	}
+	return other, nil
+}
+
+func (f dateFormat) formatDate(t time.Time) string {
+	return strings.NewReplacer(
+		"{d}", strconv.Itoa(t.Day()),
+		"{month}", f.months[t.Month()-1],
+		"{yyyy}", strconv.Itoa(t.Year()),
+	).Replace(f.date)
+}
+
+// relative returns a phrase like "in 20 minutes" or "20 minutes ago".
+func relative(tag language.Tag, d time.Duration) string {
+	suffix := "future"
+	if d < 0 {
+		d, suffix = -d, "past"
+	}
+
+	p := message.NewPrinter(tag, message.Catalog(funcsCatalog))
+	minutes := int(d.Round(time.Minute) / time.Minute)
+	switch {
+	case d < time.Minute:
+		return p.Sprintf("less than a minute " + suffix)
+	case minutes < 60:
+		return p.Sprintf("minutes "+suffix, minutes)
+	case minutes < 48*60:
+		return p.Sprintf("hours "+suffix, int(d.Round(time.Hour)/time.Hour))
+	}
+	return p.Sprintf("days "+suffix, int(d.Round(24*time.Hour)/(24*time.Hour)))
+}
+
+func newFuncsCatalog() catalog.Catalog {
+	b := catalog.NewBuilder(catalog.Fallback(language.English))
+
+	phrases := map[language.Tag]map[string][2]string{
+		language.English: {
+			"minutes future": {"in %d minute", "in %d minutes"},
+			"minutes past":   {"%d minute ago", "%d minutes ago"},
+			"hours future":   {"in %d hour", "in %d hours"},
+			"hours past":     {"%d hour ago", "%d hours ago"},
+			"days future":    {"in %d day", "in %d days"},
+			"days past":      {"%d day ago", "%d days ago"},
+		},
+		language.French: {
+			"minutes future": {"dans %d minute", "dans %d minutes"},
+			"minutes past":   {"il y a %d minute", "il y a %d minutes"},
+			"hours future":   {"dans %d heure", "dans %d heures"},
+			"hours past":     {"il y a %d heure", "il y a %d heures"},
+			"days future":    {"dans %d jour", "dans %d jours"},
+			"days past":      {"il y a %d jour", "il y a %d jours"},
+		},
+		language.Spanish: {
+			"minutes future": {"en %d minuto", "en %d minutos"},
+			"minutes past":   {"hace %d minuto", "hace %d minutos"},
+			"hours future":   {"en %d hora", "en %d horas"},
+			"hours past":     {"hace %d hora", "hace %d horas"},
+			"days future":    {"en %d día", "en %d días"},
+			"days past":      {"hace %d día", "hace %d días"},
+		},
+		language.German: {
+			"minutes future": {"in %d Minute", "in %d Minuten"},
+			"minutes past":   {"vor %d Minute", "vor %d Minuten"},
+			"hours future":   {"in %d Stunde", "in %d Stunden"},
+			"hours past":     {"vor %d Stunde", "vor %d Stunden"},
+			"days future":    {"in %d Tag", "in %d Tagen"},
+			"days past":      {"vor %d Tag", "vor %d Tagen"},
+		},
+	}
+	for tag, messages := range phrases {
+		for key, forms := range messages {
+			b.Set(tag, key, plural.Selectf(1, "%d", plural.One, forms[0], plural.Other, forms[1]))
+		}
+	}
+
+	for tag, messages := range map[language.Tag][2]string{
+		language.English: {"in less than a minute", "less than a minute ago"},
+		language.French:  {"dans moins d'une minute", "il y a moins d'une minute"},
+		language.Spanish: {"en menos de un minuto", "hace menos de un minuto"},
+		language.German:  {"in weniger als einer Minute", "vor weniger als einer Minute"},
+	} {
+		b.SetString(tag, "less than a minute future", messages[0])
+		b.SetString(tag, "less than a minute past", messages[1])
+	}
+	return b
+}
+
+func toInt(n interface{}) (int, error) {
+	switch v := n.(type) {
+	case int:
+		return v, nil
+	case int8:
+		return int(v), nil
+	case int16:
+		return int(v), nil
+	case int32:
+		return int(v), nil
+	case int64:
+		return int(v), nil
+	case uint:
+		return int(v), nil
+	case uint8:
+		return int(v), nil
+	case uint16:
+		return int(v), nil
+	case uint32:
+		return int(v), nil
+	case uint64:
+		return int(v), nil
+	case float64:
+		// numbers decoded from JSON
+		if v == float64(int(v)) {
+			return int(v), nil
+		}
+	}
+	return 0, fmt.Errorf("plural: %v is not an integer", n)
+}
@@ -0,0 +1,33 @@
+package localized
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+func TestRelative(t *testing.T) {
+	for d, expected := range map[time.Duration]string{
+		20 * time.Second:                "in less than a minute",
+		time.Minute:                     "in 1 minute",
+		59*time.Minute + 40*time.Second: "in 1 hour",
+		-3 * time.Hour:                  "3 hours ago",
+		50 * time.Hour:                  "in 2 days",
+	} {
+		require.Equal(t, expected, relative(language.English, d), d.String())
+	}
+	require.Equal(t, "dans 1 heure", relative(language.French, time.Hour))
+	require.Equal(t, "vor 2 Tagen", relative(language.German, -48*time.Hour))
+}
+
+func TestClock(t *testing.T) {
+	current := time.Date(2021, time.March, 5, 13, 45, 0, 0, time.UTC)
+	funcs := Funcs(language.English, func() time.Time { return current })
+
+	rel := funcs["relative"].(func(time.Time) string)
+	require.Equal(t, "in 2 hours", rel(current.Add(2*time.Hour)))
+	current = current.Add(3 * time.Hour)
+	require.Equal(t, "1 hour ago", rel(current.Add(-time.Hour)))
+}
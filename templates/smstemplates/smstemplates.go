@@ -0,0 +1,165 @@
+// Package smstemplates implements short plain text templates for text messages. A rendered message must fit in a
+// single SMS segment: 160 characters of the GSM 03.38 alphabet, or 70 characters when other characters are used.
+package smstemplates
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	txtTemplate "text/template"
+	"unicode/utf16"
+
+	"github.com/fdelbos/mauth/templates"
+	"golang.org/x/text/language"
+)
+
+type (
+	SMSTemplates struct {
+		locales map[language.Tag]*txtTemplate.Template
+		tags    []language.Tag
+		matcher language.Matcher
+	}
+)
+
+const (
+	gsmSegmentLength  = 160
+	ucs2SegmentLength = 70
+
+	gsmBasic    = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+	gsmExtended = "^{}\\[~]|€\f"
+)
+
+var (
+	ErrUnsupportedLanguage   = errors.New("language is not supported")
+	ErrNoTemplateForLanguage = errors.New("no template set for this language")
+	ErrTemplateEmpty         = errors.New("template is empty")
+	ErrTooLong               = errors.New("message doesn't fit in a single sms segment")
+
+	gsmBasicSet    = runeSet(gsmBasic)
+	gsmExtendedSet = runeSet(gsmExtended)
+)
+
+func runeSet(chars string) map[rune]interface{} {
+	res := map[rune]interface{}{}
+	for _, r := range chars {
+		res[r] = nil
+	}
+	return res
+}
+
+func NewTemplates() *SMSTemplates {
+	return &SMSTemplates{
+		tags:    []language.Tag{},
+		locales: map[language.Tag]*txtTemplate.Template{},
+	}
+}
+
+func (t *SMSTemplates) SetDefaultLanguage(lang string) error {
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return ErrUnsupportedLanguage
+	}
+
+	if _, ok := t.locales[tag]; !ok {
+		return ErrNoTemplateForLanguage
+	}
+
+	newTags := []language.Tag{tag}
+	for _, t := range t.tags {
+		if t != tag {
+			newTags = append(newTags, t)
+		}
+	}
+	t.tags = newTags
+	t.matcher = language.NewMatcher(t.tags)
+	return nil
+}
+
+// Add registers the template of a language, the template receives the same templates.Params as the email
+// templates: .Email (the phone number), .Original, .URL and .Expiration
+func (t *SMSTemplates) Add(lang, txt string) error {
+	if txt == "" {
+		return ErrTemplateEmpty
+	}
+
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return ErrUnsupportedLanguage
+	}
+
+	tmpl, err := txtTemplate.New("").Parse(txt)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := t.locales[tag]; !ok {
+		t.tags = append(t.tags, tag)
+		t.matcher = language.NewMatcher(t.tags)
+	}
+	t.locales[tag] = tmpl
+	return nil
+}
+
+func (t SMSTemplates) generate(tag language.Tag, params templates.Params) (*templates.TemplateResult, error) {
+	tmpl, ok := t.locales[tag]
+	if !ok {
+		return nil, ErrNoTemplateForLanguage
+	}
+
+	dest := bytes.Buffer{}
+	if err := tmpl.Execute(&dest, params); err != nil {
+		return nil, err
+	}
+
+	if !FitsOneSegment(dest.String()) {
+		return nil, ErrTooLong
+	}
+	return &templates.TemplateResult{TXT: dest.Bytes()}, nil
+}
+
+func (t SMSTemplates) Generate(params templates.Params) (*templates.TemplateResult, error) {
+	if len(t.tags) == 0 {
+		return nil, ErrNoTemplateForLanguage
+	}
+	return t.generate(t.tags[0], params)
+}
+
+func (t SMSTemplates) GenerateForLang(lang string, params templates.Params) (*templates.TemplateResult, error) {
+	if len(t.tags) == 0 {
+		return nil, ErrNoTemplateForLanguage
+	}
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return t.generate(t.tags[0], params)
+	}
+	_, idx, _ := t.matcher.Match(tag)
+	return t.generate(t.tags[idx], params)
+}
+
+func (t SMSTemplates) GenerateForRequest(r *http.Request, params templates.Params) (*templates.TemplateResult, error) {
+	if len(t.tags) == 0 {
+		return nil, ErrNoTemplateForLanguage
+	}
+	tags, _, err := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	if err != nil {
+		return t.generate(t.tags[0], params)
+	}
+	_, idx, _ := t.matcher.Match(tags...)
+	return t.generate(t.tags[idx], params)
+}
+
+// FitsOneSegment reports if the message can be sent as a single SMS segment.
+func FitsOneSegment(msg string) bool {
+	septets := 0
+	for _, r := range msg {
+		if _, ok := gsmBasicSet[r]; ok {
+			septets++
+		} else if _, ok := gsmExtendedSet[r]; ok {
+			septets += 2
+		} else {
+			// not encodable in GSM 03.38, the message is sent in UCS-2
+			return len(utf16.Encode([]rune(msg))) <= ucs2SegmentLength
+		}
+	}
+	return septets <= gsmSegmentLength
+}
@@ -0,0 +1,67 @@
+package smstemplates
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fdelbos/mauth/templates"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	phone = "+14155552671"
+	url   = "https://example.com/login?mauth_token=abc"
+)
+
+func createTemplates(t *testing.T) *SMSTemplates {
+	tmpl := NewTemplates()
+	require.Nil(t, tmpl.Add("en", `Your login link: {{ .URL }}`))
+	require.Nil(t, tmpl.Add("fr", `Votre lien de connexion : {{ .URL }}`))
+	require.Nil(t, tmpl.SetDefaultLanguage("en"))
+	return tmpl
+}
+
+func TestGenerateForLang(t *testing.T) {
+	tmpl := createTemplates(t)
+	for _, c := range []struct {
+		lang     string
+		expected string
+	}{
+		{"en", "Your login link: " + url},
+		{"fr-CA", "Votre lien de connexion : " + url},
+		{"zh", "Your login link: " + url},
+		{"invalid!", "Your login link: " + url},
+	} {
+		res, err := tmpl.GenerateForLang(c.lang, templates.Params{Email: phone, URL: url, Expiration: time.Now()})
+		require.Nil(t, err, c.lang)
+		require.Equal(t, c.expected, string(res.TXT), c.lang)
+		require.Nil(t, res.HTML)
+	}
+}
+
+func TestTooLong(t *testing.T) {
+	tmpl := NewTemplates()
+	require.Nil(t, tmpl.Add("en", strings.Repeat("a", 150)+`{{ .URL }}`))
+	_, err := tmpl.Generate(templates.Params{Email: phone, URL: url, Expiration: time.Now()})
+	require.Equal(t, ErrTooLong, err)
+}
+
+func TestFitsOneSegment(t *testing.T) {
+	for _, c := range []struct {
+		msg      string
+		expected bool
+	}{
+		{strings.Repeat("a", 160), true},
+		{strings.Repeat("a", 161), false},
+		{strings.Repeat("é", 160), true},
+		{strings.Repeat("€", 80), true},
+		{strings.Repeat("€", 81), false},
+		{strings.Repeat("ç", 70), true},
+		{strings.Repeat("ç", 71), false},
+		{strings.Repeat("😀", 35), true},
+		{strings.Repeat("😀", 36), false},
+	} {
+		require.Equal(t, c.expected, FitsOneSegment(c.msg), c.msg)
+	}
+}
@@ -0,0 +1,11 @@
+package templates
+
+import (
+	"strings"
+)
+
+// SanitizeSubject replaces the line breaks by spaces, so that the subject can't add headers to the message.
+func SanitizeSubject(subject string) string {
+	subject = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(subject)
+	return strings.TrimSpace(subject)
+}
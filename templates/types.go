@@ -11,6 +11,19 @@ var (
 )
 
 type (
+	// Params are the data given to the templates.
+	Params struct {
+		// Email is the canonical address, the token is made for it.
+		Email string
+		// Original is the address as it was entered by the user, before the normalization. The message is sent to
+		// the canonical address.
+		Original   string
+		URL        string
+		Expiration time.Time
+		// Data holds the extra values given with the send calls (ie: the name of the product).
+		Data map[string]interface{}
+	}
+
 	TemplateResult struct {
 		HTML    []byte
 		TXT     []byte
@@ -18,8 +31,8 @@ type (
 	}
 
 	Templates interface {
-		Generate(email, url string, expiration time.Time) (*TemplateResult, error)
-		GenerateForLang(lang string, email, url string, expiration time.Time) (*TemplateResult, error)
-		GenerateForRequest(r *http.Request, email, url string, expiration time.Time) (*TemplateResult, error)
+		Generate(params Params) (*TemplateResult, error)
+		GenerateForLang(lang string, params Params) (*TemplateResult, error)
+		GenerateForRequest(r *http.Request, params Params) (*TemplateResult, error)
 	}
 )
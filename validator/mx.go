@@ -0,0 +1,159 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/idna"
+)
+
+type (
+	// Resolver is the subset of *net.Resolver used by MX, so that the lookups can be replaced in the tests.
+	Resolver interface {
+		LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+		LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+	}
+
+	MXParams struct {
+		// Resolver defaults to net.DefaultResolver.
+		Resolver Resolver
+		// TTL is the duration the result of a domain is kept, defaults to 1 hour. The temporary DNS errors are
+		// never cached.
+		TTL time.Duration
+	}
+
+	// MX validates that the domain of the address can receive emails: it must have MX records, or an A/AAAA
+	// record when it has no MX (RFC 5321 section 5.1), and must not publish a null MX (RFC 7505).
+	// The lookups use the context of the call, so they are bounded by the request timeout.
+	MX struct {
+		resolver  Resolver
+		ttl       time.Duration
+		mu        sync.Mutex
+		cache     map[string]*mxResult
+		lastSweep time.Time
+		now       func() time.Time
+	}
+
+	mxResult struct {
+		reason  string
+		expires time.Time
+	}
+)
+
+const (
+	ReasonNoMailServer = "domain has no mail server"
+	ReasonNullMX       = "domain does not accept emails"
+
+	DefaultMXTTL = time.Hour
+)
+
+func NewMX(params MXParams) *MX {
+	res := &MX{
+		resolver: params.Resolver,
+		ttl:      params.TTL,
+		cache:    map[string]*mxResult{},
+		now:      time.Now,
+	}
+	if res.resolver == nil {
+		res.resolver = net.DefaultResolver
+	}
+	if res.ttl <= 0 {
+		res.ttl = DefaultMXTTL
+	}
+	return res
+}
+
+// Validate returns an *AddressError when the domain can't receive emails, or the DNS error when the lookup failed
+// for another reason (ie: timeout).
+func (m *MX) Validate(ctx context.Context, address string) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	address = strings.TrimSpace(address)
+	at := strings.LastIndexByte(address, '@')
+	if at < 0 {
+		return invalid(address, ReasonNoAt)
+	}
+	domain, err := idna.Lookup.ToASCII(strings.TrimSuffix(address[at+1:], "."))
+	if err != nil || domain == "" {
+		return invalid(address, ReasonDomainInvalid)
+	}
+
+	reason, ok := m.cached(domain)
+	if !ok {
+		reason, err = m.lookup(ctx, domain)
+		if err != nil {
+			return err
+		}
+		m.store(domain, reason)
+	}
+
+	if reason != "" {
+		return invalid(address, reason)
+	}
+	return nil
+}
+
+// lookup returns the reason why the domain can't receive emails, or an empty reason if it can.
+func (m *MX) lookup(ctx context.Context, domain string) (string, error) {
+	records, err := m.resolver.LookupMX(ctx, domain)
+	if err != nil && !isNotFound(err) {
+		return "", err
+	}
+
+	if len(records) == 1 && records[0].Host == "." {
+		return ReasonNullMX, nil
+	}
+	for _, record := range records {
+		if record.Host != "." && record.Host != "" {
+			return "", nil
+		}
+	}
+
+	// no MX, the domain itself is the mail server
+	addresses, err := m.resolver.LookupIPAddr(ctx, domain)
+	if err != nil && !isNotFound(err) {
+		return "", err
+	}
+	if len(addresses) == 0 {
+		return ReasonNoMailServer, nil
+	}
+	return "", nil
+}
+
+func (m *MX) cached(domain string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	res, ok := m.cache[domain]
+	if !ok || !m.now().Before(res.expires) {
+		return "", false
+	}
+	return res.reason, true
+}
+
+func (m *MX) store(domain, reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if now.Sub(m.lastSweep) >= m.ttl {
+		for key, res := range m.cache {
+			if !now.Before(res.expires) {
+				delete(m.cache, key)
+			}
+		}
+		m.lastSweep = now
+	}
+	m.cache[domain] = &mxResult{reason: reason, expires: now.Add(m.ttl)}
+}
+
+func isNotFound(err error) bool {
+	dnsErr := &net.DNSError{}
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
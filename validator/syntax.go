@@ -0,0 +1,177 @@
+package validator
+
+import (
+	"context"
+	"net"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+type (
+	// Syntax validates the addresses against the RFC 5322 grammar, extended to UTF-8 by RFC 6531, and the RFC 5321
+	// length limits. Internationalized domain names are checked in their ASCII (punycode) form.
+	Syntax struct {
+		// AllowDomainLiteral accepts the IP address literals as domain (ie: user@[192.0.2.1]).
+		AllowDomainLiteral bool
+	}
+)
+
+const (
+	maxAddressLength = 254
+	maxLocalLength   = 64
+	maxDomainLength  = 253
+	maxLabelLength   = 63
+)
+
+func NewSyntax() *Syntax {
+	return &Syntax{}
+}
+
+// Validate returns an *AddressError when the address is invalid. The surrounding spaces are ignored.
+func (s Syntax) Validate(ctx context.Context, address string) error {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return invalid(address, ReasonEmpty)
+	}
+	if !utf8.ValidString(address) {
+		return invalid(address, ReasonLocalInvalid)
+	}
+
+	at := strings.LastIndexByte(address, '@')
+	if at < 0 {
+		return invalid(address, ReasonNoAt)
+	}
+	local, domain := address[:at], address[at+1:]
+
+	if reason := checkLocal(local); reason != "" {
+		return invalid(address, reason)
+	}
+
+	asciiDomain, reason := s.checkDomain(domain)
+	if reason != "" {
+		return invalid(address, reason)
+	}
+
+	if len(local)+1+len(asciiDomain) > maxAddressLength {
+		return invalid(address, ReasonTooLong)
+	}
+	return nil
+}
+
+func checkLocal(local string) string {
+	switch {
+	case local == "":
+		return ReasonLocalEmpty
+	case len(local) > maxLocalLength:
+		return ReasonLocalTooLong
+	case local[0] == '"':
+		return checkQuoted(local)
+	case local[0] == '.' || local[len(local)-1] == '.' || strings.Contains(local, ".."):
+		return ReasonLocalDots
+	}
+
+	for _, r := range local {
+		if r != '.' && !isAtext(r) {
+			return ReasonLocalInvalid
+		}
+	}
+	return ""
+}
+
+// checkQuoted checks a quoted-string local part (ie: "john doe"@example.com).
+func checkQuoted(local string) string {
+	if len(local) < 2 || local[len(local)-1] != '"' {
+		return ReasonQuotedInvalid
+	}
+
+	escaped := false
+	for _, r := range local[1 : len(local)-1] {
+		switch {
+		case escaped:
+			// quoted-pair: '\' followed by a visible character or a space
+			if r < ' ' || r == 0x7f {
+				return ReasonQuotedInvalid
+			}
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"' || r < ' ' || r == 0x7f:
+			return ReasonQuotedInvalid
+		}
+	}
+	if escaped {
+		return ReasonQuotedInvalid
+	}
+	return ""
+}
+
+// checkDomain returns the ASCII form of the domain, or the reason why it is invalid.
+func (s Syntax) checkDomain(domain string) (string, string) {
+	if domain == "" {
+		return "", ReasonDomainEmpty
+	}
+
+	if strings.HasPrefix(domain, "[") && strings.HasSuffix(domain, "]") {
+		if !s.AllowDomainLiteral {
+			return "", ReasonDomainLiteral
+		}
+		literal := domain[1 : len(domain)-1]
+		if strings.HasPrefix(literal, "IPv6:") {
+			ip := net.ParseIP(strings.TrimPrefix(literal, "IPv6:"))
+			if ip == nil || ip.To4() != nil {
+				return "", ReasonLiteralInvalid
+			}
+		} else if ip := net.ParseIP(literal); ip == nil || ip.To4() == nil {
+			return "", ReasonLiteralInvalid
+		}
+		return domain, ""
+	}
+
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return "", ReasonDomainInvalid
+	}
+	if len(ascii) > maxDomainLength {
+		return "", ReasonDomainTooLong
+	}
+
+	labels := strings.Split(ascii, ".")
+	if len(labels) < 2 {
+		return "", ReasonNoTLD
+	}
+	for _, label := range labels {
+		switch {
+		case label == "":
+			return "", ReasonDomainInvalid
+		case len(label) > maxLabelLength:
+			return "", ReasonLabelTooLong
+		case label[0] == '-' || label[len(label)-1] == '-':
+			return "", ReasonDomainInvalid
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return "", ReasonDomainInvalid
+			}
+		}
+	}
+
+	tld := labels[len(labels)-1]
+	if strings.Trim(tld, "0123456789") == "" {
+		return "", ReasonNoTLD
+	}
+	return ascii, ""
+}
+
+// isAtext reports whether r is allowed in a dot-atom, non ASCII characters are allowed by RFC 6531.
+func isAtext(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	case r >= utf8.RuneSelf:
+		return r != utf8.RuneError && r > 0x9f
+	}
+	return strings.ContainsRune("!#$%&'*+-/=?^_`{|}~", r)
+}
@@ -0,0 +1,53 @@
+package validator
+
+import (
+	"context"
+	"errors"
+)
+
+type (
+	// Validator has the same method set as mauth.AddressValidator.
+	Validator interface {
+		Validate(ctx context.Context, address string) error
+	}
+
+	// AddressError is returned when an address is refused, it matches ErrInvalidAddress with errors.Is.
+	AddressError struct {
+		Address string
+		Reason  string
+	}
+)
+
+const (
+	ReasonEmpty          = "address is empty"
+	ReasonNoAt           = "missing @ separator"
+	ReasonTooLong        = "address is longer than 254 octets"
+	ReasonLocalEmpty     = "local part is empty"
+	ReasonLocalTooLong   = "local part is longer than 64 octets"
+	ReasonLocalInvalid   = "local part contains invalid characters"
+	ReasonLocalDots      = "local part has a leading, trailing or double dot"
+	ReasonQuotedInvalid  = "quoted local part is invalid"
+	ReasonDomainEmpty    = "domain is empty"
+	ReasonDomainTooLong  = "domain is longer than 253 octets"
+	ReasonDomainInvalid  = "domain is invalid"
+	ReasonLabelTooLong   = "domain label is longer than 63 octets"
+	ReasonNoTLD          = "domain has no top level domain"
+	ReasonDomainLiteral  = "domain literals are not allowed"
+	ReasonLiteralInvalid = "domain literal is not a valid IP address"
+)
+
+var (
+	ErrInvalidAddress = errors.New("invalid email address")
+)
+
+func (e *AddressError) Error() string {
+	return ErrInvalidAddress.Error() + ": " + e.Reason
+}
+
+func (e *AddressError) Is(target error) bool {
+	return target == ErrInvalidAddress
+}
+
+func invalid(address, reason string) error {
+	return &AddressError{Address: address, Reason: reason}
+}
@@ -0,0 +1,25 @@
+// Package validator checks the email addresses before a token is minted.
+package validator
+
+import (
+	"context"
+)
+
+type (
+	chain []Validator
+)
+
+// Chain returns a Validator calling the validators in order, it stops at the first error. The syntax validator
+// should come first, so that the DNS isn't queried for invalid addresses.
+func Chain(validators ...Validator) Validator {
+	return chain(validators)
+}
+
+func (c chain) Validate(ctx context.Context, address string) error {
+	for _, v := range c {
+		if err := v.Validate(ctx, address); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyntaxValid(t *testing.T) {
+	s := NewSyntax()
+	for _, address := range []string{
+		"john@example.com",
+		" john@example.com ",
+		"john.doe+tag@mail.example.co.uk",
+		"!#$%&'*+-/=?^_`{|}~@example.com",
+		`"john doe"@example.com`,
+		`"john\"doe"@example.com`,
+		`"@"@example.com`,
+		"josé@example.com",
+		"用户@例子.广告",
+		"john@bücher.de",
+		"john@xn--bcher-kva.de",
+		strings.Repeat("a", 64) + "@example.com",
+	} {
+		require.NoError(t, s.Validate(nil, address), address)
+	}
+}
+
+func TestSyntaxInvalid(t *testing.T) {
+	s := NewSyntax()
+	for address, reason := range map[string]string{
+		"":                                 ReasonEmpty,
+		"john.example.com":                 ReasonNoAt,
+		"@example.com":                     ReasonLocalEmpty,
+		strings.Repeat("a", 65) + "@x.com": ReasonLocalTooLong,
+		"john doe@example.com":             ReasonLocalInvalid,
+		"john(comment)@example.com":        ReasonLocalInvalid,
+		".john@example.com":                ReasonLocalDots,
+		"john.@example.com":                ReasonLocalDots,
+		"john..doe@example.com":            ReasonLocalDots,
+		`"john@example.com`:                ReasonQuotedInvalid,
+		`"jo"hn"@example.com`:              ReasonQuotedInvalid,
+		`"john\"@example.com`:              ReasonQuotedInvalid,
+		"john@":                            ReasonDomainEmpty,
+		"john@localhost":                   ReasonNoTLD,
+		"john@192.168.0.1":                 ReasonNoTLD,
+		"john@example..com":                ReasonDomainInvalid,
+		"john@-example.com":                ReasonDomainInvalid,
+		"john@exa_mple.com":                ReasonDomainInvalid,
+		"john@" + strings.Repeat("a", 64) + ".com":                                             ReasonLabelTooLong,
+		"john@[192.0.2.1]":                                                                     ReasonDomainLiteral,
+		"a@" + strings.Repeat(strings.Repeat("a", 60)+".", 5) + "com":                          ReasonDomainTooLong,
+		strings.Repeat("a", 64) + "@" + strings.Repeat(strings.Repeat("a", 60)+".", 4) + "com": ReasonTooLong,
+	} {
+		err := s.Validate(nil, address)
+		require.True(t, errors.Is(err, ErrInvalidAddress), address)
+
+		addressErr := &AddressError{}
+		require.True(t, errors.As(err, &addressErr))
+		require.Equal(t, reason, addressErr.Reason, address)
+	}
+}
+
+func TestSyntaxDomainLiteral(t *testing.T) {
+	s := Syntax{AllowDomainLiteral: true}
+	require.NoError(t, s.Validate(nil, "john@[192.0.2.1]"))
+	require.NoError(t, s.Validate(nil, "john@[IPv6:2001:db8::1]"))
+
+	err := s.Validate(nil, "john@[IPv6:192.0.2.1]")
+	require.True(t, errors.Is(err, ErrInvalidAddress))
+	err = s.Validate(nil, "john@[example.com]")
+	require.True(t, errors.Is(err, ErrInvalidAddress))
+}
+
+type fakeResolver struct {
+	mx      map[string][]*net.MX
+	ips     map[string][]net.IPAddr
+	err     error
+	lookups int
+}
+
+func (r *fakeResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	r.lookups++
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if res, ok := r.mx[name]; ok {
+		return res, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r *fakeResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	if res, ok := r.ips[host]; ok {
+		return res, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func newFakeResolver() *fakeResolver {
+	return &fakeResolver{
+		mx: map[string][]*net.MX{
+			"example.com":      {{Host: "mx1.example.com.", Pref: 10}, {Host: "mx2.example.com.", Pref: 20}},
+			"null.example.com": {{Host: ".", Pref: 0}},
+			"xn--bcher-kva.de": {{Host: "mx.xn--bcher-kva.de.", Pref: 10}},
+		},
+		ips: map[string][]net.IPAddr{
+			"a.example.com": {{IP: net.ParseIP("192.0.2.1")}},
+		},
+	}
+}
+
+func TestMX(t *testing.T) {
+	m := NewMX(MXParams{Resolver: newFakeResolver()})
+
+	require.NoError(t, m.Validate(nil, "john@example.com"))
+	require.NoError(t, m.Validate(nil, "john@EXAMPLE.com."))
+	require.NoError(t, m.Validate(nil, "john@bücher.de"))
+	require.NoError(t, m.Validate(nil, "john@a.example.com"))
+
+	for address, reason := range map[string]string{
+		"john@null.example.com":    ReasonNullMX,
+		"john@missing.example.com": ReasonNoMailServer,
+	} {
+		err := m.Validate(nil, address)
+		require.True(t, errors.Is(err, ErrInvalidAddress))
+		addressErr := &AddressError{}
+		require.True(t, errors.As(err, &addressErr))
+		require.Equal(t, reason, addressErr.Reason)
+	}
+}
+
+func TestMXCache(t *testing.T) {
+	resolver := newFakeResolver()
+	m := NewMX(MXParams{Resolver: resolver, TTL: time.Minute})
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	require.NoError(t, m.Validate(nil, "john@example.com"))
+	require.NoError(t, m.Validate(nil, "jane@example.com"))
+	require.Equal(t, 1, resolver.lookups)
+
+	require.Error(t, m.Validate(nil, "john@null.example.com"))
+	require.Error(t, m.Validate(nil, "john@null.example.com"))
+	require.Equal(t, 2, resolver.lookups)
+
+	now = now.Add(time.Minute)
+	require.NoError(t, m.Validate(nil, "john@example.com"))
+	require.Equal(t, 3, resolver.lookups)
+
+	// temporary errors are not cached
+	resolver.err = &net.DNSError{Err: "server misbehaving", Name: "other.com", IsTemporary: true}
+	err := m.Validate(nil, "john@other.com")
+	require.Error(t, err)
+	require.False(t, errors.Is(err, ErrInvalidAddress))
+	resolver.err = nil
+	resolver.mx["other.com"] = []*net.MX{{Host: "mx.other.com.", Pref: 10}}
+	require.NoError(t, m.Validate(nil, "john@other.com"))
+}
+
+func TestMXContext(t *testing.T) {
+	m := NewMX(MXParams{Resolver: newFakeResolver()})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := m.Validate(ctx, "john@example.com")
+	require.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestChain(t *testing.T) {
+	resolver := newFakeResolver()
+	v := Chain(NewSyntax(), NewMX(MXParams{Resolver: resolver}))
+
+	require.NoError(t, v.Validate(nil, "john@example.com"))
+	require.True(t, errors.Is(v.Validate(nil, "john..doe@example.com"), ErrInvalidAddress))
+	require.True(t, errors.Is(v.Validate(nil, "john@null.example.com"), ErrInvalidAddress))
+	require.Equal(t, 2, resolver.lookups)
+}